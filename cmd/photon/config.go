@@ -0,0 +1,104 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/mahamedmuse/photon/internal/config"
+	"github.com/spf13/cobra"
+)
+
+func newConfigCmd() *cobra.Command {
+	configCmd := &cobra.Command{
+		Use:   "config",
+		Short: "Read and change settings",
+		Long: `Read and change settings.
+
+Settings are merged from built-in defaults, the user config file, a
+project config (.photon.json or photon.toml in the working directory or a
+parent), and PHOTON_* environment variables (default_quality is
+PHOTON_DEFAULT_QUALITY), in that order. Command-line flags override all of
+them. "set" always writes the user config file.`,
+	}
+
+	getCmd := &cobra.Command{
+		Use:         "get <key>",
+		Short:       "Print the effective value of a setting",
+		Args:        cobra.ExactArgs(1),
+		Annotations: map[string]string{lenientConfig: ""},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			value, err := cfg.Get(args[0])
+			if err != nil {
+				return err
+			}
+			fmt.Println(value)
+			return nil
+		},
+	}
+
+	setCmd := &cobra.Command{
+		Use:         "set <key> <value>",
+		Short:       "Save a setting to the config file",
+		Example:     "  photon config set default_quality 85\n  photon config set favorite_formats webp,avif",
+		Args:        cobra.ExactArgs(2),
+		Annotations: map[string]string{lenientConfig: ""},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// Start from the file alone so environment overrides aren't saved.
+			fileCfg, err := config.Load()
+			if err != nil {
+				return fmt.Errorf("load config: %w", err)
+			}
+			if err := fileCfg.Set(args[0], args[1]); err != nil {
+				return err
+			}
+			// Other invalid settings don't block fixing this one.
+			var invalid config.ValidationError
+			if errors.As(fileCfg.Validate(), &invalid) && invalid.Has(args[0]) {
+				return invalid
+			}
+			return fileCfg.Save()
+		},
+	}
+
+	listCmd := &cobra.Command{
+		Use:         "list",
+		Short:       "List every setting with its value and where it came from",
+		Args:        cobra.NoArgs,
+		Annotations: map[string]string{lenientConfig: ""},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			for _, key := range config.Keys() {
+				value, _ := cfg.Get(key)
+				fmt.Fprintf(tw, "%s\t%s\t(%s)\n", key, value, cfg.Source(key))
+			}
+			fmt.Fprintf(tw, "presets\t%d defined\t(%s)\n", len(cfg.PresetNames()), cfg.Source("presets"))
+			if err := tw.Flush(); err != nil {
+				return err
+			}
+			if cfg.ProjectPath != "" {
+				fmt.Printf("\nProject config: %s\n", cfg.ProjectPath)
+			}
+			return nil
+		},
+	}
+
+	pathCmd := &cobra.Command{
+		Use:         "path",
+		Short:       "Print the config file location",
+		Args:        cobra.NoArgs,
+		Annotations: map[string]string{skipConfig: ""},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path, err := config.Path()
+			if err != nil {
+				return err
+			}
+			fmt.Println(path)
+			return nil
+		},
+	}
+
+	configCmd.AddCommand(getCmd, setCmd, listCmd, pathCmd)
+	return configCmd
+}
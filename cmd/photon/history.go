@@ -0,0 +1,185 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/mahamedmuse/photon/internal/config"
+	"github.com/mahamedmuse/photon/internal/history"
+	"github.com/mahamedmuse/photon/internal/image"
+	"github.com/spf13/cobra"
+)
+
+var historyLimit int
+
+func historyStatus(e history.Entry) string {
+	switch {
+	case e.Undone:
+		return "undone"
+	case e.Failed() > 0:
+		return fmt.Sprintf("%d failed", e.Failed())
+	}
+	return "ok"
+}
+
+func newHistoryCmd() *cobra.Command {
+	historyCmd := &cobra.Command{
+		Use:   "history",
+		Short: "List past conversions, run them again or undo them",
+		Long: `List past conversions, run them again or undo them.
+
+Every convert, batch and run is appended to history.jsonl in the config
+directory, next to config.json. Files a conversion replaced
+are backed up there too, so undo can put them back. The newest
+history_limit runs are kept; --no-history or history=false records
+nothing. An entry can be named by a unique prefix of its ID, or
+"last".`,
+		Example:     "  photon history\n  photon history show last\n  photon history rerun 20261018-1530\n  photon history undo last",
+		Args:        cobra.NoArgs,
+		Annotations: map[string]string{skipConfig: ""},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			log, err := openHistory()
+			if err != nil {
+				return err
+			}
+			entries, err := log.Load()
+			if err != nil {
+				return err
+			}
+			if len(entries) == 0 {
+				fmt.Println("No conversions recorded yet.")
+				return nil
+			}
+
+			tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(tw, "ID\tTIME\tCOMMAND\tFILES\tSTATUS")
+			oldest := 0
+			if historyLimit > 0 && len(entries) > historyLimit {
+				oldest = len(entries) - historyLimit
+			}
+			for i := len(entries) - 1; i >= oldest; i-- {
+				e := entries[i]
+				fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%s\n", e.ID, e.Time.Local().Format("2006-01-02 15:04"), e.Command, len(e.Files), historyStatus(e))
+			}
+			return tw.Flush()
+		},
+	}
+	historyCmd.Flags().IntVarP(&historyLimit, "limit", "n", 20, "Show at most this many entries (0 for all)")
+
+	showCmd := &cobra.Command{
+		Use:         "show <id>",
+		Short:       "Show the options and files of an entry",
+		Args:        cobra.ExactArgs(1),
+		Annotations: map[string]string{skipConfig: ""},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			log, err := openHistory()
+			if err != nil {
+				return err
+			}
+			e, err := log.Find(args[0])
+			if err != nil {
+				return err
+			}
+			fmt.Printf("%s  %s  %s  (%s)\n", e.ID, e.Time.Local().Format("2006-01-02 15:04:05"), e.Command, historyStatus(e))
+			if e.Recipe != "" {
+				fmt.Printf("recipe: %s\n", e.Recipe)
+			} else {
+				fmt.Printf("quality: %d", e.Options.Quality)
+				if e.Options.Width > 0 || e.Options.Height > 0 {
+					fmt.Printf("  size: %dx%d", e.Options.Width, e.Options.Height)
+				}
+				if e.Options.Metadata != "" {
+					fmt.Printf("  metadata: %s", e.Options.Metadata)
+				}
+				fmt.Println()
+			}
+			fmt.Println()
+			for _, f := range e.Files {
+				line := fmt.Sprintf("%s -> %s", f.Input, f.Output)
+				switch {
+				case f.Error != "":
+					line += "  FAILED: " + f.Error
+				case f.Backup != "":
+					line += "  (replaced an existing file)"
+				}
+				fmt.Println(line)
+			}
+			return nil
+		},
+	}
+
+	rerunCmd := &cobra.Command{
+		Use:         "rerun <id>",
+		Short:       "Convert an entry's inputs again with the same options",
+		Args:        cobra.ExactArgs(1),
+		Annotations: map[string]string{skipConfig: ""},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			log, err := openHistory()
+			if err != nil {
+				return err
+			}
+			log.Off = log.Off || noHistory
+			e, err := log.Find(args[0])
+			if err != nil {
+				return err
+			}
+			reporter, err := image.NewReporter(os.Stdout, output)
+			if err != nil {
+				return err
+			}
+			opts := e.Options.Apply(image.DefaultOptions())
+			opts.Reporter = reporter
+			rec, opts := log.Record(history.Entry{Command: "rerun", Recipe: e.Recipe}, opts)
+
+			runErr := history.Rerun(e, opts)
+			if err := rec.Save(); err != nil {
+				fmt.Fprintln(os.Stderr, "warning:", err)
+			}
+			if err := reporter.Close(); err != nil && runErr == nil {
+				return err
+			}
+			return runErr
+		},
+	}
+
+	undoCmd := &cobra.Command{
+		Use:   "undo <id>",
+		Short: "Remove the outputs an entry created and restore the files it replaced",
+		Long: `Remove the outputs an entry created and restore the files it replaced.
+
+Outputs that changed since the conversion are left alone.`,
+		Args:        cobra.ExactArgs(1),
+		Annotations: map[string]string{skipConfig: ""},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			log, err := openHistory()
+			if err != nil {
+				return err
+			}
+			e, err := log.Find(args[0])
+			if err != nil {
+				return err
+			}
+			if err := log.Undo(e); err != nil {
+				return err
+			}
+			fmt.Printf("Undid %s (%d files)\n", e.ID, len(e.Files))
+			return nil
+		},
+	}
+
+	rerunCmd.Flags().BoolVar(&noHistory, "no-history", false, "Don't record this run in the history")
+	historyCmd.AddCommand(showCmd, rerunCmd, undoCmd)
+	return historyCmd
+}
+
+// openHistory opens the history log with the history settings. The history
+// commands don't load the config up front, so a config that can't be read
+// leaves the defaults.
+func openHistory() (*history.Log, error) {
+	c, _ := config.Load()
+	if err := c.ApplyEnv(); err != nil {
+		return nil, err
+	}
+	return history.Open(c)
+}
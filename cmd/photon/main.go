@@ -1,20 +1,195 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"net/http"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
 
+	"github.com/mahamedmuse/photon/internal/config"
+	"github.com/mahamedmuse/photon/internal/history"
 	"github.com/mahamedmuse/photon/internal/image"
+	"github.com/mahamedmuse/photon/internal/recipe"
+	"github.com/mahamedmuse/photon/internal/server"
 	"github.com/mahamedmuse/photon/internal/tui"
+	"github.com/mahamedmuse/photon/internal/watch"
 	"github.com/spf13/cobra"
 )
 
 var (
-	quality int
-	fromExt string
-	toExt   string
+	quality     int
+	fromExt     string
+	toExt       string
+	output      string
+	dryRun      bool
+	noHistory   bool
+	incremental bool
+	resume      string
+	nameTmpl    string
+	onConflict  string
+	debounce    time.Duration
+	addr        string
+	serverCfg   = server.DefaultConfig()
+	sourceDir   string
+	cacheDir    string
+	presetName  string
+	preset      *config.Preset
+	outDir      string
+	cfg         config.Config
 )
 
+// withReporter runs fn with the options set by flags, reporting results on
+// stdout. Unless entry is nil, this is a dry run or history is turned off,
+// the run is recorded in the history as entry.
+func withReporter(entry *history.Entry, fn func(opts image.Options) error) error {
+	reporter, err := image.NewReporter(os.Stdout, output)
+	if err != nil {
+		return err
+	}
+	opts := image.DefaultOptions()
+	opts.Quality = quality
+	opts.Reporter = reporter
+	opts.DryRun = dryRun
+	opts.Incremental = incremental
+	opts.NameTemplate = image.NameTemplate(nameTmpl)
+	opts.OnConflict = onConflict
+	opts.OutputDir = outDir
+	if preset != nil {
+		opts = preset.Apply(opts)
+	}
+	if resume != "" {
+		// A resumed batch runs, and is recorded, with the options it
+		// started with.
+		journal, err := image.OpenJournal(resume)
+		if err != nil {
+			return err
+		}
+		opts = journal.Options(opts)
+		journal.Close()
+	}
+
+	var rec *history.Recorder
+	if entry != nil && !dryRun && !noHistory {
+		if log, err := history.Open(cfg); err == nil {
+			rec, opts = log.Record(*entry, opts)
+		}
+	}
+
+	runErr := fn(opts)
+	if rec != nil {
+		if err := rec.Save(); err != nil {
+			fmt.Fprintln(os.Stderr, "warning:", err)
+		}
+	}
+	if err := reporter.Close(); err != nil && runErr == nil {
+		return err
+	}
+	return runErr
+}
+
+// loadPreset resolves --preset. An explicit --quality overrides the
+// preset's quality.
+func loadPreset(cmd *cobra.Command) error {
+	if presetName == "" {
+		return nil
+	}
+	p, err := cfg.Preset(presetName)
+	if err != nil {
+		return err
+	}
+	if cmd.Flags().Changed("quality") {
+		p.Quality = quality
+	}
+	preset = &p
+	return nil
+}
+
+const (
+	skipConfig    = "skip-config"
+	lenientConfig = "lenient-config"
+)
+
+// loadConfig merges the user config, the project config found from the
+// working directory and PHOTON_* environment variables into the defaults
+// of flags that weren't set on the command line.
+//
+// Commands annotated with lenientConfig only warn about invalid settings,
+// so they can be used to inspect and fix them.
+func loadConfig(cmd *cobra.Command, args []string) error {
+	if _, ok := cmd.Annotations[skipConfig]; ok {
+		return nil
+	}
+
+	var err error
+	if cfg, err = config.Load(); err != nil {
+		return fmt.Errorf("load config: %w", err)
+	}
+	if wd, err := os.Getwd(); err == nil {
+		if _, err := cfg.LoadProject(wd); err != nil {
+			return fmt.Errorf("load project config: %w", err)
+		}
+	}
+	if err := cfg.ApplyEnv(); err != nil {
+		return err
+	}
+	// The built-in output_dir is for the TUI, which saves it to the file
+	// like every other setting. The CLI writes next to the inputs unless a
+	// different one is configured, and only checks it then.
+	useOutputDir := cfg.Source("output_dir") == config.SourceEnv || cfg.OutputDir != config.DefaultConfig().OutputDir
+	err = cfg.Validate()
+	var invalid config.ValidationError
+	if errors.As(err, &invalid) && !useOutputDir {
+		err = invalid.Without("output_dir")
+	}
+	if err != nil {
+		if _, ok := cmd.Annotations[lenientConfig]; !ok {
+			return err
+		}
+		fmt.Fprintln(os.Stderr, "warning:", err)
+	}
+
+	flags := cmd.Flags()
+	if !flags.Changed("quality") {
+		quality = cfg.DefaultQuality
+		// serve keeps its own default unless the setting is configured.
+		if cfg.Source("default_quality") != config.SourceDefault {
+			serverCfg.DefaultQuality = cfg.DefaultQuality
+		}
+	}
+	if !flags.Changed("name") && cfg.NameTemplate != "" {
+		nameTmpl = cfg.NameTemplate
+	}
+	if !flags.Changed("out-dir") && useOutputDir {
+		outDir = cfg.OutputDir
+	}
+	return nil
+}
+
+// targetFormat is --to, falling back to the preset's format and then to
+// default_format.
+func targetFormat() string {
+	if toExt != "" {
+		return toExt
+	}
+	if preset != nil && preset.Format != "" {
+		return preset.Format
+	}
+	return cfg.DefaultFormat
+}
+
+func defaultCacheDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "photon", "img")
+}
+
 func main() {
 	rootCmd := &cobra.Command{
 		Use:   "photon",
@@ -28,6 +203,8 @@ func main() {
   Run without arguments to launch the interactive TUI.
   Use subcommands for CLI/scripting mode.
 `,
+		// The TUI loads the config itself and shows what is wrong with it.
+		Annotations: map[string]string{skipConfig: ""},
 		Run: func(cmd *cobra.Command, args []string) {
 			if err := tui.Run(); err != nil {
 				fmt.Fprintln(os.Stderr, err)
@@ -40,35 +217,191 @@ func main() {
 		Use:     "convert <input> <output>",
 		Aliases: []string{"c"},
 		Short:   "Convert a single image (CLI mode)",
-		Example: "  photon convert photo.heic photo.jpg\n  photon convert input.png output.webp -q 85",
+		Example: "  photon convert photo.heic photo.jpg\n  photon convert input.png output.webp -q 85\n  photon convert photo.jpg thumb --preset thumbnail",
 		Args:    cobra.ExactArgs(2),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			opts := image.DefaultOptions()
-			opts.Quality = quality
-			return image.Convert(args[0], args[1], opts)
+			if err := loadPreset(cmd); err != nil {
+				return err
+			}
+			out := args[1]
+			if filepath.Ext(out) == "" {
+				out += "." + targetFormat()
+			}
+			return withReporter(&history.Entry{Command: "convert"}, func(opts image.Options) error {
+				return image.Convert(args[0], out, opts)
+			})
 		},
 	}
 	convertCmd.Flags().IntVarP(&quality, "quality", "q", 95, "Output quality (1-100)")
+	convertCmd.Flags().BoolVar(&dryRun, "dry-run", false, "Show what would be converted without writing anything")
+	convertCmd.Flags().BoolVar(&noHistory, "no-history", false, "Don't record this run in the history")
+	convertCmd.Flags().StringVar(&presetName, "preset", "", "Apply a named preset (an output without extension gets the preset's format)")
 
 	batchCmd := &cobra.Command{
 		Use:     "batch <directory>",
 		Aliases: []string{"b"},
 		Short:   "Convert all images in a directory (CLI mode)",
-		Example: "  photon batch ./photos --from heic --to jpg\n  photon batch ./images --from png --to webp -q 80",
-		Args:    cobra.ExactArgs(1),
+		Example: "  photon batch ./photos --from heic --to jpg\n  photon batch ./images --from png --to webp -q 80\n  photon batch ./photos --from heic --to jpg --dry-run\n  photon batch ./images --from png --to webp --name '{name}-{width}w.{ext}'\n  photon batch ./images --from png --preset web\n  photon batch --resume ./photos/" + image.JournalName,
+		Args:    cobra.MaximumNArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			opts := image.DefaultOptions()
-			opts.Quality = quality
-			return image.ConvertBatch(args[0], fromExt, toExt, opts)
+			if resume != "" {
+				if len(args) > 0 {
+					return fmt.Errorf("--resume does not take a directory")
+				}
+				return withReporter(&history.Entry{Command: "batch"}, func(opts image.Options) error {
+					return image.ResumeBatch(resume, opts)
+				})
+			}
+
+			if len(args) != 1 {
+				return fmt.Errorf("requires a directory argument")
+			}
+			if err := loadPreset(cmd); err != nil {
+				return err
+			}
+			toExt = targetFormat()
+			if fromExt == "" || toExt == "" {
+				return fmt.Errorf("--from and --to are required")
+			}
+			return withReporter(&history.Entry{Command: "batch"}, func(opts image.Options) error {
+				return image.ConvertBatch(args[0], fromExt, toExt, opts)
+			})
 		},
 	}
 	batchCmd.Flags().IntVarP(&quality, "quality", "q", 95, "Output quality (1-100)")
+	batchCmd.Flags().BoolVar(&dryRun, "dry-run", false, "Show what would be converted without writing anything")
+	batchCmd.Flags().BoolVar(&noHistory, "no-history", false, "Don't record this run in the history")
+	batchCmd.Flags().BoolVarP(&incremental, "incremental", "i", false, "Skip files whose output is up to date")
 	batchCmd.Flags().StringVar(&fromExt, "from", "", "Source format (required)")
-	batchCmd.Flags().StringVar(&toExt, "to", "", "Target format (required)")
-	batchCmd.MarkFlagRequired("from")
-	batchCmd.MarkFlagRequired("to")
+	batchCmd.Flags().StringVar(&toExt, "to", "", "Target format (default: the preset's, then default_format from the config)")
+	batchCmd.Flags().StringVar(&outDir, "out-dir", "", "Write outputs to this directory instead of next to the inputs")
+	batchCmd.Flags().StringVar(&nameTmpl, "name", image.DefaultNameTemplate, "Output name template ({name} {ext} {width} {height} {quality} {date} {index} {hash8})")
+	batchCmd.Flags().StringVar(&onConflict, "on-conflict", image.ConflictOverwrite, "What to do when an output exists (overwrite, rename)")
+	batchCmd.Flags().StringVar(&presetName, "preset", "", "Apply a named preset (its format is used when --to is omitted)")
+	batchCmd.Flags().StringVar(&resume, "resume", "", "Resume the batch recorded in a journal file")
+	batchCmd.MarkFlagsMutuallyExclusive("resume", "from")
+	batchCmd.MarkFlagsMutuallyExclusive("resume", "to")
+	batchCmd.MarkFlagsMutuallyExclusive("resume", "preset")
+
+	watchCmd := &cobra.Command{
+		Use:     "watch <directory>",
+		Aliases: []string{"w"},
+		Short:   "Convert images as they appear in a directory",
+		Example: "  photon watch ./screenshots --to webp\n  photon watch ./exports --from png --to avif -q 70",
+		Args:    cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+
+			return withReporter(nil, func(opts image.Options) error {
+				w := &watch.Watcher{
+					Dir:      args[0],
+					FromExt:  fromExt,
+					ToExt:    targetFormat(),
+					Options:  opts,
+					Debounce: debounce,
+					Log:      os.Stderr,
+				}
+				fmt.Fprintf(os.Stderr, "Watching %s (Ctrl+C to stop)\n", args[0])
+				return w.Run(ctx)
+			})
+		},
+	}
+	watchCmd.Flags().IntVarP(&quality, "quality", "q", 95, "Output quality (1-100)")
+	watchCmd.Flags().StringVar(&fromExt, "from", "", "Only convert this source format")
+	watchCmd.Flags().StringVar(&toExt, "to", "", "Target format (default: default_format from the config)")
+	watchCmd.Flags().StringVar(&outDir, "out-dir", "", "Write outputs to this directory instead of next to the inputs")
+	watchCmd.Flags().StringVar(&nameTmpl, "name", image.DefaultNameTemplate, "Output name template")
+	watchCmd.Flags().StringVar(&onConflict, "on-conflict", image.ConflictOverwrite, "What to do when an output exists (overwrite, rename)")
+	watchCmd.Flags().DurationVar(&debounce, "debounce", watch.DefaultDebounce, "How long a file must stay unchanged before it is converted")
+
+	serveCmd := &cobra.Command{
+		Use:   "serve",
+		Short: "Run an HTTP conversion server",
+		Long: `Run an HTTP conversion server.
+
+Endpoints:
+  POST /convert?to=webp&q=80   convert the request body, respond with the image
+  POST /info                   format and dimensions of the request body
+  GET  /formats                formats this build can read and write
+  GET  /healthz                health check
+  GET  /img/<path>?w=&h=&fmt=&q=  transformed image from --source (with --source)
+
+/img picks AVIF or WebP from the Accept header when fmt is omitted and
+caches results in --cache-dir keyed by source content and options.`,
+		Example: "  photon serve --addr :8080\n  curl --data-binary @photo.png 'localhost:8080/convert?to=webp&q=80' -o photo.webp\n  photon serve --source ./public/images\n  curl 'localhost:8080/img/hero.jpg?w=400&fmt=avif&q=70' -o hero.avif",
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+
+			handler := server.New(serverCfg)
+			if sourceDir != "" {
+				if err := handler.ServeImages(sourceDir, cacheDir); err != nil {
+					return err
+				}
+			}
+
+			srv := &http.Server{
+				Addr:              addr,
+				Handler:           handler,
+				ReadHeaderTimeout: 10 * time.Second,
+			}
+
+			errc := make(chan error, 1)
+			go func() {
+				fmt.Fprintf(os.Stderr, "Listening on %s\n", addr)
+				errc <- srv.ListenAndServe()
+			}()
+
+			select {
+			case err := <-errc:
+				return err
+			case <-ctx.Done():
+			}
+
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+			return srv.Shutdown(shutdownCtx)
+		},
+	}
+	serveCmd.Flags().StringVar(&addr, "addr", "localhost:8080", "Address to listen on")
+	serveCmd.Flags().Int64Var(&serverCfg.MaxBytes, "max-bytes", serverCfg.MaxBytes, "Maximum request body size in bytes")
+	serveCmd.Flags().IntVar(&serverCfg.MaxPixels, "max-pixels", serverCfg.MaxPixels, "Maximum image size in pixels (width*height)")
+	serveCmd.Flags().IntVar(&serverCfg.MaxConcurrent, "concurrency", serverCfg.MaxConcurrent, "Maximum number of concurrent conversions")
+	serveCmd.Flags().IntVar(&serverCfg.MaxBodies, "max-bodies", serverCfg.MaxBodies, "Maximum number of request bodies and source images held in memory at once")
+	serveCmd.Flags().DurationVar(&serverCfg.ReadTimeout, "read-timeout", serverCfg.ReadTimeout, "Maximum time to read a request body")
+	serveCmd.Flags().StringVar(&sourceDir, "source", "", "Directory served by /img")
+	serveCmd.Flags().StringVar(&cacheDir, "cache-dir", defaultCacheDir(), "Directory for cached /img results (empty disables the cache)")
+	serveCmd.Flags().IntVarP(&serverCfg.DefaultQuality, "quality", "q", serverCfg.DefaultQuality, "Quality when a request doesn't set q (default_quality when configured)")
+
+	runCmd := &cobra.Command{
+		Use:     "run <recipe>",
+		Short:   "Run a recipe file producing several outputs per input",
+		Example: "  photon run release.yaml\n  photon run release.yaml --dry-run",
+		Args:    cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			r, err := recipe.Load(args[0])
+			if err != nil {
+				return err
+			}
+			path, err := filepath.Abs(args[0])
+			if err != nil {
+				return err
+			}
+			return withReporter(&history.Entry{Command: "run", Recipe: path}, func(opts image.Options) error {
+				return recipe.Run(r, opts)
+			})
+		},
+	}
+	runCmd.Flags().BoolVar(&dryRun, "dry-run", false, "Show what would be written without writing anything")
+	runCmd.Flags().BoolVar(&noHistory, "no-history", false, "Don't record this run in the history")
+	runCmd.Flags().StringVar(&onConflict, "on-conflict", image.ConflictOverwrite, "What to do when an output exists (overwrite, rename)")
+
+	rootCmd.PersistentPreRunE = loadConfig
+	rootCmd.PersistentFlags().StringVarP(&output, "output", "o", image.OutputText, "Format for printed results (text, json, ndjson); convert's output file is its second argument")
 
-	rootCmd.AddCommand(convertCmd, batchCmd)
+	rootCmd.AddCommand(convertCmd, batchCmd, watchCmd, serveCmd, runCmd, newConfigCmd(), newHistoryCmd())
 
 	if err := rootCmd.Execute(); err != nil {
 		fmt.Fprintln(os.Stderr, err)
@@ -2,37 +2,161 @@ package config
 
 import (
 	"encoding/json"
+	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mahamedmuse/photon/internal/image"
 )
 
 type Config struct {
-	DefaultQuality    int      `json:"default_quality"`
-	DefaultFormat     string   `json:"default_format"`
-	LastInputDir      string   `json:"last_input_dir"`
-	LastOutputDir     string   `json:"last_output_dir"`
-	OutputDir         string   `json:"output_dir"`
-	RecentFiles       []string `json:"recent_files"`
-	FavoriteFormats   []string `json:"favorite_formats"`
-	ShowHiddenFiles   bool     `json:"show_hidden_files"`
-	ConfirmOverwrite  bool     `json:"confirm_overwrite"`
-	PreserveOriginals bool     `json:"preserve_originals"`
+	Version           int          `json:"version"`
+	DefaultQuality    int          `json:"default_quality"`
+	DefaultFormat     string       `json:"default_format"`
+	LastInputDir      string       `json:"last_input_dir"`
+	LastOutputDir     string       `json:"last_output_dir"`
+	OutputDir         string       `json:"output_dir"`
+	RecentFiles       []RecentFile `json:"recent_files"`
+	FavoriteFormats   []string     `json:"favorite_formats"`
+	ShowHiddenFiles   bool         `json:"show_hidden_files"`
+	ConfirmOverwrite  bool         `json:"confirm_overwrite"`
+	PreserveOriginals bool         `json:"preserve_originals"`
+	LastBatchJournal  string       `json:"last_batch_journal,omitempty"`
+	NameTemplate      string       `json:"name_template,omitempty"`
+	Theme             string       `json:"theme"`
+
+	// History turns recording conversions in the history on, keeping the
+	// newest HistoryLimit runs; 0 keeps them all.
+	History      bool `json:"history"`
+	HistoryLimit int  `json:"history_limit"`
+
+	Presets map[string]Preset `json:"presets,omitempty"`
+
+	// Keys rebinds TUI actions, e.g. {"quit": ["ctrl+q"]}. Actions left
+	// out keep their default keys and an empty list unbinds one.
+	Keys map[string][]string `json:"keys,omitempty"`
+
+	// ProjectPath is the project config merged by LoadProject, if any.
+	ProjectPath string `json:"-"`
+
+	// sources records where each setting came from, see Source.
+	sources map[string]string
+	// user holds the settings before LoadProject, for Save.
+	user *Config
+	// unreadable is the path of a config file Load couldn't parse.
+	unreadable string
+	// path is the file the config was loaded from, where Save writes.
+	path string
+}
+
+// Preset is a named bundle of conversion settings. Zero fields leave the
+// corresponding setting alone.
+type Preset struct {
+	Format   string `json:"format,omitempty"`
+	Quality  int    `json:"quality,omitempty"`
+	Lossless bool   `json:"lossless,omitempty"`
+	Width    int    `json:"width,omitempty"`
+	Height   int    `json:"height,omitempty"`
+	// Metadata is "strip" or "keep".
+	Metadata string `json:"metadata,omitempty"`
+}
+
+func (p Preset) Validate() error {
+	if p.Format != "" {
+		if _, err := image.FormatFromExtension("." + p.Format); err != nil {
+			return err
+		}
+	}
+	if p.Quality < 0 || p.Quality > 100 {
+		return fmt.Errorf("quality must be between 1 and 100")
+	}
+	if p.Width < 0 || p.Height < 0 {
+		return fmt.Errorf("width and height must not be negative")
+	}
+	if p.Metadata != "" && p.Metadata != image.MetadataStrip && p.Metadata != image.MetadataKeep {
+		return fmt.Errorf("metadata must be %s or %s", image.MetadataStrip, image.MetadataKeep)
+	}
+	return nil
+}
+
+// Apply overrides opts with the settings the preset sets.
+func (p Preset) Apply(opts image.Options) image.Options {
+	if p.Quality != 0 {
+		opts.Quality = p.Quality
+	}
+	opts.Lossless = p.Lossless
+	opts.Width = p.Width
+	opts.Height = p.Height
+	if p.Metadata != "" {
+		opts.Metadata = p.Metadata
+	}
+	return opts
+}
+
+// Themes are the TUI color themes. "mono" uses no colors and is also
+// picked when NO_COLOR is set.
+var Themes = []string{"default", "light", "dracula", "nord", "mono"}
+
+func DefaultPresets() map[string]Preset {
+	return map[string]Preset{
+		"web":       {Format: "webp", Quality: 80, Width: 1920, Height: 1920, Metadata: "strip"},
+		"archive":   {Format: "png", Lossless: true, Metadata: "keep"},
+		"thumbnail": {Format: "jpg", Quality: 75, Width: 320, Height: 320, Metadata: "strip"},
+	}
 }
 
 func DefaultConfig() Config {
 	home, _ := os.UserHomeDir()
 	return Config{
+		Version:           CurrentVersion,
 		DefaultQuality:    95,
 		DefaultFormat:     "webp",
 		LastInputDir:      home,
 		LastOutputDir:     home,
 		OutputDir:         filepath.Join(home, "Downloads", "photon"),
-		RecentFiles:       []string{},
+		RecentFiles:       []RecentFile{},
 		FavoriteFormats:   []string{"webp", "avif", "jpg", "png"},
 		ShowHiddenFiles:   false,
 		ConfirmOverwrite:  true,
 		PreserveOriginals: false,
+		Theme:             "default",
+		History:           true,
+		HistoryLimit:      100,
+		Presets:           DefaultPresets(),
+	}
+}
+
+// Preset returns the named preset from the config, falling back to the
+// built-in presets.
+func (c *Config) Preset(name string) (Preset, error) {
+	if p, ok := c.Presets[name]; ok {
+		if err := p.Validate(); err != nil {
+			return Preset{}, fmt.Errorf("preset %s: %w", name, err)
+		}
+		return p, nil
+	}
+	if p, ok := DefaultPresets()[name]; ok {
+		return p, nil
+	}
+	return Preset{}, fmt.Errorf("unknown preset: %s (have %s)", name, strings.Join(c.PresetNames(), ", "))
+}
+
+// PresetNames lists the configured and built-in presets, sorted.
+func (c *Config) PresetNames() []string {
+	var names []string
+	for name := range DefaultPresets() {
+		names = append(names, name)
+	}
+	for name := range c.Presets {
+		if _, ok := DefaultPresets()[name]; !ok {
+			names = append(names, name)
+		}
 	}
+	sort.Strings(names)
+	return names
 }
 
 func (c *Config) EnsureOutputDir() error {
@@ -46,63 +170,142 @@ func (c *Config) EnsureOutputDir() error {
 	return os.MkdirAll(c.OutputDir, 0755)
 }
 
-func configPath() (string, error) {
-	home, err := os.UserHomeDir()
-	if err != nil {
-		return "", err
-	}
-	configDir := filepath.Join(home, ".config", "photon")
-	if err := os.MkdirAll(configDir, 0755); err != nil {
-		return "", err
+// Path returns the user config file, under $XDG_CONFIG_HOME/photon when
+// set and ~/.config/photon otherwise.
+func Path() (string, error) {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		dir = filepath.Join(home, ".config")
 	}
-	return filepath.Join(configDir, "config.json"), nil
+	return filepath.Join(dir, "photon", "config.json"), nil
 }
 
+// Load reads the user config file over the defaults, migrating it from
+// older versions. Environment overrides are not applied, see ApplyEnv, and
+// settings are not validated, see Validate.
+//
+// When the file can't be parsed Load returns the defaults and an error
+// pointing at the problem; Save then refuses to overwrite the file.
 func Load() (Config, error) {
-	path, err := configPath()
+	path, err := Path()
 	if err != nil {
 		return DefaultConfig(), err
 	}
+	return LoadFile(path)
+}
 
+// LoadFile is Load for the config file at path, which Save then writes.
+func LoadFile(path string) (Config, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
 		if os.IsNotExist(err) {
-			return DefaultConfig(), nil
+			cfg := DefaultConfig()
+			cfg.path = path
+			return cfg, nil
 		}
-		return DefaultConfig(), err
+		return unreadable(path), err
 	}
 
-	var cfg Config
-	if err := json.Unmarshal(data, &cfg); err != nil {
-		return DefaultConfig(), err
+	upgraded, err := upgrade(path, data)
+	if err != nil {
+		return unreadable(path), err
 	}
 
+	cfg := DefaultConfig()
+	if err := json.Unmarshal(upgraded, &cfg); err != nil {
+		return unreadable(path), decodeError(path, data, err)
+	}
+	cfg.markSources(data, SourceFile)
+	cfg.path = path
+
 	return cfg, nil
 }
 
+func unreadable(path string) Config {
+	cfg := DefaultConfig()
+	cfg.unreadable = path
+	return cfg
+}
+
+// Save writes the config through a temporary file and a rename, so a crash
+// mid-write leaves the previous file intact.
 func (c *Config) Save() error {
-	path, err := configPath()
-	if err != nil {
+	if c.unreadable != "" {
+		return fmt.Errorf("not overwriting %s, which has errors; fix or remove it first", c.unreadable)
+	}
+
+	path := c.path
+	if path == "" {
+		var err error
+		if path, err = Path(); err != nil {
+			return err
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
 		return err
 	}
 
-	data, err := json.MarshalIndent(c, "", "  ")
+	out := c.userSettings()
+	out.Version = CurrentVersion
+	data, err := json.MarshalIndent(out, "", "  ")
 	if err != nil {
 		return err
 	}
 
-	return os.WriteFile(path, data, 0644)
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".config-*.json")
+	if err != nil {
+		return fmt.Errorf("write config: %w", err)
+	}
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("write config: %w", err)
+	}
+	return nil
 }
 
-func (c *Config) AddRecentFile(path string) {
+// RecentFile is a converted input and the settings it was converted with.
+type RecentFile struct {
+	Path        string    `json:"path"`
+	Output      string    `json:"output,omitempty"`
+	Format      string    `json:"format,omitempty"`
+	Quality     int       `json:"quality,omitempty"`
+	Preset      string    `json:"preset,omitempty"`
+	ConvertedAt time.Time `json:"converted_at,omitzero"`
+}
+
+func (c *Config) AddRecentFile(r RecentFile) {
 	for i, f := range c.RecentFiles {
-		if f == path {
+		if f.Path == r.Path {
 			c.RecentFiles = append(c.RecentFiles[:i], c.RecentFiles[i+1:]...)
 			break
 		}
 	}
-	c.RecentFiles = append([]string{path}, c.RecentFiles...)
+	c.RecentFiles = append([]RecentFile{r}, c.RecentFiles...)
 	if len(c.RecentFiles) > 10 {
 		c.RecentFiles = c.RecentFiles[:10]
 	}
 }
+
+// RemoveRecentFile drops the entry for path.
+func (c *Config) RemoveRecentFile(path string) {
+	for i, f := range c.RecentFiles {
+		if f.Path == path {
+			c.RecentFiles = append(c.RecentFiles[:i], c.RecentFiles[i+1:]...)
+			return
+		}
+	}
+}
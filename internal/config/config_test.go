@@ -0,0 +1,382 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	goimage "image"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/mahamedmuse/photon/internal/image"
+)
+
+func TestPreset(t *testing.T) {
+	cfg := Config{Presets: map[string]Preset{
+		"web":   {Format: "avif", Quality: 60},
+		"email": {Format: "jpg", Quality: 70, Width: 1024},
+		"bad":   {Format: "jpg", Metadata: "maybe"},
+	}}
+
+	p, err := cfg.Preset("web")
+	if err != nil || p.Format != "avif" {
+		t.Errorf("Preset(web) = %+v, %v; want the configured override", p, err)
+	}
+	if p, err := cfg.Preset("thumbnail"); err != nil || p.Width != 320 {
+		t.Errorf("Preset(thumbnail) = %+v, %v; want the built-in preset", p, err)
+	}
+	if _, err := cfg.Preset("bad"); err == nil || !strings.Contains(err.Error(), "metadata") {
+		t.Errorf("Preset(bad) error = %v, want metadata error", err)
+	}
+	if _, err := cfg.Preset("missing"); err == nil {
+		t.Error("expected error for unknown preset")
+	}
+
+	want := "archive,bad,email,thumbnail,web"
+	if got := strings.Join(cfg.PresetNames(), ","); got != want {
+		t.Errorf("PresetNames() = %s, want %s", got, want)
+	}
+}
+
+func TestDefaultPresetsKeepAspectRatio(t *testing.T) {
+	tests := []struct {
+		preset       string
+		srcW, srcH   int
+		wantW, wantH int
+	}{
+		{"web", 2560, 1920, 1920, 1440},
+		{"web", 1000, 2000, 960, 1920},
+		{"thumbnail", 640, 480, 320, 240},
+		{"thumbnail", 300, 600, 160, 320},
+	}
+	for _, tt := range tests {
+		p := DefaultPresets()[tt.preset]
+		opts := p.Apply(image.DefaultOptions())
+		src := goimage.NewRGBA(goimage.Rect(0, 0, tt.srcW, tt.srcH))
+		b := image.Resize(src, opts.Width, opts.Height).Bounds()
+		if b.Dx() != tt.wantW || b.Dy() != tt.wantH {
+			t.Errorf("%s on %dx%d = %dx%d, want %dx%d", tt.preset, tt.srcW, tt.srcH, b.Dx(), b.Dy(), tt.wantW, tt.wantH)
+		}
+	}
+}
+
+func TestLoadMergesFileOverDefaults(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", dir)
+
+	path, err := Path()
+	if err != nil || path != filepath.Join(dir, "photon", "config.json") {
+		t.Fatalf("Path() = %s, %v", path, err)
+	}
+	os.MkdirAll(filepath.Dir(path), 0755)
+	os.WriteFile(path, []byte(`{"default_quality": 70}`), 0644)
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if cfg.DefaultQuality != 70 || cfg.DefaultFormat != "webp" {
+		t.Errorf("quality = %d, format = %s; want 70 from the file and webp by default", cfg.DefaultQuality, cfg.DefaultFormat)
+	}
+	if cfg.Source("default_quality") != SourceFile || cfg.Source("default_format") != SourceDefault {
+		t.Errorf("sources = %s, %s", cfg.Source("default_quality"), cfg.Source("default_format"))
+	}
+}
+
+func TestGetSet(t *testing.T) {
+	cfg := DefaultConfig()
+
+	tests := []struct {
+		key, value string
+	}{
+		{"default_quality", "80"},
+		{"default_format", "avif"},
+		{"show_hidden_files", "true"},
+		{"favorite_formats", "webp,png"},
+	}
+	for _, tt := range tests {
+		if err := cfg.Set(tt.key, tt.value); err != nil {
+			t.Errorf("Set(%s) error = %v", tt.key, err)
+		}
+		if got, _ := cfg.Get(tt.key); got != tt.value {
+			t.Errorf("Get(%s) = %q, want %q", tt.key, got, tt.value)
+		}
+	}
+
+	if err := cfg.Set("default_quality", "high"); err == nil {
+		t.Error("expected error for non-numeric quality")
+	}
+	if _, err := cfg.Get("presets"); err == nil {
+		t.Error("expected error for presets, which are edited in the file")
+	}
+}
+
+func TestApplyEnv(t *testing.T) {
+	t.Setenv("PHOTON_DEFAULT_QUALITY", "60")
+	t.Setenv("PHOTON_OUTPUT_DIR", "/tmp/out")
+
+	cfg := DefaultConfig()
+	if err := cfg.ApplyEnv(); err != nil {
+		t.Fatalf("ApplyEnv() error = %v", err)
+	}
+	if cfg.DefaultQuality != 60 || cfg.OutputDir != "/tmp/out" {
+		t.Errorf("quality = %d, output dir = %s", cfg.DefaultQuality, cfg.OutputDir)
+	}
+	if cfg.Source("output_dir") != SourceEnv {
+		t.Errorf("Source(output_dir) = %s, want env", cfg.Source("output_dir"))
+	}
+
+	t.Setenv("PHOTON_SHOW_HIDDEN_FILES", "maybe")
+	if err := cfg.ApplyEnv(); err == nil || !strings.Contains(err.Error(), "PHOTON_SHOW_HIDDEN_FILES") {
+		t.Errorf("ApplyEnv() error = %v, want one naming the variable", err)
+	}
+}
+
+func TestLoadProject(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	root := t.TempDir()
+	sub := filepath.Join(root, "assets", "icons")
+	os.MkdirAll(sub, 0755)
+
+	cfg := DefaultConfig()
+	if path, err := cfg.LoadProject(sub); err != nil || path != "" {
+		t.Fatalf("LoadProject() without a project = %q, %v", path, err)
+	}
+
+	os.WriteFile(filepath.Join(root, ".photon.json"), []byte(`{
+		"default_format": "avif",
+		"output_dir": "public/img",
+		"presets": {"hero": {"format": "webp", "width": 1600}}
+	}`), 0644)
+
+	path, err := cfg.LoadProject(sub)
+	if err != nil || path != filepath.Join(root, ".photon.json") {
+		t.Fatalf("LoadProject() = %q, %v", path, err)
+	}
+	if cfg.DefaultFormat != "avif" || cfg.Source("default_format") != SourceProject {
+		t.Errorf("default_format = %s from %s", cfg.DefaultFormat, cfg.Source("default_format"))
+	}
+	if want := filepath.Join(root, "public", "img"); cfg.OutputDir != want {
+		t.Errorf("output_dir = %s, want %s", cfg.OutputDir, want)
+	}
+	if _, err := cfg.Preset("hero"); err != nil {
+		t.Errorf("project preset: %v", err)
+	}
+	if _, err := cfg.Preset("web"); err != nil {
+		t.Errorf("built-in preset: %v", err)
+	}
+
+	// Saving keeps project settings out of the user config.
+	cfg.DefaultQuality = 70
+	if err := cfg.Save(); err != nil {
+		t.Fatal(err)
+	}
+	saved, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved.DefaultQuality != 70 || saved.DefaultFormat != "webp" {
+		t.Errorf("saved quality = %d, format = %s; want 70 and webp", saved.DefaultQuality, saved.DefaultFormat)
+	}
+	if _, ok := saved.Presets["hero"]; ok {
+		t.Error("project preset saved to the user config")
+	}
+}
+
+func TestLoadProjectTOML(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "photon.toml"), []byte("default_quality = 60\n\n[presets.banner]\nformat = \"jpg\"\nquality = 70\n"), 0644)
+
+	cfg := DefaultConfig()
+	if _, err := cfg.LoadProject(dir); err != nil {
+		t.Fatalf("LoadProject() error = %v", err)
+	}
+	if cfg.DefaultQuality != 60 {
+		t.Errorf("default_quality = %d, want 60", cfg.DefaultQuality)
+	}
+	if p, err := cfg.Preset("banner"); err != nil || p.Quality != 70 {
+		t.Errorf("Preset(banner) = %+v, %v", p, err)
+	}
+}
+
+func writeUserConfig(t *testing.T, content string) string {
+	t.Helper()
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	path, _ := Path()
+	os.MkdirAll(filepath.Dir(path), 0755)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadMigrates(t *testing.T) {
+	writeUserConfig(t, `{"default_format": "JPEG", "favorite_formats": [".tif", "webp"], "presets": {"x": {"format": "Jpeg"}}, "recent_files": ["/a.png"]}`)
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if cfg.Version != CurrentVersion {
+		t.Errorf("version = %d, want %d", cfg.Version, CurrentVersion)
+	}
+	if cfg.DefaultFormat != "jpg" || strings.Join(cfg.FavoriteFormats, ",") != "tiff,webp" || cfg.Presets["x"].Format != "jpg" {
+		t.Errorf("formats not normalized: %s %v %s", cfg.DefaultFormat, cfg.FavoriteFormats, cfg.Presets["x"].Format)
+	}
+	if len(cfg.RecentFiles) != 1 || cfg.RecentFiles[0].Path != "/a.png" {
+		t.Errorf("recent files = %+v, want /a.png", cfg.RecentFiles)
+	}
+}
+
+func TestAddRecentFile(t *testing.T) {
+	cfg := DefaultConfig()
+	for i := 0; i < 12; i++ {
+		cfg.AddRecentFile(RecentFile{Path: fmt.Sprintf("/%d.png", i)})
+	}
+	cfg.AddRecentFile(RecentFile{Path: "/5.png", Format: "webp"})
+
+	if len(cfg.RecentFiles) != 10 {
+		t.Fatalf("kept %d recent files, want 10", len(cfg.RecentFiles))
+	}
+	if r := cfg.RecentFiles[0]; r.Path != "/5.png" || r.Format != "webp" {
+		t.Errorf("most recent = %+v, want /5.png converted to webp", r)
+	}
+	for _, r := range cfg.RecentFiles[1:] {
+		if r.Path == "/5.png" {
+			t.Error("duplicate entry kept")
+		}
+	}
+
+	cfg.RemoveRecentFile("/5.png")
+	if len(cfg.RecentFiles) != 9 || cfg.RecentFiles[0].Path == "/5.png" {
+		t.Errorf("RemoveRecentFile left %+v", cfg.RecentFiles)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{"syntax", "{\n  \"default_quality\": 90,\n}\n", "config.json:3:1"},
+		{"type", `{"default_quality": "high"}`, "default_quality must be a number"},
+		{"newer version", `{"version": 99}`, "newer than this build"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeUserConfig(t, tt.content)
+
+			cfg, err := Load()
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Load() error = %v, want containing %q", err, tt.wantErr)
+			}
+			if err := cfg.Save(); err == nil {
+				t.Error("Save() overwrote a config file that failed to load")
+			}
+			if data, _ := os.ReadFile(path); string(data) != tt.content {
+				t.Error("config file changed")
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "file")
+	os.WriteFile(file, nil, 0644)
+
+	cfg := DefaultConfig()
+	cfg.OutputDir = t.TempDir()
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("default config is invalid: %v", err)
+	}
+	if entries, _ := os.ReadDir(cfg.OutputDir); len(entries) != 0 {
+		t.Errorf("Validate wrote %d files into output_dir", len(entries))
+	}
+
+	cfg.DefaultQuality = 0
+	cfg.DefaultFormat = "xyz"
+	cfg.OutputDir = filepath.Join(file, "sub")
+	cfg.Theme = "neon"
+	cfg.setSource("default_format", SourceEnv)
+
+	var invalid ValidationError
+	if !errors.As(cfg.Validate(), &invalid) {
+		t.Fatal("expected a ValidationError")
+	}
+	for _, key := range []string{"default_quality", "default_format", "output_dir", "theme"} {
+		if !invalid.Has(key) {
+			t.Errorf("%s not reported in %v", key, invalid)
+		}
+	}
+	if !strings.Contains(invalid.Error(), `unknown format "xyz" (from env)`) {
+		t.Errorf("error doesn't name the source: %v", invalid)
+	}
+	if rest, ok := invalid.Without("output_dir").(ValidationError); !ok || rest.Has("output_dir") || len(rest) != len(invalid)-1 {
+		t.Errorf("Without(output_dir) = %v", rest)
+	}
+}
+
+func TestValidateReadOnlyOutputDir(t *testing.T) {
+	if runtime.GOOS == "windows" || os.Geteuid() == 0 {
+		t.Skip("permissions aren't enforced")
+	}
+	dir := t.TempDir()
+	os.Chmod(dir, 0555)
+	t.Cleanup(func() { os.Chmod(dir, 0755) })
+
+	// Both the directory and one that would be created in it are rejected.
+	for _, outputDir := range []string{dir, filepath.Join(dir, "new", "out")} {
+		cfg := DefaultConfig()
+		cfg.OutputDir = outputDir
+		var invalid ValidationError
+		if !errors.As(cfg.Validate(), &invalid) || !invalid.Has("output_dir") {
+			t.Errorf("read-only %s passed validation", outputDir)
+		}
+	}
+}
+
+func TestSaveAtomic(t *testing.T) {
+	path := writeUserConfig(t, `{"default_quality": 90}`)
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.DefaultQuality = 50
+	if err := cfg.Save(); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	entries, _ := os.ReadDir(filepath.Dir(path))
+	if len(entries) != 1 {
+		t.Errorf("config directory has %d entries, want only config.json", len(entries))
+	}
+	saved, err := Load()
+	if err != nil || saved.DefaultQuality != 50 || saved.Version != CurrentVersion {
+		t.Errorf("saved config = quality %d, version %d, %v", saved.DefaultQuality, saved.Version, err)
+	}
+}
+
+func TestLoadFileSavesBack(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	path := filepath.Join(t.TempDir(), "nested", "config.json")
+
+	cfg, err := LoadFile(path)
+	if err != nil || cfg.DefaultQuality != DefaultConfig().DefaultQuality {
+		t.Fatalf("LoadFile of a missing file = %+v, %v", cfg, err)
+	}
+	cfg.DefaultQuality = 40
+	if err := cfg.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if saved, err := LoadFile(path); err != nil || saved.DefaultQuality != 40 {
+		t.Errorf("reloaded quality %d, %v", saved.DefaultQuality, err)
+	}
+	user, _ := Path()
+	if _, err := os.Stat(user); err == nil {
+		t.Errorf("Save wrote the user config %s too", user)
+	}
+}
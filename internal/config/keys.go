@@ -0,0 +1,161 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// EnvPrefix prefixes the environment variables that override settings:
+// default_quality is PHOTON_DEFAULT_QUALITY.
+const EnvPrefix = "PHOTON_"
+
+// Sources of a setting, from lowest to highest precedence.
+const (
+	SourceDefault = "default"
+	SourceFile    = "file"
+	SourceEnv     = "env"
+)
+
+type setting struct {
+	key   string
+	index int
+}
+
+// settings lists, in declaration order, the settings that can be read and
+// written as strings. Presets are edited in the file.
+func settings() []setting {
+	var list []setting
+	t := reflect.TypeFor[Config]()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		key, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if key == "" || key == "-" || key == "version" {
+			continue
+		}
+		switch f.Type.Kind() {
+		case reflect.String, reflect.Int, reflect.Bool:
+		case reflect.Slice:
+			if f.Type.Elem().Kind() != reflect.String {
+				continue
+			}
+		default:
+			continue
+		}
+		list = append(list, setting{key: key, index: i})
+	}
+	return list
+}
+
+// Keys lists the settings Get and Set accept.
+func Keys() []string {
+	var keys []string
+	for _, s := range settings() {
+		keys = append(keys, s.key)
+	}
+	return keys
+}
+
+func (c *Config) field(key string) (reflect.Value, error) {
+	for _, s := range settings() {
+		if s.key == key {
+			return reflect.ValueOf(c).Elem().Field(s.index), nil
+		}
+	}
+	return reflect.Value{}, fmt.Errorf("unknown setting: %s (see photon config list)", key)
+}
+
+// Get formats a setting as a string. Lists are comma separated.
+func (c *Config) Get(key string) (string, error) {
+	v, err := c.field(key)
+	if err != nil {
+		return "", err
+	}
+	switch v.Kind() {
+	case reflect.Int:
+		return strconv.Itoa(int(v.Int())), nil
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool()), nil
+	case reflect.Slice:
+		return strings.Join(v.Interface().([]string), ","), nil
+	default:
+		return v.String(), nil
+	}
+}
+
+// Set parses value into a setting. Lists are comma separated.
+func (c *Config) Set(key, value string) error {
+	v, err := c.field(key)
+	if err != nil {
+		return err
+	}
+	switch v.Kind() {
+	case reflect.Int:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("%s: %q is not a number", key, value)
+		}
+		v.SetInt(int64(n))
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("%s: %q is not true or false", key, value)
+		}
+		v.SetBool(b)
+	case reflect.Slice:
+		list := []string{}
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+		v.Set(reflect.ValueOf(list))
+	default:
+		v.SetString(value)
+	}
+	return nil
+}
+
+// ApplyEnv overrides settings from PHOTON_* environment variables.
+func (c *Config) ApplyEnv() error {
+	for _, key := range Keys() {
+		value, ok := os.LookupEnv(EnvPrefix + strings.ToUpper(key))
+		if !ok {
+			continue
+		}
+		if err := c.Set(key, value); err != nil {
+			return fmt.Errorf("environment variable %s%s: %w", EnvPrefix, strings.ToUpper(key), err)
+		}
+		c.setSource(key, SourceEnv)
+	}
+	return nil
+}
+
+// Source reports where a setting's value came from.
+func (c *Config) Source(key string) string {
+	if s, ok := c.sources[key]; ok {
+		return s
+	}
+	return SourceDefault
+}
+
+func (c *Config) setSource(key, source string) {
+	if c.sources == nil {
+		c.sources = make(map[string]string)
+	}
+	c.sources[key] = source
+}
+
+// markSources records every key present in the JSON document data.
+func (c *Config) markSources(data []byte, source string) {
+	var raw map[string]json.RawMessage
+	if json.Unmarshal(data, &raw) != nil {
+		return
+	}
+	for key := range raw {
+		c.setSource(key, source)
+	}
+}
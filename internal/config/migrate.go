@@ -0,0 +1,126 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// CurrentVersion is the config schema version this build writes.
+const CurrentVersion = 2
+
+// migrations[i] upgrades a version i document to version i+1.
+var migrations = []func(doc map[string]any){
+	// 1: format names are lowercase without a dot, spelled jpg and tiff.
+	func(doc map[string]any) {
+		if f, ok := doc["default_format"].(string); ok {
+			doc["default_format"] = normalizeFormat(f)
+		}
+		if list, ok := doc["favorite_formats"].([]any); ok {
+			for i, f := range list {
+				if s, ok := f.(string); ok {
+					list[i] = normalizeFormat(s)
+				}
+			}
+		}
+		if presets, ok := doc["presets"].(map[string]any); ok {
+			for _, p := range presets {
+				if p, ok := p.(map[string]any); ok {
+					if f, ok := p["format"].(string); ok {
+						p["format"] = normalizeFormat(f)
+					}
+				}
+			}
+		}
+	},
+	// 2: recent_files entries are objects recording the conversion.
+	func(doc map[string]any) {
+		if list, ok := doc["recent_files"].([]any); ok {
+			for i, f := range list {
+				if path, ok := f.(string); ok {
+					list[i] = map[string]any{"path": path}
+				}
+			}
+		}
+	},
+}
+
+func normalizeFormat(f string) string {
+	f = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(f), "."))
+	switch f {
+	case "jpeg":
+		return "jpg"
+	case "tif":
+		return "tiff"
+	}
+	return f
+}
+
+// upgrade migrates the config document in data to CurrentVersion. Files
+// without a version field are version 0.
+func upgrade(path string, data []byte) ([]byte, error) {
+	var doc map[string]any
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, decodeError(path, data, err)
+	}
+
+	version := 0
+	if v, ok := doc["version"]; ok {
+		n, ok := v.(float64)
+		if !ok || n != float64(int(n)) || n < 0 {
+			return nil, fmt.Errorf("%s: version must be a whole number", path)
+		}
+		version = int(n)
+	}
+	if version > CurrentVersion {
+		return nil, fmt.Errorf("%s: config version %d is newer than this build of photon supports (%d)", path, version, CurrentVersion)
+	}
+
+	for _, migrate := range migrations[version:] {
+		migrate(doc)
+	}
+	doc["version"] = CurrentVersion
+	return json.Marshal(doc)
+}
+
+// decodeError points JSON errors at the offending line, column or key.
+func decodeError(path string, data []byte, err error) error {
+	var syntax *json.SyntaxError
+	if errors.As(err, &syntax) {
+		line, col := position(data, syntax.Offset)
+		return fmt.Errorf("%s:%d:%d: %v", path, line, col, syntax)
+	}
+	var typ *json.UnmarshalTypeError
+	if errors.As(err, &typ) && typ.Field != "" {
+		return fmt.Errorf("%s: %s must be %s, not %s", path, typ.Field, typeName(typ.Type.Kind().String()), typ.Value)
+	}
+	return fmt.Errorf("%s: %w", path, err)
+}
+
+func typeName(kind string) string {
+	switch kind {
+	case "int":
+		return "a number"
+	case "bool":
+		return "true or false"
+	case "slice":
+		return "a list"
+	case "map", "struct":
+		return "an object"
+	}
+	return "a " + kind
+}
+
+func position(data []byte, offset int64) (line, col int) {
+	line, col = 1, 1
+	for i := int64(0); i < offset-1 && i < int64(len(data)); i++ {
+		if data[i] == '\n' {
+			line++
+			col = 1
+		} else {
+			col++
+		}
+	}
+	return line, col
+}
@@ -0,0 +1,108 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// ProjectFiles are the names of project config files, in lookup order.
+var ProjectFiles = []string{".photon.json", "photon.toml"}
+
+// SourceProject marks settings from a project config file. It ranks
+// between SourceFile and SourceEnv.
+const SourceProject = "project"
+
+// FindProject walks up from dir to the first directory holding one of
+// ProjectFiles and returns that file's path.
+func FindProject(dir string) (string, bool) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", false
+	}
+	for {
+		for _, name := range ProjectFiles {
+			path := filepath.Join(dir, name)
+			if info, err := os.Stat(path); err == nil && !info.IsDir() {
+				return path, true
+			}
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", false
+		}
+		dir = parent
+	}
+}
+
+// LoadProject merges the project config found from dir over c and returns
+// its path, or "" when there is none. A relative output_dir is resolved
+// against the project file's directory. Save keeps project settings out of
+// the user config.
+func (c *Config) LoadProject(dir string) (string, error) {
+	path, ok := FindProject(dir)
+	if !ok {
+		return "", nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("read project config: %w", err)
+	}
+	if strings.HasSuffix(path, ".toml") {
+		var doc map[string]any
+		if err := toml.Unmarshal(data, &doc); err != nil {
+			return "", fmt.Errorf("parse project config %s: %w", path, err)
+		}
+		if data, err = json.Marshal(doc); err != nil {
+			return "", fmt.Errorf("parse project config %s: %w", path, err)
+		}
+	}
+
+	upgraded, err := upgrade(path, data)
+	if err != nil {
+		return "", err
+	}
+
+	user := *c
+	user.Presets = maps.Clone(c.Presets)
+	user.Keys = maps.Clone(c.Keys)
+
+	if err := json.Unmarshal(upgraded, c); err != nil {
+		return "", decodeError(path, data, err)
+	}
+	if c.OutputDir != user.OutputDir && c.OutputDir != "" && !filepath.IsAbs(c.OutputDir) {
+		c.OutputDir = filepath.Join(filepath.Dir(path), c.OutputDir)
+	}
+
+	c.user = &user
+	c.markSources(data, SourceProject)
+	c.ProjectPath = path
+	return path, nil
+}
+
+// userSettings returns c with the settings a project overrode reverted to
+// their user config values.
+func (c *Config) userSettings() Config {
+	out := *c
+	if c.user == nil {
+		return out
+	}
+
+	dst := reflect.ValueOf(&out).Elem()
+	src := reflect.ValueOf(c.user).Elem()
+	t := dst.Type()
+	for i := 0; i < t.NumField(); i++ {
+		key, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if key != "" && key != "-" && c.Source(key) == SourceProject {
+			dst.Field(i).Set(src.Field(i))
+		}
+	}
+	return out
+}
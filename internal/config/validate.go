@@ -0,0 +1,136 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/mahamedmuse/photon/internal/image"
+)
+
+type FieldError struct {
+	Key string
+	Msg string
+}
+
+// ValidationError lists every invalid setting.
+type ValidationError []FieldError
+
+func (e ValidationError) Error() string {
+	var b strings.Builder
+	b.WriteString("invalid config:")
+	for _, f := range e {
+		fmt.Fprintf(&b, "\n  %s: %s", f.Key, f.Msg)
+	}
+	return b.String()
+}
+
+// Has reports whether key is among the invalid settings.
+func (e ValidationError) Has(key string) bool {
+	for _, f := range e {
+		if f.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+// Without returns the errors for every setting but key, or nil when there
+// are none left.
+func (e ValidationError) Without(key string) error {
+	var rest ValidationError
+	for _, f := range e {
+		if f.Key != key {
+			rest = append(rest, f)
+		}
+	}
+	if len(rest) > 0 {
+		return rest
+	}
+	return nil
+}
+
+// Validate checks every setting and returns a ValidationError naming each
+// invalid one and where its value came from.
+func (c *Config) Validate() error {
+	var errs ValidationError
+	add := func(key, format string, args ...any) {
+		msg := fmt.Sprintf(format, args...)
+		if src := c.Source(key); src != SourceDefault {
+			msg += " (from " + src + ")"
+		}
+		errs = append(errs, FieldError{Key: key, Msg: msg})
+	}
+
+	if c.DefaultQuality < 1 || c.DefaultQuality > 100 {
+		add("default_quality", "must be between 1 and 100, got %d", c.DefaultQuality)
+	}
+	if err := validFormat(c.DefaultFormat); err != nil {
+		add("default_format", "%v", err)
+	}
+	for _, f := range c.FavoriteFormats {
+		if err := validFormat(f); err != nil {
+			add("favorite_formats", "%v", err)
+			break
+		}
+	}
+	if err := dirPath(c.OutputDir); err != nil {
+		add("output_dir", "%v", err)
+	}
+	if err := image.NameTemplate(c.NameTemplate).Validate(); err != nil {
+		add("name_template", "%v", err)
+	}
+	if !slices.Contains(Themes, c.Theme) {
+		add("theme", "unknown theme %q, want one of %s", c.Theme, strings.Join(Themes, ", "))
+	}
+	if c.HistoryLimit < 0 {
+		add("history_limit", "must not be negative, got %d", c.HistoryLimit)
+	}
+	for _, name := range c.PresetNames() {
+		if p, ok := c.Presets[name]; ok {
+			if err := p.Validate(); err != nil {
+				add("presets", "%s: %v", name, err)
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func validFormat(f string) error {
+	if f == "" {
+		return fmt.Errorf("must not be empty")
+	}
+	if _, err := image.FormatFromExtension("." + f); err != nil {
+		return fmt.Errorf("unknown format %q", f)
+	}
+	return nil
+}
+
+// dirPath checks that dir, or the closest existing parent it would be
+// created in, is a writable directory. It doesn't write anything.
+func dirPath(dir string) error {
+	if dir == "" {
+		return fmt.Errorf("must not be empty")
+	}
+	dir = filepath.Clean(dir)
+	for {
+		info, err := os.Stat(dir)
+		if err == nil {
+			if !info.IsDir() {
+				return fmt.Errorf("%s is not a directory", dir)
+			}
+			return writable(dir)
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return fmt.Errorf("no existing parent directory")
+		}
+		dir = parent
+	}
+}
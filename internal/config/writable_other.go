@@ -0,0 +1,9 @@
+//go:build !unix
+
+package config
+
+// writable can't tell from permissions whether dir is writable here, so
+// it's found out when the directory is used.
+func writable(dir string) error {
+	return nil
+}
@@ -0,0 +1,17 @@
+//go:build unix
+
+package config
+
+import (
+	"fmt"
+
+	"golang.org/x/sys/unix"
+)
+
+// writable checks that files can be created in dir without creating one.
+func writable(dir string) error {
+	if err := unix.Access(dir, unix.W_OK|unix.X_OK); err != nil {
+		return fmt.Errorf("%s is not writable: %w", dir, err)
+	}
+	return nil
+}
@@ -0,0 +1,544 @@
+// Package history keeps an append-only log of conversions so they can be
+// browsed, run again and undone.
+package history
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mahamedmuse/photon/internal/config"
+	"github.com/mahamedmuse/photon/internal/image"
+	"github.com/mahamedmuse/photon/internal/recipe"
+)
+
+// FileName is the log kept in Dir.
+const FileName = "history.jsonl"
+
+// File is one output written by a run.
+type File struct {
+	Input  string `json:"input"`
+	Output string `json:"output"`
+	// Size is the size of the written output, used to tell whether it has
+	// changed since.
+	Size int64 `json:"size,omitempty"`
+	// Backup is a copy of the file the output replaced.
+	Backup string `json:"backup,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Options are the conversion settings a run used.
+type Options struct {
+	Quality      int                `json:"quality,omitempty"`
+	Lossless     bool               `json:"lossless,omitempty"`
+	Width        int                `json:"width,omitempty"`
+	Height       int                `json:"height,omitempty"`
+	Metadata     string             `json:"metadata,omitempty"`
+	Incremental  bool               `json:"incremental,omitempty"`
+	NameTemplate image.NameTemplate `json:"name_template,omitempty"`
+	OnConflict   string             `json:"on_conflict,omitempty"`
+}
+
+// Apply sets opts' conversion settings to o.
+func (o Options) Apply(opts image.Options) image.Options {
+	opts.Quality = o.Quality
+	opts.Lossless = o.Lossless
+	opts.Width = o.Width
+	opts.Height = o.Height
+	opts.Metadata = o.Metadata
+	opts.Incremental = o.Incremental
+	opts.NameTemplate = o.NameTemplate
+	opts.OnConflict = o.OnConflict
+	return opts
+}
+
+// Entry is one record in the log: a run, or the undoing of one.
+type Entry struct {
+	ID      string    `json:"id"`
+	Time    time.Time `json:"time"`
+	Command string    `json:"command"`
+	// Recipe is the recipe file a "run" entry ran.
+	Recipe  string  `json:"recipe,omitempty"`
+	Options Options `json:"options"`
+	Files   []File  `json:"files,omitempty"`
+	// Undoes is the ID of the entry an "undo" entry undid.
+	Undoes string `json:"undoes,omitempty"`
+
+	// Undone is set by Load on entries whose every file has been undone.
+	Undone bool `json:"-"`
+	// undone holds the outputs already undone, set by Load.
+	undone map[string]bool
+}
+
+// Failed returns the number of files the run failed to write.
+func (e Entry) Failed() int {
+	n := 0
+	for _, f := range e.Files {
+		if f.Error != "" {
+			n++
+		}
+	}
+	return n
+}
+
+// Log is the history file and the directory holding its backups.
+type Log struct {
+	Path string
+	// Limit is the number of runs kept: saving a run drops the oldest ones
+	// beyond it, with their backups. Zero keeps every run.
+	Limit int
+	// Off makes Record record nothing; the log can still be read and
+	// undone from.
+	Off bool
+}
+
+// Dir returns the directory holding the log and its backups: the config
+// directory, next to config.json.
+func Dir() (string, error) {
+	path, err := config.Path()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Dir(path), nil
+}
+
+// Open returns the log in Dir, set up by the history and history_limit
+// settings of cfg.
+func Open(cfg config.Config) (*Log, error) {
+	dir, err := Dir()
+	if err != nil {
+		return nil, err
+	}
+	l := In(dir)
+	l.Limit = cfg.HistoryLimit
+	l.Off = !cfg.History
+	return l, nil
+}
+
+// In returns the log kept in dir.
+func In(dir string) *Log {
+	return &Log{Path: filepath.Join(dir, FileName)}
+}
+
+func (l *Log) backupDir(id string) string {
+	return filepath.Join(filepath.Dir(l.Path), "backups", id)
+}
+
+// Load returns the runs in the log, oldest first, with Undone set on those
+// that have been undone. Undo records themselves are left out, as are
+// lines that can't be parsed, e.g. one cut short by a crash. An undo
+// record lists the files it undid, so a run is undone once its files are.
+func (l *Log) Load() ([]Entry, error) {
+	f, err := os.Open(l.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("open history: %w", err)
+	}
+	defer f.Close()
+
+	var entries []Entry
+	undone := make(map[string]map[string]bool)
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for sc.Scan() {
+		var e Entry
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			continue
+		}
+		if e.Undoes != "" {
+			if undone[e.Undoes] == nil {
+				undone[e.Undoes] = make(map[string]bool)
+			}
+			for _, f := range e.Files {
+				undone[e.Undoes][f.Output] = true
+			}
+			continue
+		}
+		entries = append(entries, e)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("read history: %w", err)
+	}
+	for i := range entries {
+		e := &entries[i]
+		e.undone = undone[e.ID]
+		e.Undone = e.undone != nil && !slices.ContainsFunc(e.Files, func(f File) bool { return !e.undone[f.Output] })
+	}
+	return entries, nil
+}
+
+// Find returns the run whose ID is id or starts with it. "last" is the most
+// recent run.
+func (l *Log) Find(id string) (Entry, error) {
+	entries, err := l.Load()
+	if err != nil {
+		return Entry{}, err
+	}
+	if id == "last" {
+		if len(entries) == 0 {
+			return Entry{}, fmt.Errorf("history is empty")
+		}
+		return entries[len(entries)-1], nil
+	}
+	var found []Entry
+	for _, e := range entries {
+		if e.ID == id {
+			return e, nil
+		}
+		if strings.HasPrefix(e.ID, id) {
+			found = append(found, e)
+		}
+	}
+	switch len(found) {
+	case 0:
+		return Entry{}, fmt.Errorf("no history entry %q", id)
+	case 1:
+		return found[0], nil
+	}
+	return Entry{}, fmt.Errorf("history entry %q is ambiguous (%d matches)", id, len(found))
+}
+
+func (l *Log) append(e Entry) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(l.Path), 0755); err != nil {
+		return fmt.Errorf("create history directory: %w", err)
+	}
+	f, err := os.OpenFile(l.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("open history: %w", err)
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return fmt.Errorf("write history: %w", err)
+	}
+	return f.Close()
+}
+
+func newID(t time.Time) string {
+	b := make([]byte, 2)
+	rand.Read(b)
+	return t.Format("20060102-150405") + "-" + hex.EncodeToString(b)
+}
+
+// Recorder collects the results of one run for the log.
+type Recorder struct {
+	log     *Log
+	mu      sync.Mutex
+	entry   Entry
+	backups map[string]string
+}
+
+// Record starts recording the run described by e. It returns opts set up to
+// record every result before passing it on to opts.Reporter, and to back
+// up outputs that are about to be replaced. Call Save when the run ends.
+// A nil or Off Log records nothing and returns a nil Recorder, which is
+// safe to use.
+func (l *Log) Record(e Entry, opts image.Options) (*Recorder, image.Options) {
+	if l == nil || l.Off {
+		return nil, opts
+	}
+	e.Time = time.Now()
+	e.ID = newID(e.Time)
+	e.Options = Options{
+		Quality:      opts.Quality,
+		Lossless:     opts.Lossless,
+		Width:        opts.Width,
+		Height:       opts.Height,
+		Metadata:     opts.Metadata,
+		Incremental:  opts.Incremental,
+		NameTemplate: opts.NameTemplate,
+		OnConflict:   opts.OnConflict,
+	}
+	r := &Recorder{log: l, entry: e, backups: make(map[string]string)}
+	opts.Reporter = r.Wrap(opts.Reporter)
+	opts.BeforeWrite = r.backup
+	return r, opts
+}
+
+// Wrap returns a reporter that records each result and then passes it on
+// to next, which may be nil.
+func (r *Recorder) Wrap(next image.Reporter) image.Reporter {
+	if r == nil {
+		return next
+	}
+	return &recordingReporter{r: r, next: next}
+}
+
+// backup copies path, if it exists, into the run's backup directory.
+func (r *Recorder) backup(path string) error {
+	info, err := os.Stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.backups[path]; ok {
+		return nil
+	}
+	dir := r.log.backupDir(r.entry.ID)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("create backup directory: %w", err)
+	}
+	dst := filepath.Join(dir, fmt.Sprintf("%d-%s", len(r.backups), filepath.Base(path)))
+	if err := copyFile(path, dst, info); err != nil {
+		return fmt.Errorf("back up %s: %w", path, err)
+	}
+	r.backups[path] = dst
+	return nil
+}
+
+func (r *Recorder) add(res image.Result) {
+	if res.Skipped {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	// Record absolute paths so the entry can be rerun and undone from
+	// anywhere.
+	f := File{Input: abs(res.Input), Output: abs(res.Output), Size: res.BytesOut, Backup: r.backups[res.Output]}
+	if res.Err != nil {
+		f.Error = res.Err.Error()
+	}
+	r.entry.Files = append(r.entry.Files, f)
+}
+
+// Save appends the run to the log unless it wrote nothing, then drops the
+// runs beyond the log's Limit.
+func (r *Recorder) Save() error {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.entry.Files) == 0 {
+		os.RemoveAll(r.log.backupDir(r.entry.ID))
+		return nil
+	}
+	if err := r.log.append(r.entry); err != nil {
+		return err
+	}
+	return r.log.prune()
+}
+
+// prune rewrites the log without the oldest runs beyond l.Limit, their
+// undo records and lines that can't be parsed, and deletes their backups.
+func (l *Log) prune() error {
+	if l.Limit <= 0 {
+		return nil
+	}
+	data, err := os.ReadFile(l.Path)
+	if err != nil {
+		return fmt.Errorf("read history: %w", err)
+	}
+
+	lines := strings.SplitAfter(string(data), "\n")
+	entries := make([]Entry, len(lines))
+	var runs []string
+	for i, line := range lines {
+		if json.Unmarshal([]byte(line), &entries[i]) == nil && entries[i].Undoes == "" {
+			runs = append(runs, entries[i].ID)
+		}
+	}
+	if len(runs) <= l.Limit {
+		return nil
+	}
+	dropped := make(map[string]bool)
+	for _, id := range runs[:len(runs)-l.Limit] {
+		dropped[id] = true
+	}
+
+	var kept strings.Builder
+	for i, line := range lines {
+		if e := entries[i]; e.ID != "" && !dropped[e.ID] && !dropped[e.Undoes] {
+			kept.WriteString(line)
+		}
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(l.Path), ".history-*.jsonl")
+	if err != nil {
+		return fmt.Errorf("write history: %w", err)
+	}
+	_, err = tmp.WriteString(kept.String())
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), l.Path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("write history: %w", err)
+	}
+	for id := range dropped {
+		os.RemoveAll(l.backupDir(id))
+	}
+	return nil
+}
+
+func abs(path string) string {
+	if p, err := filepath.Abs(path); err == nil {
+		return p
+	}
+	return path
+}
+
+type recordingReporter struct {
+	r    *Recorder
+	next image.Reporter
+}
+
+func (t *recordingReporter) Report(res image.Result) {
+	t.r.add(res)
+	if t.next != nil {
+		t.next.Report(res)
+	}
+}
+
+func (t *recordingReporter) Summary(s image.Summary) {
+	if t.next != nil {
+		t.next.Summary(s)
+	}
+}
+
+func (t *recordingReporter) Plan(p image.Plan) {
+	if t.next != nil {
+		t.next.Plan(p)
+	}
+}
+
+func (t *recordingReporter) Close() error {
+	if t.next != nil {
+		return t.next.Close()
+	}
+	return nil
+}
+
+// Rerun converts e's inputs again with its options, writing the same
+// outputs; a "run" entry runs its recipe again. opts supplies the reporter
+// and the rest of the settings.
+func Rerun(e Entry, opts image.Options) error {
+	opts = e.Options.Apply(opts)
+	if e.Recipe != "" {
+		r, err := recipe.Load(e.Recipe)
+		if err != nil {
+			return err
+		}
+		return recipe.Run(r, opts)
+	}
+	jobs := make([]image.Job, len(e.Files))
+	for i, f := range e.Files {
+		jobs[i] = image.Job{Input: f.Input, Output: f.Output}
+	}
+	return image.ConvertJobs(jobs, opts)
+}
+
+// Undo removes the outputs e created and restores the files it replaced.
+// Outputs changed since the run, or that can't be put back, are left alone
+// and reported in the error. The files that were undone are recorded in
+// the log, so undoing e again retries only the others; e's backups are
+// kept until every file is undone.
+func (l *Log) Undo(e Entry) error {
+	if e.Undone {
+		return fmt.Errorf("history entry %s was already undone", e.ID)
+	}
+
+	undo := Entry{Time: time.Now(), Command: "undo", Undoes: e.ID}
+	undo.ID = newID(undo.Time)
+	var problems []string
+	for _, f := range e.Files {
+		if e.undone[f.Output] {
+			continue
+		}
+		if err := undoFile(f); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", f.Output, err))
+			continue
+		}
+		undo.Files = append(undo.Files, File{Input: f.Input, Output: f.Output})
+	}
+	if len(undo.Files) > 0 {
+		if err := l.append(undo); err != nil {
+			return err
+		}
+	}
+	if len(problems) == 0 {
+		os.RemoveAll(l.backupDir(e.ID))
+		return nil
+	}
+	return fmt.Errorf("could not undo %d of %d files:\n%s", len(problems), len(e.Files), strings.Join(problems, "\n"))
+}
+
+func undoFile(f File) error {
+	info, err := os.Stat(f.Output)
+	exists := err == nil
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	if f.Error != "" {
+		// A failed write removes its partial output, taking the replaced
+		// file with it.
+		if f.Backup != "" && !exists {
+			return restore(f.Backup, f.Output)
+		}
+		return nil
+	}
+	if exists && info.Size() != f.Size {
+		return fmt.Errorf("changed since it was written")
+	}
+	if f.Backup != "" {
+		return restore(f.Backup, f.Output)
+	}
+	if exists {
+		return os.Remove(f.Output)
+	}
+	return nil
+}
+
+func restore(backup, path string) error {
+	if err := os.Rename(backup, path); err == nil {
+		return nil
+	}
+	info, err := os.Stat(backup)
+	if err != nil {
+		return fmt.Errorf("read backup: %w", err)
+	}
+	return copyFile(backup, path, info)
+}
+
+func copyFile(src, dst string, info os.FileInfo) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	return os.Chtimes(dst, info.ModTime(), info.ModTime())
+}
@@ -0,0 +1,262 @@
+package history
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mahamedmuse/photon/internal/image"
+	"github.com/mahamedmuse/photon/internal/testutil"
+)
+
+func testLog(t *testing.T) *Log {
+	return &Log{Path: filepath.Join(t.TempDir(), FileName)}
+}
+
+// convert records converting inputs to outputs as one run.
+func convert(t *testing.T, l *Log, jobs ...image.Job) Entry {
+	t.Helper()
+	rec, opts := l.Record(Entry{Command: "batch"}, image.Options{Quality: 80})
+	image.ConvertJobs(jobs, opts)
+	if err := rec.Save(); err != nil {
+		t.Fatal(err)
+	}
+	e, err := l.Find("last")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return e
+}
+
+func TestRecordAndUndo(t *testing.T) {
+	dir := t.TempDir()
+	l := testLog(t)
+	in := filepath.Join(dir, "a.png")
+	testutil.WritePNG(t, in, 4, 4)
+	created := filepath.Join(dir, "a.jpg")
+	replaced := filepath.Join(dir, "b.jpg")
+	os.WriteFile(replaced, []byte("original"), 0644)
+
+	e := convert(t, l, image.Job{Input: in, Output: created}, image.Job{Input: in, Output: replaced})
+	if e.Command != "batch" || e.Options.Quality != 80 || len(e.Files) != 2 {
+		t.Fatalf("entry = %+v", e)
+	}
+	if e.Files[0].Backup != "" || e.Files[1].Backup == "" {
+		t.Fatalf("backups = %q, %q", e.Files[0].Backup, e.Files[1].Backup)
+	}
+	if data, _ := os.ReadFile(replaced); string(data) == "original" {
+		t.Fatal("output was not replaced")
+	}
+
+	if err := l.Undo(e); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(created); !os.IsNotExist(err) {
+		t.Errorf("created output still exists: %v", err)
+	}
+	if data, _ := os.ReadFile(replaced); string(data) != "original" {
+		t.Errorf("replaced output = %q, want the original", data)
+	}
+
+	entries, err := l.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || !entries[0].Undone {
+		t.Fatalf("entries = %+v, want one undone entry", entries)
+	}
+	if err := l.Undo(entries[0]); err == nil {
+		t.Error("undoing twice succeeded")
+	}
+}
+
+func TestUndoSkipsChangedOutputs(t *testing.T) {
+	dir := t.TempDir()
+	l := testLog(t)
+	in := filepath.Join(dir, "a.png")
+	testutil.WritePNG(t, in, 4, 4)
+	out := filepath.Join(dir, "a.jpg")
+
+	e := convert(t, l, image.Job{Input: in, Output: out})
+	os.WriteFile(out, []byte("edited since"), 0644)
+
+	err := l.Undo(e)
+	if err == nil || !strings.Contains(err.Error(), "changed since") {
+		t.Fatalf("Undo error = %v", err)
+	}
+	if data, _ := os.ReadFile(out); string(data) != "edited since" {
+		t.Errorf("changed output was touched: %q", data)
+	}
+}
+
+func TestUndoRetriesFailedFiles(t *testing.T) {
+	dir := t.TempDir()
+	l := testLog(t)
+	in := filepath.Join(dir, "a.png")
+	testutil.WritePNG(t, in, 4, 4)
+	var jobs []image.Job
+	for _, name := range []string{"a.jpg", "b.jpg"} {
+		out := filepath.Join(dir, name)
+		os.WriteFile(out, []byte("original "+name), 0644)
+		jobs = append(jobs, image.Job{Input: in, Output: out})
+	}
+	e := convert(t, l, jobs...)
+
+	// Without its backup b.jpg can't be restored.
+	backup := e.Files[1].Backup
+	saved, _ := os.ReadFile(backup)
+	os.Remove(backup)
+
+	if err := l.Undo(e); err == nil || !strings.Contains(err.Error(), "b.jpg") {
+		t.Fatalf("Undo error = %v, want b.jpg's", err)
+	}
+	if data, _ := os.ReadFile(jobs[0].Output); string(data) != "original a.jpg" {
+		t.Errorf("a.jpg = %q, want it restored", data)
+	}
+	e, _ = l.Find(e.ID)
+	if e.Undone {
+		t.Fatal("entry marked undone with b.jpg still converted")
+	}
+
+	// Once the backup is back, a retry restores b.jpg and leaves a.jpg alone.
+	os.WriteFile(backup, saved, 0644)
+	if err := l.Undo(e); err != nil {
+		t.Fatal(err)
+	}
+	for i, job := range jobs {
+		if data, _ := os.ReadFile(job.Output); string(data) != "original "+filepath.Base(job.Output) {
+			t.Errorf("file %d = %q, want the original", i, data)
+		}
+	}
+	if e, _ = l.Find(e.ID); !e.Undone {
+		t.Error("entry not undone after the retry")
+	}
+	if _, err := os.Stat(filepath.Dir(backup)); !os.IsNotExist(err) {
+		t.Errorf("backups kept after a full undo: %v", err)
+	}
+}
+
+func TestRecordKeepsOptions(t *testing.T) {
+	dir := t.TempDir()
+	l := testLog(t)
+	in := filepath.Join(dir, "a.png")
+	testutil.WritePNG(t, in, 4, 4)
+
+	want := image.Options{Quality: 70, Width: 2, Metadata: image.MetadataKeep, Incremental: true, NameTemplate: "{name}-2.{ext}", OnConflict: image.ConflictOverwrite}
+	rec, opts := l.Record(Entry{Command: "batch"}, want)
+	image.ConvertJobs([]image.Job{{Input: in, Output: filepath.Join(dir, "a.jpg")}}, opts)
+	rec.Save()
+
+	e, err := l.Find("last")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := e.Options.Apply(image.Options{}); !reflect.DeepEqual(got, want) {
+		t.Errorf("recorded options = %+v, want %+v", got, want)
+	}
+}
+
+func TestSaveSkipsEmptyRuns(t *testing.T) {
+	l := testLog(t)
+	rec, opts := l.Record(Entry{Command: "convert"}, image.Options{DryRun: true})
+	image.Convert("missing.png", "missing.jpg", opts)
+	if err := rec.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(l.Path); !os.IsNotExist(err) {
+		t.Errorf("dry run was recorded: %v", err)
+	}
+}
+
+func TestFind(t *testing.T) {
+	dir := t.TempDir()
+	l := testLog(t)
+	in := filepath.Join(dir, "a.png")
+	testutil.WritePNG(t, in, 4, 4)
+	first := convert(t, l, image.Job{Input: in, Output: filepath.Join(dir, "a.jpg")})
+	last := convert(t, l, image.Job{Input: in, Output: filepath.Join(dir, "a.gif")})
+
+	if e, err := l.Find(first.ID); err != nil || e.ID != first.ID {
+		t.Errorf("Find(%s) = %s, %v", first.ID, e.ID, err)
+	}
+	if e, err := l.Find("last"); err != nil || e.ID != last.ID {
+		t.Errorf("Find(last) = %s, %v", e.ID, err)
+	}
+	if _, err := l.Find("20"); err == nil || !strings.Contains(err.Error(), "ambiguous") {
+		t.Errorf("Find(20) error = %v, want ambiguous", err)
+	}
+	if _, err := l.Find("nope"); err == nil {
+		t.Error("Find(nope) succeeded")
+	}
+}
+
+func TestRerun(t *testing.T) {
+	dir := t.TempDir()
+	l := testLog(t)
+	in := filepath.Join(dir, "a.png")
+	testutil.WritePNG(t, in, 4, 4)
+	out := filepath.Join(dir, "a.jpg")
+	e := convert(t, l, image.Job{Input: in, Output: out})
+	os.Remove(out)
+
+	rec, opts := l.Record(Entry{Command: "rerun"}, e.Options.Apply(image.Options{}))
+	if err := Rerun(e, opts); err != nil {
+		t.Fatal(err)
+	}
+	rec.Save()
+	if _, err := os.Stat(out); err != nil {
+		t.Fatalf("output not rewritten: %v", err)
+	}
+	again, _ := l.Find("last")
+	if again.Command != "rerun" || again.Options.Quality != 80 || len(again.Files) != 1 {
+		t.Errorf("rerun entry = %+v", again)
+	}
+}
+
+func TestLimitDropsOldRuns(t *testing.T) {
+	dir := t.TempDir()
+	l := testLog(t)
+	l.Limit = 2
+	in := filepath.Join(dir, "a.png")
+	testutil.WritePNG(t, in, 4, 4)
+	out := filepath.Join(dir, "a.jpg")
+
+	// Each run replaces the last one's output, so each is backed up.
+	var runs []Entry
+	for range 3 {
+		runs = append(runs, convert(t, l, image.Job{Input: in, Output: out}))
+	}
+	if err := l.Undo(runs[1]); err != nil {
+		t.Fatal(err)
+	}
+	runs = append(runs, convert(t, l, image.Job{Input: in, Output: out}))
+
+	entries, err := l.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[0].ID != runs[2].ID || entries[1].ID != runs[3].ID {
+		t.Fatalf("entries = %+v, want the last two runs", entries)
+	}
+	if data, _ := os.ReadFile(l.Path); strings.Contains(string(data), runs[1].ID) {
+		t.Error("the undo record of a dropped run was kept")
+	}
+	for _, e := range runs[:2] {
+		if _, err := os.Stat(l.backupDir(e.ID)); !os.IsNotExist(err) {
+			t.Errorf("backups of dropped run %s kept: %v", e.ID, err)
+		}
+	}
+	if _, err := os.Stat(entries[1].Files[0].Backup); err != nil {
+		t.Errorf("backup of a kept run removed: %v", err)
+	}
+}
+
+func TestOffRecordsNothing(t *testing.T) {
+	l := testLog(t)
+	l.Off = true
+	if rec, _ := l.Record(Entry{Command: "convert"}, image.Options{}); rec != nil {
+		t.Error("Record returned a recorder")
+	}
+}
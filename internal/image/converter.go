@@ -2,14 +2,56 @@ package image
 
 import (
 	"fmt"
+	"image"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
+)
+
+const (
+	MetadataStrip = "strip"
+	MetadataKeep  = "keep"
 )
 
 type Options struct {
 	Quality  int
 	Lossless bool
+
+	// Width and Height bound the output size, see Resize. Zero leaves the
+	// dimension unconstrained.
+	Width  int
+	Height int
+
+	// Metadata is MetadataStrip (the default) or MetadataKeep, which copies
+	// the source's EXIF block into JPEG outputs.
+	Metadata string
+
+	// DryRun reports the resolved plan instead of converting anything.
+	DryRun bool
+
+	// Incremental makes ConvertBatch skip outputs that are up to date
+	// according to the manifest kept in the output directory.
+	Incremental bool
+
+	// OutputDir receives batch outputs along with the manifest and journal.
+	// Empty means the input directory.
+	OutputDir string
+
+	// NameTemplate names batch outputs, see NameTemplate for placeholders.
+	NameTemplate NameTemplate
+	// OnConflict is ConflictOverwrite (the default) or ConflictRename.
+	OnConflict string
+
+	// Reporter receives a Result for every converted file. A nil Reporter
+	// keeps the conversion silent.
+	Reporter Reporter
+
+	// BeforeWrite, if set, is called with each output path just before the
+	// file is written, e.g. to back up a file about to be replaced. An
+	// error fails that file.
+	BeforeWrite func(path string) error
 }
 
 func DefaultOptions() Options {
@@ -20,62 +62,234 @@ func DefaultOptions() Options {
 }
 
 func Convert(inputPath, outputPath string, opts Options) error {
+	if opts.DryRun {
+		plan := PlanJobs([]Job{{Input: inputPath, Output: outputPath}})
+		reportPlan(opts.Reporter, plan)
+		return plan[0].Err
+	}
+
+	res := convert(inputPath, outputPath, opts)
+	report(opts.Reporter, res)
+	return res.Err
+}
+
+func convert(inputPath, outputPath string, opts Options) Result {
+	start := time.Now()
+	res := Result{Input: inputPath, Output: outputPath}
+	fail := func(err error) Result {
+		res.Err = err
+		res.Duration = time.Since(start)
+		return res
+	}
+
 	inputFile, err := os.Open(inputPath)
 	if err != nil {
-		return fmt.Errorf("open input file: %w", err)
+		return fail(fmt.Errorf("open input file: %w", err))
 	}
 	defer inputFile.Close()
 
+	if info, err := inputFile.Stat(); err == nil {
+		res.BytesIn = info.Size()
+	}
+
 	img, srcFormat, err := Decode(inputFile)
 	if err != nil {
-		return err
+		return fail(err)
 	}
+	res.SrcFormat = srcFormat
+	img = Resize(img, opts.Width, opts.Height)
+	res.Width = img.Bounds().Dx()
+	res.Height = img.Bounds().Dy()
 
 	dstFormat, err := FormatFromExtension(outputPath)
 	if err != nil {
-		return err
+		return fail(err)
 	}
+	res.DstFormat = dstFormat
 
 	if !IsSupported(dstFormat) {
-		return fmt.Errorf("output format not supported: %s", dstFormat)
+		return fail(fmt.Errorf("output format not supported: %s", dstFormat))
+	}
+
+	var exif []byte
+	if opts.Metadata == MetadataKeep && dstFormat == FormatJPEG {
+		exif = readEXIF(inputPath)
+	}
+
+	if opts.BeforeWrite != nil {
+		if err := opts.BeforeWrite(outputPath); err != nil {
+			return fail(err)
+		}
+	}
+
+	size, err := writeFile(outputPath, img, dstFormat, opts.Quality, exif)
+	if err != nil {
+		return fail(err)
 	}
+	res.BytesOut = size
+	res.Duration = time.Since(start)
+	return res
+}
+
+// WriteFile encodes img to path and returns the size of the written file.
+// A partially written file is removed when encoding fails.
+func WriteFile(path string, img image.Image, format Format, quality int) (int64, error) {
+	return writeFile(path, img, format, quality, nil)
+}
 
-	outputFile, err := os.Create(outputPath)
+// writeFile is WriteFile that also embeds exif, a TIFF-structured EXIF
+// block, into JPEG outputs.
+func writeFile(path string, img image.Image, format Format, quality int, exif []byte) (int64, error) {
+	f, err := os.Create(path)
 	if err != nil {
-		return fmt.Errorf("create output file: %w", err)
+		return 0, fmt.Errorf("create output file: %w", err)
 	}
-	defer outputFile.Close()
+	defer f.Close()
 
-	if err := Encode(outputFile, img, dstFormat, opts.Quality); err != nil {
-		os.Remove(outputPath)
-		return fmt.Errorf("encode image: %w", err)
+	var w io.Writer = f
+	if exif != nil && format == FormatJPEG {
+		w = &exifWriter{w: f, exif: exif}
 	}
 
-	fmt.Printf("Converted %s (%s) -> %s (%s)\n", inputPath, srcFormat, outputPath, dstFormat)
-	return nil
+	if err := Encode(w, img, format, quality); err != nil {
+		f.Close()
+		os.Remove(path)
+		return 0, fmt.Errorf("encode image: %w", err)
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
 }
 
 func ConvertBatch(dir string, fromExt, toExt string, opts Options) error {
-	fromExt = strings.TrimPrefix(strings.ToLower(fromExt), ".")
-	toExt = strings.TrimPrefix(strings.ToLower(toExt), ".")
+	jobs, err := BatchJobs(dir, fromExt, toExt, opts)
+	if err != nil {
+		return err
+	}
 
-	pattern := filepath.Join(dir, "*."+fromExt)
-	files, err := filepath.Glob(pattern)
+	outDir := opts.batchDir(dir)
+	if opts.DryRun {
+		return planBatch(outDir, jobs, opts)
+	}
+
+	if err := os.MkdirAll(outDir, 0755); err != nil {
+		return fmt.Errorf("create output directory: %w", err)
+	}
+	journal, err := CreateJournal(outDir, jobs, opts)
 	if err != nil {
-		return fmt.Errorf("glob pattern: %w", err)
+		return err
+	}
+	defer journal.Close()
+
+	return runBatch(outDir, jobs, opts, journal)
+}
+
+// ConvertJobs converts each job in turn, reporting every result and a
+// summary. Unlike ConvertBatch it keeps no manifest or journal.
+func ConvertJobs(jobs []Job, opts Options) error {
+	if opts.DryRun {
+		plan := PlanJobs(jobs)
+		reportPlan(opts.Reporter, plan)
+		return planError(plan)
 	}
+	opts.Incremental = false
+	return runBatch("", jobs, opts, nil)
+}
+
+func (o Options) batchDir(inputDir string) string {
+	if o.OutputDir != "" {
+		return o.OutputDir
+	}
+	return inputDir
+}
+
+// ResumeBatch continues the batch recorded in the journal at path, running
+// every job that hasn't completed with the options the batch started with.
+func ResumeBatch(path string, opts Options) error {
+	journal, err := OpenJournal(path)
+	if err != nil {
+		return err
+	}
+	defer journal.Close()
+
+	dir := filepath.Dir(path)
+	opts = journal.Options(opts)
+	jobs := journal.Pending()
 
-	if len(files) == 0 {
-		return fmt.Errorf("no .%s files found in %s", fromExt, dir)
+	if opts.DryRun {
+		return planBatch(dir, jobs, opts)
 	}
 
+	return runBatch(dir, jobs, opts, journal)
+}
+
+func planBatch(dir string, jobs []Job, opts Options) error {
+	plan := PlanJobs(jobs)
+	if opts.Incremental {
+		manifest, err := LoadManifest(dir)
+		if err != nil {
+			return err
+		}
+		for i := range plan {
+			plan[i].UpToDate = manifest.UpToDate(plan[i].Job, jobOptionsHash(plan[i].Job, opts))
+		}
+	}
+	reportPlan(opts.Reporter, plan)
+	return planError(plan)
+}
+
+func runBatch(dir string, jobs []Job, opts Options, journal *Journal) error {
+	var manifest *Manifest
+	if opts.Incremental {
+		var err error
+		if manifest, err = LoadManifest(dir); err != nil {
+			return err
+		}
+	}
+
+	start := time.Now()
+	var summary Summary
 	var errors []string
-	for _, inputPath := range files {
-		base := strings.TrimSuffix(filepath.Base(inputPath), "."+fromExt)
-		outputPath := filepath.Join(dir, base+"."+toExt)
+	for _, job := range jobs {
+		var optionsHash string
+		if manifest != nil {
+			optionsHash = jobOptionsHash(job, opts)
+			if manifest.UpToDate(job, optionsHash) {
+				res := Result{Input: job.Input, Output: job.Output, Skipped: true}
+				report(opts.Reporter, res)
+				summary.Add(res)
+				if err := journal.Record(job, nil); err != nil {
+					errors = append(errors, err.Error())
+				}
+				continue
+			}
+		}
 
-		if err := Convert(inputPath, outputPath, opts); err != nil {
-			errors = append(errors, fmt.Sprintf("%s: %v", inputPath, err))
+		res := convert(job.Input, job.Output, opts)
+		report(opts.Reporter, res)
+		summary.Add(res)
+		if err := journal.Record(job, res.Err); err != nil {
+			errors = append(errors, err.Error())
+		}
+		if res.Err != nil {
+			errors = append(errors, fmt.Sprintf("%s: %v", job.Input, res.Err))
+			continue
+		}
+		if manifest != nil {
+			if err := manifest.Record(job, optionsHash); err != nil {
+				errors = append(errors, fmt.Sprintf("%s: record manifest: %v", job.Input, err))
+			}
+		}
+	}
+	summary.Duration = time.Since(start)
+	reportSummary(opts.Reporter, summary)
+
+	if manifest != nil {
+		if err := manifest.Save(); err != nil {
+			errors = append(errors, err.Error())
 		}
 	}
 
@@ -83,6 +297,10 @@ func ConvertBatch(dir string, fromExt, toExt string, opts Options) error {
 		return fmt.Errorf("failed to convert %d files:\n%s", len(errors), strings.Join(errors, "\n"))
 	}
 
-	fmt.Printf("Batch complete: %d files converted\n", len(files))
 	return nil
 }
+
+func jobOptionsHash(job Job, opts Options) string {
+	format, _ := FormatFromExtension(job.Output)
+	return opts.Hash(format)
+}
@@ -1,8 +1,10 @@
 package image
 
 import (
+	"bytes"
 	"image"
 	"image/color"
+	"image/jpeg"
 	"image/png"
 	"os"
 	"path/filepath"
@@ -117,6 +119,29 @@ func TestConvertBatch(t *testing.T) {
 	}
 }
 
+func TestConvertBatchOutputDir(t *testing.T) {
+	tmpDir := t.TempDir()
+	outDir := filepath.Join(tmpDir, "out")
+	if err := createTestPNG(filepath.Join(tmpDir, "image.png"), 50, 50); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := DefaultOptions()
+	opts.OutputDir = outDir
+	if err := ConvertBatch(tmpDir, "png", "jpg", opts); err != nil {
+		t.Fatalf("ConvertBatch: %v", err)
+	}
+
+	for _, name := range []string{"image.jpg", JournalName} {
+		if _, err := os.Stat(filepath.Join(outDir, name)); err != nil {
+			t.Errorf("expected %s in the output directory", name)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, "image.jpg")); err == nil {
+		t.Error("output written next to the input")
+	}
+}
+
 func TestConvertBatchNoFiles(t *testing.T) {
 	tmpDir := t.TempDir()
 
@@ -201,3 +226,47 @@ func TestConvertAllFormats(t *testing.T) {
 		})
 	}
 }
+
+func TestConvertResizeAndMetadata(t *testing.T) {
+	tmpDir := t.TempDir()
+	srcPath := filepath.Join(tmpDir, "input.jpg")
+
+	var src bytes.Buffer
+	w := &exifWriter{w: &src, exif: testTIFF()}
+	if err := jpeg.Encode(w, createTestImage(200, 100, false), nil); err != nil {
+		t.Fatalf("encode source: %v", err)
+	}
+	if err := os.WriteFile(srcPath, src.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, metadata := range []string{MetadataStrip, MetadataKeep} {
+		t.Run(metadata, func(t *testing.T) {
+			dstPath := filepath.Join(tmpDir, metadata+".jpg")
+			opts := DefaultOptions()
+			opts.Width = 50
+			opts.Metadata = metadata
+			if err := Convert(srcPath, dstPath, opts); err != nil {
+				t.Fatalf("Convert: %v", err)
+			}
+
+			f, err := os.Open(dstPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+			cfg, _, err := DecodeConfig(f)
+			if err != nil {
+				t.Fatalf("decode output: %v", err)
+			}
+			if cfg.Width != 50 || cfg.Height != 25 {
+				t.Errorf("output is %dx%d, want 50x25", cfg.Width, cfg.Height)
+			}
+
+			_, ok := CaptureTime(dstPath)
+			if ok != (metadata == MetadataKeep) {
+				t.Errorf("capture time present = %v with metadata %s", ok, metadata)
+			}
+		})
+	}
+}
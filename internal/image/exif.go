@@ -0,0 +1,287 @@
+package image
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	tagDateTime          = 0x0132
+	tagStripOffsets      = 0x0111
+	tagStripByteCounts   = 0x0117
+	tagTileOffsets       = 0x0144
+	tagTileByteCounts    = 0x0145
+	tagSubIFDs           = 0x014A
+	tagJPEGOffset        = 0x0201
+	tagJPEGLength        = 0x0202
+	tagExifIFD           = 0x8769
+	tagGPSIFD            = 0x8825
+	tagInteropIFD        = 0xA005
+	tagDateTimeOriginal  = 0x9003
+	tagDateTimeDigitized = 0x9004
+
+	exifTypeASCII = 2
+	exifTypeLong  = 4
+)
+
+// exifTypeSizes is the size in bytes of one value of each TIFF field type.
+var exifTypeSizes = map[uint16]uint64{
+	1: 1, 2: 1, 3: 2, 4: 4, 5: 8, 6: 1, 7: 1, 8: 2, 9: 4, 10: 8, 11: 4, 12: 8, 13: 4,
+}
+
+// imageDataTags locate image data rather than describe it; they are left
+// out when the metadata is copied.
+var imageDataTags = map[uint16]bool{
+	tagStripOffsets: true, tagStripByteCounts: true,
+	tagTileOffsets: true, tagTileByteCounts: true,
+	tagSubIFDs: true, tagJPEGOffset: true, tagJPEGLength: true,
+}
+
+// subIFDTags point at the IFDs copied along with IFD0.
+var subIFDTags = map[uint16]bool{tagExifIFD: true, tagGPSIFD: true, tagInteropIFD: true}
+
+// CaptureTime returns the EXIF capture time of the image at path. It looks
+// for an embedded TIFF structure, which covers JPEG APP1 segments, TIFF
+// files and the Exif item of HEIC/AVIF containers.
+func CaptureTime(path string) (time.Time, bool) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return exifCaptureTime(data)
+}
+
+func exifCaptureTime(data []byte) (time.Time, bool) {
+	tiff := findTIFF(data)
+	if tiff == nil {
+		return time.Time{}, false
+	}
+
+	order := tiffOrder(tiff)
+	if order == nil {
+		return time.Time{}, false
+	}
+
+	ifd0 := readIFD(tiff, order, order.Uint32(tiff[4:8]))
+	if ptr, ok := ifd0[tagExifIFD]; ok && ptr.typ == exifTypeLong {
+		exif := readIFD(tiff, order, ptr.value(order))
+		for _, tag := range []uint16{tagDateTimeOriginal, tagDateTimeDigitized} {
+			if t, ok := exifTime(tiff, order, exif[tag]); ok {
+				return t, true
+			}
+		}
+	}
+	return exifTime(tiff, order, ifd0[tagDateTime])
+}
+
+// readEXIF returns the TIFF-structured EXIF block of the image at path, or
+// nil if it has none or it doesn't fit in a JPEG APP1 segment. A JPEG's
+// block is its APP1 segment; for TIFF files and HEIC/AVIF Exif items,
+// which aren't delimited the same way, only the metadata IFDs are copied.
+func readEXIF(path string) []byte {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	tiff := findTIFF(data)
+	if idx := bytes.Index(data, []byte("Exif\x00\x00")); idx >= 4 && data[0] == 0xFF && data[1] == 0xD8 {
+		n := int(binary.BigEndian.Uint16(data[idx-2:idx])) - 2 - 6
+		if n > 0 && n <= len(tiff) {
+			tiff = tiff[:n]
+		}
+	} else {
+		tiff = copyMetadata(tiff)
+	}
+	if len(tiff) > 0xFFFF-2-6 {
+		return nil
+	}
+	return tiff
+}
+
+// copyMetadata returns a TIFF structure holding IFD0 of tiff, without its
+// image data, and the Exif, GPS and interoperability IFDs it points to.
+// Only the values the IFDs reference are copied, so image data around
+// them, or whatever follows the structure, is left out.
+func copyMetadata(tiff []byte) []byte {
+	order := tiffOrder(tiff)
+	if order == nil {
+		return nil
+	}
+	c := &tiffCopier{src: tiff, order: order, out: append([]byte(nil), tiff[:4]...)}
+	c.out = append(c.out, 0, 0, 0, 0)
+	order.PutUint32(c.out[4:], 8)
+	c.ifd(order.Uint32(tiff[4:8]), 0)
+	return c.out
+}
+
+type tiffCopier struct {
+	src   []byte
+	order binary.ByteOrder
+	out   []byte
+}
+
+// ifd appends the IFD at offset in src, with its values and sub-IFDs, to
+// out and returns where it starts. IFD1 and later are left out.
+func (c *tiffCopier) ifd(offset uint32, depth int) uint32 {
+	start := uint32(len(c.out))
+	var entries [][]byte
+	if uint64(offset)+2 <= uint64(len(c.src)) {
+		n := int(c.order.Uint16(c.src[offset:]))
+		for i, pos := 0, int(offset)+2; i < n && pos+12 <= len(c.src); i, pos = i+1, pos+12 {
+			if e := c.src[pos : pos+12]; c.keep(e, depth) {
+				entries = append(entries, e)
+			}
+		}
+	}
+
+	c.out = append(c.out, make([]byte, 2+12*len(entries)+4)...)
+	c.order.PutUint16(c.out[start:], uint16(len(entries)))
+	for i, e := range entries {
+		at := int(start) + 2 + 12*i
+		copy(c.out[at:], e)
+		tag, size, value := c.order.Uint16(e), c.size(e), c.order.Uint32(e[8:])
+		switch {
+		case subIFDTags[tag]:
+			value = c.ifd(value, depth+1)
+		case size > 4:
+			off := uint32(len(c.out))
+			c.out = append(c.out, c.src[value:uint64(value)+size]...)
+			if len(c.out)%2 == 1 {
+				c.out = append(c.out, 0)
+			}
+			value = off
+		default:
+			continue
+		}
+		c.order.PutUint32(c.out[at+8:], value)
+	}
+	return start
+}
+
+// keep reports whether entry e of an IFD at depth is copied: it must
+// describe the image and its values must lie within src.
+func (c *tiffCopier) keep(e []byte, depth int) bool {
+	tag, value := c.order.Uint16(e), c.order.Uint32(e[8:])
+	if imageDataTags[tag] {
+		return false
+	}
+	if subIFDTags[tag] {
+		return depth < 2 && uint64(value)+2 <= uint64(len(c.src))
+	}
+	size := c.size(e)
+	return size > 0 && (size <= 4 || uint64(value)+size <= uint64(len(c.src)))
+}
+
+// size is the size in bytes of entry e's values, or 0 for unknown types.
+func (c *tiffCopier) size(e []byte) uint64 {
+	return exifTypeSizes[c.order.Uint16(e[2:])] * uint64(c.order.Uint32(e[4:]))
+}
+
+func tiffOrder(tiff []byte) binary.ByteOrder {
+	if len(tiff) < 8 {
+		return nil
+	}
+	switch string(tiff[:4]) {
+	case "II*\x00":
+		return binary.LittleEndian
+	case "MM\x00*":
+		return binary.BigEndian
+	}
+	return nil
+}
+
+// exifWriter inserts an APP1 EXIF segment right after the JPEG SOI marker.
+type exifWriter struct {
+	w       io.Writer
+	exif    []byte
+	written bool
+}
+
+func (e *exifWriter) Write(p []byte) (int, error) {
+	if e.written || len(p) < 2 {
+		return e.w.Write(p)
+	}
+	e.written = true
+
+	seg := []byte{0xFF, 0xE1, 0, 0}
+	binary.BigEndian.PutUint16(seg[2:], uint16(2+6+len(e.exif)))
+	seg = append(seg, "Exif\x00\x00"...)
+	seg = append(seg, e.exif...)
+
+	if _, err := e.w.Write(p[:2]); err != nil {
+		return 0, err
+	}
+	if _, err := e.w.Write(seg); err != nil {
+		return 0, err
+	}
+	n, err := e.w.Write(p[2:])
+	return n + 2, err
+}
+
+func findTIFF(data []byte) []byte {
+	if len(data) >= 8 && (string(data[:4]) == "II*\x00" || string(data[:4]) == "MM\x00*") {
+		return data
+	}
+	idx := bytes.Index(data, []byte("Exif\x00\x00"))
+	if idx < 0 || len(data) < idx+6+8 {
+		return nil
+	}
+	return data[idx+6:]
+}
+
+type ifdEntry struct {
+	typ   uint16
+	count uint32
+	raw   []byte
+}
+
+func (e ifdEntry) value(order binary.ByteOrder) uint32 {
+	return order.Uint32(e.raw)
+}
+
+func readIFD(tiff []byte, order binary.ByteOrder, offset uint32) map[uint16]ifdEntry {
+	entries := make(map[uint16]ifdEntry)
+	if int(offset)+2 > len(tiff) {
+		return entries
+	}
+
+	n := int(order.Uint16(tiff[offset:]))
+	pos := int(offset) + 2
+	for i := 0; i < n && pos+12 <= len(tiff); i++ {
+		e := tiff[pos : pos+12]
+		entries[order.Uint16(e[0:2])] = ifdEntry{
+			typ:   order.Uint16(e[2:4]),
+			count: order.Uint32(e[4:8]),
+			raw:   e[8:12],
+		}
+		pos += 12
+	}
+	return entries
+}
+
+func exifTime(tiff []byte, order binary.ByteOrder, e ifdEntry) (time.Time, bool) {
+	if e.typ != exifTypeASCII || e.count == 0 {
+		return time.Time{}, false
+	}
+
+	var s []byte
+	if e.count <= 4 {
+		s = e.raw[:e.count]
+	} else {
+		off := int(e.value(order))
+		if off < 0 || off+int(e.count) > len(tiff) {
+			return time.Time{}, false
+		}
+		s = tiff[off : off+int(e.count)]
+	}
+
+	t, err := time.ParseInLocation("2006:01:02 15:04:05", strings.TrimRight(string(s), "\x00 "), time.Local)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t, true
+}
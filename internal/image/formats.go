@@ -107,6 +107,41 @@ func Decode(r io.Reader) (image.Image, Format, error) {
 	return img, Format(format), nil
 }
 
+// DecodeConfig reads the format and dimensions of an image without decoding
+// its pixels.
+func DecodeConfig(r io.Reader) (image.Config, Format, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return image.Config{}, "", fmt.Errorf("read image data: %w", err)
+	}
+
+	if isHEIF(data) {
+		cfg, err := decodeHEIFConfig(data)
+		if err != nil {
+			return image.Config{}, "", err
+		}
+		format := FormatHEIC
+		if isAVIF(data) {
+			format = FormatAVIF
+		}
+		return cfg, format, nil
+	}
+
+	if isWebP(data) {
+		cfg, err := webp.DecodeConfig(bytes.NewReader(data))
+		if err != nil {
+			return image.Config{}, "", fmt.Errorf("decode webp: %w", err)
+		}
+		return cfg, FormatWebP, nil
+	}
+
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return image.Config{}, "", fmt.Errorf("decode image: %w", err)
+	}
+	return cfg, Format(format), nil
+}
+
 func isWebP(data []byte) bool {
 	return len(data) >= 12 && string(data[0:4]) == "RIFF" && string(data[8:12]) == "WEBP"
 }
@@ -168,6 +203,23 @@ func toRGBA(img image.Image) *image.RGBA {
 	return rgba
 }
 
+func (f Format) MIMEType() string {
+	switch f {
+	case FormatJPEG:
+		return "image/jpeg"
+	case FormatHEIC:
+		return "image/heic"
+	case "":
+		return "application/octet-stream"
+	}
+	return "image/" + string(f)
+}
+
+// Formats lists every format photon knows about, in display order.
+func Formats() []Format {
+	return []Format{FormatPNG, FormatJPEG, FormatGIF, FormatWebP, FormatBMP, FormatTIFF, FormatAVIF, FormatHEIC}
+}
+
 func IsSupported(format Format) bool {
 	return supportedFormats[format]
 }
@@ -175,3 +227,24 @@ func IsSupported(format Format) bool {
 func CanWrite(format Format) bool {
 	return supportedFormats[format] && !writeOnlyFormats[format]
 }
+
+// CanDecode reports whether this build can read format.
+func CanDecode(format Format) bool {
+	switch format {
+	case FormatHEIC, FormatAVIF:
+		return heifSupported
+	}
+	return supportedFormats[format]
+}
+
+// CanEncode reports whether this build can write format. Unlike CanWrite it
+// takes the optional CGO codecs into account.
+func CanEncode(format Format) bool {
+	switch format {
+	case FormatWebP:
+		return webpWriteSupported
+	case FormatAVIF:
+		return heifSupported
+	}
+	return CanWrite(format)
+}
@@ -38,6 +38,24 @@ func decodeHEIF(data []byte) (goimage.Image, error) {
 	return img.GetImage()
 }
 
+func decodeHEIFConfig(data []byte) (goimage.Config, error) {
+	ctx, err := heif.NewContext()
+	if err != nil {
+		return goimage.Config{}, fmt.Errorf("create heif context: %w", err)
+	}
+
+	if err := ctx.ReadFromMemory(data); err != nil {
+		return goimage.Config{}, fmt.Errorf("read heif data: %w", err)
+	}
+
+	handle, err := ctx.GetPrimaryImageHandle()
+	if err != nil {
+		return goimage.Config{}, fmt.Errorf("get primary image: %w", err)
+	}
+
+	return goimage.Config{Width: handle.GetWidth(), Height: handle.GetHeight()}, nil
+}
+
 func encodeAVIF(w io.Writer, img goimage.Image, quality int) error {
 	rgba := toRGBA(img)
 	ctx, err := heif.EncodeFromImage(rgba, heif.CompressionAV1, quality, heif.LosslessModeDisabled, heif.LoggingLevelNone)
@@ -16,6 +16,10 @@ func decodeHEIF(data []byte) (goimage.Image, error) {
 	return nil, fmt.Errorf("HEIC/AVIF support not available (build without CGO)")
 }
 
+func decodeHEIFConfig(data []byte) (goimage.Config, error) {
+	return goimage.Config{}, fmt.Errorf("HEIC/AVIF support not available (build without CGO)")
+}
+
 func encodeAVIF(w io.Writer, img goimage.Image, quality int) error {
 	return fmt.Errorf("AVIF encoding not available (build without CGO)")
 }
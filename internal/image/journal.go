@@ -0,0 +1,224 @@
+package image
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// JournalName is the file batches append their progress to in the output
+// directory, so an interrupted batch can be resumed.
+const JournalName = ".photon-journal.jsonl"
+
+const (
+	journalBatch  = "batch"
+	journalDone   = "done"
+	journalFailed = "failed"
+)
+
+type journalRecord struct {
+	Type         string       `json:"type"`
+	Started      time.Time    `json:"started,omitempty"`
+	Quality      int          `json:"quality,omitempty"`
+	Lossless     bool         `json:"lossless,omitempty"`
+	Width        int          `json:"width,omitempty"`
+	Height       int          `json:"height,omitempty"`
+	Metadata     string       `json:"metadata,omitempty"`
+	Incremental  bool         `json:"incremental,omitempty"`
+	NameTemplate NameTemplate `json:"name_template,omitempty"`
+	OnConflict   string       `json:"on_conflict,omitempty"`
+	Jobs         []Job        `json:"jobs,omitempty"`
+	Input        string       `json:"input,omitempty"`
+	Output       string       `json:"output,omitempty"`
+	Error        string       `json:"error,omitempty"`
+}
+
+// Journal is an append-only log of a batch: a header listing every job and
+// the options used, followed by one record per finished job.
+type Journal struct {
+	Path         string
+	Started      time.Time
+	Quality      int
+	Lossless     bool
+	Width        int
+	Height       int
+	Metadata     string
+	Incremental  bool
+	NameTemplate NameTemplate
+	OnConflict   string
+	Jobs         []Job
+
+	done   map[string]bool
+	failed map[string]string
+	f      *os.File
+}
+
+func CreateJournal(dir string, jobs []Job, opts Options) (*Journal, error) {
+	path := filepath.Join(dir, JournalName)
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("create journal: %w", err)
+	}
+
+	j := &Journal{
+		Path:         path,
+		Started:      time.Now(),
+		Quality:      opts.Quality,
+		Lossless:     opts.Lossless,
+		Width:        opts.Width,
+		Height:       opts.Height,
+		Metadata:     opts.Metadata,
+		Incremental:  opts.Incremental,
+		NameTemplate: opts.NameTemplate,
+		OnConflict:   opts.OnConflict,
+		Jobs:         jobs,
+		done:         make(map[string]bool),
+		failed:       make(map[string]string),
+		f:            f,
+	}
+
+	if err := j.append(journalRecord{
+		Type:         journalBatch,
+		Started:      j.Started,
+		Quality:      j.Quality,
+		Lossless:     j.Lossless,
+		Width:        j.Width,
+		Height:       j.Height,
+		Metadata:     j.Metadata,
+		Incremental:  j.Incremental,
+		NameTemplate: j.NameTemplate,
+		OnConflict:   j.OnConflict,
+		Jobs:         jobs,
+	}); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return j, nil
+}
+
+// OpenJournal reads an existing journal and opens it for appending. A
+// truncated last line, left by a crash mid-write, is ignored.
+func OpenJournal(path string) (*Journal, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("open journal: %w", err)
+	}
+
+	j := &Journal{
+		Path:   path,
+		done:   make(map[string]bool),
+		failed: make(map[string]string),
+		f:      f,
+	}
+
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	header := false
+	for sc.Scan() {
+		var rec journalRecord
+		if err := json.Unmarshal(sc.Bytes(), &rec); err != nil {
+			continue
+		}
+		switch rec.Type {
+		case journalBatch:
+			header = true
+			j.Started = rec.Started
+			j.Quality = rec.Quality
+			j.Lossless = rec.Lossless
+			j.Width = rec.Width
+			j.Height = rec.Height
+			j.Metadata = rec.Metadata
+			j.Incremental = rec.Incremental
+			j.NameTemplate = rec.NameTemplate
+			j.OnConflict = rec.OnConflict
+			j.Jobs = rec.Jobs
+		case journalDone:
+			j.done[rec.Output] = true
+			delete(j.failed, rec.Output)
+		case journalFailed:
+			delete(j.done, rec.Output)
+			j.failed[rec.Output] = rec.Error
+		}
+	}
+	if err := sc.Err(); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("read journal: %w", err)
+	}
+	if !header {
+		f.Close()
+		return nil, fmt.Errorf("read journal: %s has no batch header", path)
+	}
+	return j, nil
+}
+
+// Pending returns the jobs that haven't completed yet, including failed
+// ones and completed ones whose output has since disappeared.
+func (j *Journal) Pending() []Job {
+	var pending []Job
+	for _, job := range j.Jobs {
+		if j.done[job.Output] {
+			if _, err := os.Stat(job.Output); err == nil {
+				continue
+			}
+		}
+		pending = append(pending, job)
+	}
+	return pending
+}
+
+func (j *Journal) Completed() int {
+	return len(j.Jobs) - len(j.Pending())
+}
+
+func (j *Journal) Failed() int {
+	return len(j.failed)
+}
+
+// Options returns opts with the conversion settings the batch started
+// with.
+func (j *Journal) Options(opts Options) Options {
+	opts.Quality = j.Quality
+	opts.Lossless = j.Lossless
+	opts.Width = j.Width
+	opts.Height = j.Height
+	opts.Metadata = j.Metadata
+	opts.Incremental = j.Incremental
+	opts.NameTemplate = j.NameTemplate
+	opts.OnConflict = j.OnConflict
+	return opts
+}
+
+func (j *Journal) Record(job Job, err error) error {
+	if j == nil {
+		return nil
+	}
+	rec := journalRecord{Type: journalDone, Input: job.Input, Output: job.Output}
+	if err != nil {
+		rec.Type = journalFailed
+		rec.Error = err.Error()
+		j.failed[job.Output] = rec.Error
+		delete(j.done, job.Output)
+	} else {
+		j.done[job.Output] = true
+		delete(j.failed, job.Output)
+	}
+	return j.append(rec)
+}
+
+func (j *Journal) append(rec journalRecord) error {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	if _, err := j.f.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("write journal: %w", err)
+	}
+	return j.f.Sync()
+}
+
+func (j *Journal) Close() error {
+	return j.f.Close()
+}
@@ -0,0 +1,111 @@
+package image
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestJournalPending(t *testing.T) {
+	tmpDir := t.TempDir()
+	jobs := []Job{
+		{Input: "a.png", Output: filepath.Join(tmpDir, "a.jpg")},
+		{Input: "b.png", Output: filepath.Join(tmpDir, "b.jpg")},
+		{Input: "c.png", Output: filepath.Join(tmpDir, "c.jpg")},
+	}
+
+	opts := Options{Quality: 70, Width: 800, Metadata: MetadataKeep, Incremental: true, NameTemplate: "{name}-small.{ext}", OnConflict: ConflictOverwrite}
+	j, err := CreateJournal(tmpDir, jobs, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(jobs[0].Output, []byte("done"), 0644)
+	j.Record(jobs[0], nil)
+	j.Record(jobs[1], os.ErrNotExist)
+	j.Close()
+
+	// Simulate a crash in the middle of writing a record.
+	f, _ := os.OpenFile(j.Path, os.O_APPEND|os.O_WRONLY, 0644)
+	f.WriteString(`{"type":"done","inp`)
+	f.Close()
+
+	j, err = OpenJournal(j.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer j.Close()
+
+	if got := j.Options(Options{Quality: 95, Lossless: true}); !reflect.DeepEqual(got, opts) {
+		t.Errorf("journal options = %+v, want %+v", got, opts)
+	}
+	pending := j.Pending()
+	if len(pending) != 2 || pending[0] != jobs[1] || pending[1] != jobs[2] {
+		t.Errorf("unexpected pending jobs: %+v", pending)
+	}
+	if j.Failed() != 1 {
+		t.Errorf("expected 1 failed job, got %d", j.Failed())
+	}
+}
+
+func TestResumeBatch(t *testing.T) {
+	tmpDir := t.TempDir()
+	for _, name := range []string{"a.png", "b.png"} {
+		if err := createTestPNG(filepath.Join(tmpDir, name), 10, 10); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := ConvertBatch(tmpDir, "png", "jpg", DefaultOptions()); err != nil {
+		t.Fatal(err)
+	}
+
+	// Lose one output as if the batch had died before writing it.
+	os.Remove(filepath.Join(tmpDir, "b.jpg"))
+
+	var results []Result
+	opts := DefaultOptions()
+	opts.Reporter = &recordingReporter{results: &results}
+	if err := ResumeBatch(filepath.Join(tmpDir, JournalName), opts); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(results) != 1 || results[0].Output != filepath.Join(tmpDir, "b.jpg") {
+		t.Fatalf("expected only b.jpg to be converted, got %+v", results)
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, "b.jpg")); err != nil {
+		t.Error("expected resumed output to exist")
+	}
+}
+
+func TestRunBatchReportsJournalErrorsForSkippedJobs(t *testing.T) {
+	tmpDir := t.TempDir()
+	job := Job{Input: filepath.Join(tmpDir, "a.png"), Output: filepath.Join(tmpDir, "a.jpg")}
+	if err := createTestPNG(job.Input, 4, 4); err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(job.Output, []byte("up to date"), 0644)
+	later := time.Now().Add(time.Hour)
+	os.Chtimes(job.Output, later, later)
+
+	opts := Options{Quality: 80, Incremental: true}
+	j, err := CreateJournal(tmpDir, []Job{job}, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	j.Close()
+
+	err = runBatch(tmpDir, []Job{job}, opts, j)
+	if err == nil || !strings.Contains(err.Error(), "write journal") {
+		t.Errorf("runBatch error = %v, want the journal write error", err)
+	}
+}
+
+func TestOpenJournalWithoutHeader(t *testing.T) {
+	path := filepath.Join(t.TempDir(), JournalName)
+	os.WriteFile(path, []byte("{\"type\":\"done\"}\n"), 0644)
+	if _, err := OpenJournal(path); err == nil {
+		t.Error("expected error for journal without header")
+	}
+}
@@ -0,0 +1,164 @@
+package image
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ManifestName is the file incremental batches keep in the output directory
+// to remember what produced each output.
+const ManifestName = ".photon-manifest.json"
+
+const manifestVersion = 1
+
+type Manifest struct {
+	Version int                      `json:"version"`
+	Entries map[string]ManifestEntry `json:"entries"`
+
+	dir string
+}
+
+type ManifestEntry struct {
+	Input       string    `json:"input"`
+	InputHash   string    `json:"input_hash"`
+	InputSize   int64     `json:"input_size"`
+	InputMod    time.Time `json:"input_mod_time"`
+	OptionsHash string    `json:"options_hash"`
+}
+
+func LoadManifest(dir string) (*Manifest, error) {
+	m := &Manifest{
+		Version: manifestVersion,
+		Entries: make(map[string]ManifestEntry),
+		dir:     dir,
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, ManifestName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return m, nil
+		}
+		return nil, fmt.Errorf("read manifest: %w", err)
+	}
+
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, fmt.Errorf("parse manifest: %w", err)
+	}
+	if m.Entries == nil {
+		m.Entries = make(map[string]ManifestEntry)
+	}
+	return m, nil
+}
+
+func (m *Manifest) Save() error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	path := filepath.Join(m.dir, ManifestName)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("write manifest: %w", err)
+	}
+	return os.Rename(tmp, path)
+}
+
+func (m *Manifest) key(output string) string {
+	if rel, err := filepath.Rel(m.dir, output); err == nil {
+		return rel
+	}
+	return output
+}
+
+// UpToDate reports whether job's output can be kept as is. Outputs recorded
+// in the manifest are compared by input content and options hash; outputs
+// the manifest doesn't know about are kept when newer than their input.
+func (m *Manifest) UpToDate(job Job, optionsHash string) bool {
+	out, err := os.Stat(job.Output)
+	if err != nil {
+		return false
+	}
+	in, err := os.Stat(job.Input)
+	if err != nil {
+		return false
+	}
+
+	entry, ok := m.Entries[m.key(job.Output)]
+	if !ok {
+		return out.ModTime().After(in.ModTime())
+	}
+	if entry.OptionsHash != optionsHash {
+		return false
+	}
+
+	hash, err := m.inputHash(job.Input, in, entry)
+	if err != nil {
+		return false
+	}
+	return hash == entry.InputHash
+}
+
+func (m *Manifest) Record(job Job, optionsHash string) error {
+	in, err := os.Stat(job.Input)
+	if err != nil {
+		return err
+	}
+
+	key := m.key(job.Output)
+	hash, err := m.inputHash(job.Input, in, m.Entries[key])
+	if err != nil {
+		return err
+	}
+
+	m.Entries[key] = ManifestEntry{
+		Input:       job.Input,
+		InputHash:   hash,
+		InputSize:   in.Size(),
+		InputMod:    in.ModTime(),
+		OptionsHash: optionsHash,
+	}
+	return nil
+}
+
+// inputHash reuses the recorded hash while the input's size and modification
+// time are unchanged, so unchanged files aren't read on every run.
+func (m *Manifest) inputHash(path string, info os.FileInfo, entry ManifestEntry) (string, error) {
+	if entry.InputHash != "" && entry.InputSize == info.Size() && entry.InputMod.Equal(info.ModTime()) {
+		return entry.InputHash, nil
+	}
+	return FileHash(path)
+}
+
+func FileHash(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", fmt.Errorf("hash %s: %w", path, err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Hash identifies the options that affect the encoded output for format.
+func (o Options) Hash(format Format) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "format=%s\nquality=%d\nlossless=%t\n", format, o.Quality, o.Lossless)
+	if o.Width != 0 || o.Height != 0 {
+		fmt.Fprintf(h, "resize=%dx%d\n", o.Width, o.Height)
+	}
+	if o.Metadata == MetadataKeep {
+		fmt.Fprintf(h, "metadata=%s\n", o.Metadata)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
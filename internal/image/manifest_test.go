@@ -0,0 +1,93 @@
+package image
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestConvertBatchIncremental(t *testing.T) {
+	tmpDir := t.TempDir()
+	src := filepath.Join(tmpDir, "a.png")
+	if err := createTestPNG(src, 10, 10); err != nil {
+		t.Fatal(err)
+	}
+
+	var results []Result
+	opts := DefaultOptions()
+	opts.Incremental = true
+	opts.Reporter = &recordingReporter{results: &results}
+
+	if err := ConvertBatch(tmpDir, "png", "jpg", opts); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, ManifestName)); err != nil {
+		t.Fatalf("expected manifest to be written: %v", err)
+	}
+
+	if err := ConvertBatch(tmpDir, "png", "jpg", opts); err != nil {
+		t.Fatal(err)
+	}
+	if !results[1].Skipped {
+		t.Error("expected unchanged file to be skipped on second run")
+	}
+
+	opts.Quality = 50
+	if err := ConvertBatch(tmpDir, "png", "jpg", opts); err != nil {
+		t.Fatal(err)
+	}
+	if results[2].Skipped {
+		t.Error("expected changed options to re-encode")
+	}
+
+	if err := createTestPNG(src, 20, 20); err != nil {
+		t.Fatal(err)
+	}
+	if err := ConvertBatch(tmpDir, "png", "jpg", opts); err != nil {
+		t.Fatal(err)
+	}
+	if results[3].Skipped {
+		t.Error("expected changed input to re-encode")
+	}
+}
+
+func TestManifestUpToDateWithoutEntry(t *testing.T) {
+	tmpDir := t.TempDir()
+	src := filepath.Join(tmpDir, "a.png")
+	dst := filepath.Join(tmpDir, "a.jpg")
+	if err := createTestPNG(src, 10, 10); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(dst, []byte("old"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := LoadManifest(tmpDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	job := Job{Input: src, Output: dst}
+	hash := DefaultOptions().Hash(FormatJPEG)
+
+	past := time.Now().Add(-time.Hour)
+	os.Chtimes(dst, past, past)
+	if m.UpToDate(job, hash) {
+		t.Error("output older than input should not be up to date")
+	}
+
+	future := time.Now().Add(time.Hour)
+	os.Chtimes(dst, future, future)
+	if !m.UpToDate(job, hash) {
+		t.Error("output newer than input should be up to date")
+	}
+}
+
+type recordingReporter struct {
+	results *[]Result
+}
+
+func (r *recordingReporter) Report(res Result) { *r.results = append(*r.results, res) }
+func (r *recordingReporter) Summary(Summary)   {}
+func (r *recordingReporter) Plan(Plan)         {}
+func (r *recordingReporter) Close() error      { return nil }
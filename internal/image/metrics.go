@@ -0,0 +1,95 @@
+package image
+
+import (
+	"image"
+	"math"
+)
+
+// PSNR is the peak signal-to-noise ratio of b against a in decibels, over
+// the RGB channels of their common area. Identical images give +Inf.
+func PSNR(a, b image.Image) float64 {
+	w, h := commonSize(a, b)
+	if w == 0 || h == 0 {
+		return 0
+	}
+	ab, bb := a.Bounds(), b.Bounds()
+
+	var sum float64
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			r1, g1, b1, _ := a.At(ab.Min.X+x, ab.Min.Y+y).RGBA()
+			r2, g2, b2, _ := b.At(bb.Min.X+x, bb.Min.Y+y).RGBA()
+			for _, d := range [3]float64{
+				float64(r1>>8) - float64(r2>>8),
+				float64(g1>>8) - float64(g2>>8),
+				float64(b1>>8) - float64(b2>>8),
+			} {
+				sum += d * d
+			}
+		}
+	}
+	mse := sum / float64(3*w*h)
+	if mse == 0 {
+		return math.Inf(1)
+	}
+	return 10 * math.Log10(255*255/mse)
+}
+
+// SSIM is the structural similarity of b against a, from 0 to 1, averaged
+// over 8x8 blocks of luma.
+func SSIM(a, b image.Image) float64 {
+	const (
+		block = 8
+		c1    = (0.01 * 255) * (0.01 * 255)
+		c2    = (0.03 * 255) * (0.03 * 255)
+	)
+
+	w, h := commonSize(a, b)
+	if w == 0 || h == 0 {
+		return 0
+	}
+	la, lb := luma(a, w, h), luma(b, w, h)
+
+	var total float64
+	var blocks int
+	for by := 0; by < h; by += block {
+		for bx := 0; bx < w; bx += block {
+			var sa, sb, saa, sbb, sab float64
+			n := 0
+			for y := by; y < min(by+block, h); y++ {
+				for x := bx; x < min(bx+block, w); x++ {
+					va, vb := la[y*w+x], lb[y*w+x]
+					sa += va
+					sb += vb
+					saa += va * va
+					sbb += vb * vb
+					sab += va * vb
+					n++
+				}
+			}
+			fn := float64(n)
+			ma, mb := sa/fn, sb/fn
+			va, vb := saa/fn-ma*ma, sbb/fn-mb*mb
+			cov := sab/fn - ma*mb
+			total += (2*ma*mb + c1) * (2*cov + c2) / ((ma*ma + mb*mb + c1) * (va + vb + c2))
+			blocks++
+		}
+	}
+	return total / float64(blocks)
+}
+
+func commonSize(a, b image.Image) (int, int) {
+	return min(a.Bounds().Dx(), b.Bounds().Dx()), min(a.Bounds().Dy(), b.Bounds().Dy())
+}
+
+func luma(img image.Image, w, h int) []float64 {
+	b := img.Bounds()
+	l := make([]float64, w*h)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			r, g, bl, _ := img.At(b.Min.X+x, b.Min.Y+y).RGBA()
+			l[y*w+x] = (0.299*float64(r) + 0.587*float64(g) + 0.114*float64(bl)) / 257
+		}
+	}
+	return l
+}
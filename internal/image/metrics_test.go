@@ -0,0 +1,38 @@
+package image
+
+import (
+	"bytes"
+	"math"
+	"testing"
+)
+
+func TestMetrics(t *testing.T) {
+	img := createTestImage(64, 48, false)
+
+	if psnr := PSNR(img, img); !math.IsInf(psnr, 1) {
+		t.Errorf("PSNR of identical images = %v, want +Inf", psnr)
+	}
+	if ssim := SSIM(img, img); math.Abs(ssim-1) > 1e-9 {
+		t.Errorf("SSIM of identical images = %v, want 1", ssim)
+	}
+
+	decode := func(quality int) (float64, float64) {
+		var buf bytes.Buffer
+		if err := Encode(&buf, img, FormatJPEG, quality); err != nil {
+			t.Fatal(err)
+		}
+		out, _, err := Decode(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return PSNR(img, out), SSIM(img, out)
+	}
+	lowPSNR, lowSSIM := decode(10)
+	highPSNR, highSSIM := decode(95)
+	if lowPSNR >= highPSNR {
+		t.Errorf("PSNR at quality 10 (%.1f) should be below quality 95 (%.1f)", lowPSNR, highPSNR)
+	}
+	if lowSSIM >= highSSIM || highSSIM > 1 {
+		t.Errorf("SSIM at quality 10 (%.3f) should be below quality 95 (%.3f) and at most 1", lowSSIM, highSSIM)
+	}
+}
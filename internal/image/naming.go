@@ -0,0 +1,223 @@
+package image
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// DefaultNameTemplate keeps the input's base name and swaps the extension.
+const DefaultNameTemplate = "{name}.{ext}"
+
+const (
+	ConflictOverwrite = "overwrite"
+	ConflictRename    = "rename"
+)
+
+var placeholderRe = regexp.MustCompile(`\{([a-z0-9]+)(?::([^}]*))?\}`)
+
+// NameTemplate expands output file names from placeholders:
+//
+//	{name}     input base name without extension
+//	{ext}      output extension
+//	{width}    output width in pixels, after opts.Width/Height resizing
+//	{height}   output height in pixels
+//	{quality}  output quality
+//	{date}     EXIF capture date (file modification date as a fallback),
+//	           formatted as 2006-01-02 or with a Go layout: {date:20060102}
+//	{index}    1-based position in the batch, zero padded with {index:3}
+//	{hash8}    first 8 hex digits of the input's SHA-256
+type NameTemplate string
+
+func (t NameTemplate) Validate() error {
+	_, err := t.expand("", "", 0, Options{}, true)
+	return err
+}
+
+func (t NameTemplate) Expand(input, ext string, index int, opts Options) (string, error) {
+	return t.expand(input, ext, index, opts, false)
+}
+
+func (t NameTemplate) expand(input, ext string, index int, opts Options, dry bool) (string, error) {
+	tmpl := string(t)
+	if tmpl == "" {
+		tmpl = DefaultNameTemplate
+	}
+
+	var width, height int
+	var sizeErr error
+	sized := false
+	size := func() (int, int, error) {
+		if !sized {
+			width, height, sizeErr = imageSize(input)
+			width, height = FitSize(width, height, opts.Width, opts.Height)
+			sized = true
+		}
+		return width, height, sizeErr
+	}
+
+	var expandErr error
+	name := placeholderRe.ReplaceAllStringFunc(tmpl, func(ph string) string {
+		m := placeholderRe.FindStringSubmatch(ph)
+		key, arg := m[1], m[2]
+
+		if dry {
+			switch key {
+			case "name", "ext", "width", "height", "quality", "date", "index", "hash8":
+				return key
+			}
+			expandErr = fmt.Errorf("unknown placeholder %s in name template", ph)
+			return ""
+		}
+
+		switch key {
+		case "name":
+			return strings.TrimSuffix(filepath.Base(input), filepath.Ext(input))
+		case "ext":
+			return ext
+		case "width", "height":
+			w, h, err := size()
+			if err != nil {
+				expandErr = err
+				return ""
+			}
+			if key == "width" {
+				return strconv.Itoa(w)
+			}
+			return strconv.Itoa(h)
+		case "quality":
+			return strconv.Itoa(opts.Quality)
+		case "date":
+			layout := "2006-01-02"
+			if arg != "" {
+				layout = arg
+			}
+			t, ok := CaptureTime(input)
+			if !ok {
+				info, err := os.Stat(input)
+				if err != nil {
+					expandErr = err
+					return ""
+				}
+				t = info.ModTime()
+			}
+			return t.Format(layout)
+		case "index":
+			if arg != "" {
+				width, err := strconv.Atoi(arg)
+				if err != nil {
+					expandErr = fmt.Errorf("invalid index width in %s", ph)
+					return ""
+				}
+				return fmt.Sprintf("%0*d", width, index)
+			}
+			return strconv.Itoa(index)
+		case "hash8":
+			hash, err := FileHash(input)
+			if err != nil {
+				expandErr = err
+				return ""
+			}
+			return hash[:8]
+		}
+		expandErr = fmt.Errorf("unknown placeholder %s in name template", ph)
+		return ""
+	})
+	if expandErr != nil {
+		return "", expandErr
+	}
+
+	if strings.ContainsAny(name, `/\`) {
+		return "", fmt.Errorf("name template %q must not produce directories", tmpl)
+	}
+	if filepath.Ext(name) == "" && ext != "" {
+		name += "." + ext
+	}
+	return name, nil
+}
+
+func imageSize(path string) (int, int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, 0, fmt.Errorf("open input file: %w", err)
+	}
+	defer f.Close()
+
+	cfg, _, err := DecodeConfig(f)
+	if err != nil {
+		return 0, 0, err
+	}
+	return cfg.Width, cfg.Height, nil
+}
+
+// NameJobs maps inputs to outputs in dir using opts.NameTemplate. Names
+// that collide within the batch always get a numeric suffix; names that
+// collide with existing files only when opts.OnConflict is ConflictRename.
+// Incremental batches can't rename: a renamed output is never the one the
+// manifest recorded, so nothing would ever be skipped.
+func NameJobs(inputs []string, dir, ext string, opts Options) ([]Job, error) {
+	if opts.OnConflict != "" && opts.OnConflict != ConflictOverwrite && opts.OnConflict != ConflictRename {
+		return nil, fmt.Errorf("unknown conflict policy: %s (want %s or %s)", opts.OnConflict, ConflictOverwrite, ConflictRename)
+	}
+	if opts.Incremental && opts.OnConflict == ConflictRename {
+		return nil, fmt.Errorf("incremental batches can't use the %s conflict policy", ConflictRename)
+	}
+
+	taken := make(map[string]bool)
+	jobs := make([]Job, 0, len(inputs))
+	for i, input := range inputs {
+		job, err := NameJob(input, dir, ext, i+1, opts, taken)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, nil
+}
+
+// NameJob names a single output, avoiding and then adding to taken.
+func NameJob(input, dir, ext string, index int, opts Options, taken map[string]bool) (Job, error) {
+	name, err := opts.NameTemplate.Expand(input, ext, index, opts)
+	if err != nil {
+		return Job{}, fmt.Errorf("%s: %w", input, err)
+	}
+
+	output := uniqueName(filepath.Join(dir, name), taken, opts.OnConflict == ConflictRename)
+	taken[output] = true
+	return Job{Input: input, Output: output}, nil
+}
+
+// UniqueName returns path, or if a file already exists there, the path
+// with the first free numeric suffix, e.g. "photo-1.jpg".
+func UniqueName(path string) string {
+	return uniqueName(path, nil, true)
+}
+
+func uniqueName(path string, taken map[string]bool, avoidExisting bool) string {
+	free := func(p string) bool {
+		if taken[p] {
+			return false
+		}
+		if avoidExisting {
+			if _, err := os.Stat(p); err == nil {
+				return false
+			}
+		}
+		return true
+	}
+
+	if free(path) {
+		return path
+	}
+	ext := filepath.Ext(path)
+	base := strings.TrimSuffix(path, ext)
+	for n := 1; ; n++ {
+		candidate := fmt.Sprintf("%s-%d%s", base, n, ext)
+		if free(candidate) {
+			return candidate
+		}
+	}
+}
@@ -0,0 +1,203 @@
+package image
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestNameTemplateExpand(t *testing.T) {
+	tmpDir := t.TempDir()
+	src := filepath.Join(tmpDir, "photo.png")
+	if err := createTestPNG(src, 40, 30); err != nil {
+		t.Fatal(err)
+	}
+	mod := time.Date(2024, 3, 9, 12, 0, 0, 0, time.Local)
+	os.Chtimes(src, mod, mod)
+
+	hash, _ := FileHash(src)
+	opts := Options{Quality: 80}
+
+	tests := []struct {
+		tmpl string
+		opts Options
+		want string
+	}{
+		{"", opts, "photo.webp"},
+		{"{name}-{width}x{height}.{ext}", opts, "photo-40x30.webp"},
+		{"{name}-{width}x{height}.{ext}", Options{Width: 20}, "photo-20x15.webp"},
+		{"{name}-{width}x{height}.{ext}", Options{Width: 100, Height: 100}, "photo-40x30.webp"},
+		{"{name}_q{quality}", opts, "photo_q80.webp"},
+		{"{date}-{name}.{ext}", opts, "2024-03-09-photo.webp"},
+		{"{date:20060102}.{ext}", opts, "20240309.webp"},
+		{"img-{index:3}.{ext}", opts, "img-007.webp"},
+		{"{hash8}.{ext}", opts, hash[:8] + ".webp"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.tmpl, func(t *testing.T) {
+			got, err := NameTemplate(tt.tmpl).Expand(src, "webp", 7, tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("Expand(%q) = %q, want %q", tt.tmpl, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNameTemplateInvalid(t *testing.T) {
+	if err := NameTemplate("{name}-{size}.{ext}").Validate(); err == nil {
+		t.Error("expected error for unknown placeholder")
+	}
+	if _, err := NameTemplate("sub/{name}").Expand("a.png", "jpg", 1, Options{}); err == nil {
+		t.Error("expected error for template producing directories")
+	}
+}
+
+func TestNameJobsCollisions(t *testing.T) {
+	tmpDir := t.TempDir()
+	inputs := []string{filepath.Join(tmpDir, "a.png"), filepath.Join(tmpDir, "b.png")}
+	for _, in := range inputs {
+		if err := createTestPNG(in, 10, 10); err != nil {
+			t.Fatal(err)
+		}
+	}
+	os.WriteFile(filepath.Join(tmpDir, "out.jpg"), []byte("existing"), 0644)
+
+	opts := Options{NameTemplate: "out.{ext}"}
+	jobs, err := NameJobs(inputs, tmpDir, "jpg", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if jobs[0].Output != filepath.Join(tmpDir, "out.jpg") || jobs[1].Output != filepath.Join(tmpDir, "out-1.jpg") {
+		t.Errorf("unexpected outputs with overwrite policy: %+v", jobs)
+	}
+
+	opts.OnConflict = ConflictRename
+	jobs, err = NameJobs(inputs, tmpDir, "jpg", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if jobs[0].Output != filepath.Join(tmpDir, "out-1.jpg") || jobs[1].Output != filepath.Join(tmpDir, "out-2.jpg") {
+		t.Errorf("unexpected outputs with rename policy: %+v", jobs)
+	}
+
+	opts.OnConflict = "skip"
+	if _, err := NameJobs(inputs, tmpDir, "jpg", opts); err == nil {
+		t.Error("expected error for unknown conflict policy")
+	}
+
+	opts.OnConflict, opts.Incremental = ConflictRename, true
+	if _, err := NameJobs(inputs, tmpDir, "jpg", opts); err == nil {
+		t.Error("expected error for an incremental batch that renames")
+	}
+}
+
+func TestUniqueName(t *testing.T) {
+	tmpDir := t.TempDir()
+	path := filepath.Join(tmpDir, "out.jpg")
+	if got := UniqueName(path); got != path {
+		t.Errorf("UniqueName of a free path = %s", got)
+	}
+	os.WriteFile(path, []byte("existing"), 0644)
+	os.WriteFile(filepath.Join(tmpDir, "out-1.jpg"), []byte("existing"), 0644)
+	if got, want := UniqueName(path), filepath.Join(tmpDir, "out-2.jpg"); got != want {
+		t.Errorf("UniqueName = %s, want %s", got, want)
+	}
+}
+
+// testTIFF is a little endian TIFF structure: IFD0 with an Exif IFD
+// pointer, Exif IFD with DateTimeOriginal 2023-07-14 08:30:00.
+func testTIFF() []byte {
+	var buf bytes.Buffer
+	le := binary.LittleEndian
+	buf.WriteString("II*\x00")
+	binary.Write(&buf, le, uint32(8))
+
+	binary.Write(&buf, le, uint16(1))
+	binary.Write(&buf, le, []uint16{tagExifIFD, exifTypeLong})
+	binary.Write(&buf, le, []uint32{1, 26})
+	binary.Write(&buf, le, uint32(0))
+
+	binary.Write(&buf, le, uint16(1))
+	binary.Write(&buf, le, []uint16{tagDateTimeOriginal, exifTypeASCII})
+	binary.Write(&buf, le, []uint32{20, 44})
+	binary.Write(&buf, le, uint32(0))
+	buf.WriteString("2023:07:14 08:30:00\x00")
+	return buf.Bytes()
+}
+
+func TestExifCaptureTime(t *testing.T) {
+	jpeg := append([]byte("\xff\xd8\xff\xe1\x00\x00Exif\x00\x00"), testTIFF()...)
+
+	got, ok := exifCaptureTime(jpeg)
+	if !ok {
+		t.Fatal("expected capture time")
+	}
+	want := time.Date(2023, 7, 14, 8, 30, 0, 0, time.Local)
+	if !got.Equal(want) {
+		t.Errorf("capture time = %v, want %v", got, want)
+	}
+
+	if _, ok := exifCaptureTime([]byte("not an image")); ok {
+		t.Error("expected no capture time without EXIF")
+	}
+}
+
+// testTIFFWithImage is a little endian TIFF file whose 100 KB of image data
+// comes before IFD0, which has a StripOffsets entry and an Exif IFD
+// pointer, as in testTIFF.
+func testTIFFWithImage() []byte {
+	const pixels = 100 << 10
+	var buf bytes.Buffer
+	le := binary.LittleEndian
+	buf.WriteString("II*\x00")
+	binary.Write(&buf, le, uint32(8+pixels))
+	buf.Write(make([]byte, pixels))
+
+	ifd0 := uint32(8 + pixels)
+	binary.Write(&buf, le, uint16(2))
+	binary.Write(&buf, le, []uint16{tagStripOffsets, exifTypeLong})
+	binary.Write(&buf, le, []uint32{1, 8})
+	binary.Write(&buf, le, []uint16{tagExifIFD, exifTypeLong})
+	binary.Write(&buf, le, []uint32{1, ifd0 + 30})
+	binary.Write(&buf, le, uint32(0))
+
+	binary.Write(&buf, le, uint16(1))
+	binary.Write(&buf, le, []uint16{tagDateTimeOriginal, exifTypeASCII})
+	binary.Write(&buf, le, []uint32{20, ifd0 + 48})
+	binary.Write(&buf, le, uint32(0))
+	buf.WriteString("2023:07:14 08:30:00\x00")
+	return buf.Bytes()
+}
+
+func TestReadEXIF(t *testing.T) {
+	dir := t.TempDir()
+	heic := append([]byte("\x00\x00\x00\x18ftypheic\x00\x00\x00\x00mif1heic\x00\x00\x00\x06Exif\x00\x00"), testTIFF()...)
+	heic = append(heic, make([]byte, 100<<10)...)
+	files := map[string][]byte{
+		"photo.tiff": testTIFFWithImage(),
+		"photo.heic": heic,
+	}
+
+	for name, data := range files {
+		path := filepath.Join(dir, name)
+		os.WriteFile(path, data, 0644)
+
+		exif := readEXIF(path)
+		if len(exif) == 0 || len(exif) > 128 {
+			t.Errorf("%s: EXIF block is %d bytes, want the metadata only", name, len(exif))
+			continue
+		}
+		if _, ok := exifCaptureTime(exif); !ok {
+			t.Errorf("%s: EXIF block lost the capture time", name)
+		}
+		if _, ok := readIFD(exif, binary.LittleEndian, 8)[tagStripOffsets]; ok {
+			t.Errorf("%s: EXIF block kept the image data offsets", name)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package image
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+type Job struct {
+	Input  string `json:"input"`
+	Output string `json:"output"`
+}
+
+// PlanEntry describes what converting a Job would do without touching the
+// output. Err is set when the job cannot run, e.g. a codec is missing.
+type PlanEntry struct {
+	Job
+	SrcFormat Format
+	DstFormat Format
+	Overwrite bool
+	UpToDate  bool
+	Err       error
+}
+
+type Plan []PlanEntry
+
+func (p Plan) Failed() int {
+	n := 0
+	for _, e := range p {
+		if e.Err != nil {
+			n++
+		}
+	}
+	return n
+}
+
+func (p Plan) Overwrites() int {
+	n := 0
+	for _, e := range p {
+		if e.Overwrite && !e.UpToDate {
+			n++
+		}
+	}
+	return n
+}
+
+// BatchJobs resolves the inputs of a batch conversion in dir and maps each
+// one to its output path.
+func BatchJobs(dir string, fromExt, toExt string, opts Options) ([]Job, error) {
+	fromExt = strings.TrimPrefix(strings.ToLower(fromExt), ".")
+	toExt = strings.TrimPrefix(strings.ToLower(toExt), ".")
+
+	pattern := filepath.Join(dir, "*."+fromExt)
+	files, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("glob pattern: %w", err)
+	}
+
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no .%s files found in %s", fromExt, dir)
+	}
+
+	return NameJobs(files, opts.batchDir(dir), toExt, opts)
+}
+
+func PlanJobs(jobs []Job) Plan {
+	plan := make(Plan, 0, len(jobs))
+	seen := make(map[string]string)
+
+	for _, job := range jobs {
+		entry := PlanEntry{Job: job}
+		entry.Err = planJob(&entry)
+
+		if prev, ok := seen[job.Output]; ok && entry.Err == nil {
+			entry.Err = fmt.Errorf("output collides with %s", prev)
+		}
+		seen[job.Output] = job.Input
+
+		plan = append(plan, entry)
+	}
+	return plan
+}
+
+func planJob(entry *PlanEntry) error {
+	if _, err := os.Stat(entry.Input); err != nil {
+		return fmt.Errorf("open input file: %w", err)
+	}
+
+	src, err := FormatFromExtension(entry.Input)
+	if err != nil {
+		return err
+	}
+	entry.SrcFormat = src
+
+	dst, err := FormatFromExtension(entry.Output)
+	if err != nil {
+		return err
+	}
+	entry.DstFormat = dst
+
+	if _, err := os.Stat(entry.Output); err == nil {
+		entry.Overwrite = true
+	}
+
+	if !CanDecode(src) {
+		return fmt.Errorf("no decoder available for %s in this build", src)
+	}
+	if !CanEncode(dst) {
+		return fmt.Errorf("no encoder available for %s in this build", dst)
+	}
+	return nil
+}
+
+func (p Plan) Skipped() int {
+	n := 0
+	for _, e := range p {
+		if e.UpToDate {
+			n++
+		}
+	}
+	return n
+}
+
+func reportPlan(r Reporter, p Plan) {
+	if r != nil {
+		r.Plan(p)
+	}
+}
+
+func planError(p Plan) error {
+	if n := p.Failed(); n > 0 {
+		return fmt.Errorf("%d of %d files cannot be converted", n, len(p))
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package image
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBatchJobs(t *testing.T) {
+	tmpDir := t.TempDir()
+	for _, name := range []string{"a.png", "b.png", "c.jpg"} {
+		if err := createTestPNG(filepath.Join(tmpDir, name), 10, 10); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	jobs, err := BatchJobs(tmpDir, ".PNG", "webp", DefaultOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(jobs) != 2 {
+		t.Fatalf("expected 2 jobs, got %d", len(jobs))
+	}
+	if jobs[0].Output != filepath.Join(tmpDir, "a.webp") {
+		t.Errorf("unexpected output path %s", jobs[0].Output)
+	}
+}
+
+func TestPlanJobs(t *testing.T) {
+	tmpDir := t.TempDir()
+	src := filepath.Join(tmpDir, "a.png")
+	if err := createTestPNG(src, 10, 10); err != nil {
+		t.Fatal(err)
+	}
+	existing := filepath.Join(tmpDir, "a.jpg")
+	if err := os.WriteFile(existing, []byte("old"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	plan := PlanJobs([]Job{
+		{Input: src, Output: existing},
+		{Input: src, Output: filepath.Join(tmpDir, "a.bmp")},
+		{Input: src, Output: filepath.Join(tmpDir, "a.heic")},
+		{Input: filepath.Join(tmpDir, "missing.png"), Output: filepath.Join(tmpDir, "m.jpg")},
+		{Input: src, Output: existing},
+	})
+
+	if !plan[0].Overwrite || plan[0].Err != nil {
+		t.Errorf("expected overwrite without error, got %+v", plan[0])
+	}
+	if plan[1].Overwrite || plan[1].Err != nil || plan[1].DstFormat != FormatBMP {
+		t.Errorf("unexpected entry %+v", plan[1])
+	}
+	if plan[2].Err == nil {
+		t.Error("expected error for HEIC output")
+	}
+	if plan[3].Err == nil {
+		t.Error("expected error for missing input")
+	}
+	if plan[4].Err == nil || !strings.Contains(plan[4].Err.Error(), "collides") {
+		t.Errorf("expected collision error, got %v", plan[4].Err)
+	}
+	if plan.Failed() != 3 {
+		t.Errorf("expected 3 failed entries, got %d", plan.Failed())
+	}
+}
+
+func TestConvertBatchDryRun(t *testing.T) {
+	tmpDir := t.TempDir()
+	if err := createTestPNG(filepath.Join(tmpDir, "a.png"), 10, 10); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	r, _ := NewReporter(&buf, OutputText)
+	opts := DefaultOptions()
+	opts.Reporter = r
+	opts.DryRun = true
+
+	if err := ConvertBatch(tmpDir, "png", "jpg", opts); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, "a.jpg")); !os.IsNotExist(err) {
+		t.Error("dry run should not write output files")
+	}
+	if !strings.Contains(buf.String(), "a.jpg") || !strings.Contains(buf.String(), "nothing written") {
+		t.Errorf("unexpected plan output: %q", buf.String())
+	}
+}
@@ -0,0 +1,311 @@
+package image
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+type Result struct {
+	Input     string
+	Output    string
+	SrcFormat Format
+	DstFormat Format
+	Width     int
+	Height    int
+	BytesIn   int64
+	BytesOut  int64
+	Duration  time.Duration
+	Skipped   bool
+	Err       error
+}
+
+type Summary struct {
+	Total     int
+	Converted int
+	Failed    int
+	Skipped   int
+	BytesIn   int64
+	BytesOut  int64
+	Duration  time.Duration
+}
+
+func (s *Summary) Add(r Result) {
+	s.Total++
+	s.Duration += r.Duration
+	if r.Skipped {
+		s.Skipped++
+		return
+	}
+	if r.Err != nil {
+		s.Failed++
+		return
+	}
+	s.Converted++
+	s.BytesIn += r.BytesIn
+	s.BytesOut += r.BytesOut
+}
+
+// Reporter receives one Result per processed file and a Summary at the end
+// of a batch, or a Plan for dry runs. Close flushes anything the reporter
+// buffered.
+type Reporter interface {
+	Report(r Result)
+	Summary(s Summary)
+	Plan(p Plan)
+	Close() error
+}
+
+const (
+	OutputText   = "text"
+	OutputJSON   = "json"
+	OutputNDJSON = "ndjson"
+)
+
+func NewReporter(w io.Writer, format string) (Reporter, error) {
+	switch format {
+	case "", OutputText:
+		return &textReporter{w: w}, nil
+	case OutputJSON:
+		return &jsonReporter{w: w, files: []resultRecord{}}, nil
+	case OutputNDJSON:
+		return &ndjsonReporter{enc: json.NewEncoder(w)}, nil
+	default:
+		return nil, fmt.Errorf("unknown report format: %s (want text, json or ndjson)", format)
+	}
+}
+
+func report(r Reporter, res Result) {
+	if r != nil {
+		r.Report(res)
+	}
+}
+
+func reportSummary(r Reporter, s Summary) {
+	if r != nil {
+		r.Summary(s)
+	}
+}
+
+type textReporter struct {
+	w io.Writer
+}
+
+func (t *textReporter) Report(r Result) {
+	if r.Skipped {
+		fmt.Fprintf(t.w, "Skipped %s (up to date)\n", r.Input)
+		return
+	}
+	if r.Err != nil {
+		return
+	}
+	fmt.Fprintf(t.w, "Converted %s (%s) -> %s (%s)\n", r.Input, r.SrcFormat, r.Output, r.DstFormat)
+}
+
+func (t *textReporter) Summary(s Summary) {
+	if s.Failed > 0 {
+		return
+	}
+	if s.Skipped > 0 {
+		fmt.Fprintf(t.w, "Batch complete: %d files converted, %d up to date\n", s.Converted, s.Skipped)
+		return
+	}
+	fmt.Fprintf(t.w, "Batch complete: %d files converted\n", s.Converted)
+}
+
+func (t *textReporter) Plan(p Plan) {
+	for _, e := range p {
+		switch {
+		case e.Err != nil:
+			fmt.Fprintf(t.w, "  %s -> %s: %v\n", e.Input, e.Output, e.Err)
+		case e.UpToDate:
+			fmt.Fprintf(t.w, "  %s -> %s [up to date]\n", e.Input, e.Output)
+		case e.Overwrite:
+			fmt.Fprintf(t.w, "  %s (%s) -> %s (%s) [overwrite]\n", e.Input, e.SrcFormat, e.Output, e.DstFormat)
+		default:
+			fmt.Fprintf(t.w, "  %s (%s) -> %s (%s)\n", e.Input, e.SrcFormat, e.Output, e.DstFormat)
+		}
+	}
+	fmt.Fprintf(t.w, "Dry run: %d files, %d up to date, %d overwrites, %d errors, nothing written\n",
+		len(p), p.Skipped(), p.Overwrites(), p.Failed())
+}
+
+func (t *textReporter) Close() error {
+	return nil
+}
+
+type resultRecord struct {
+	Type       string `json:"type,omitempty"`
+	Input      string `json:"input"`
+	Output     string `json:"output"`
+	SrcFormat  Format `json:"src_format,omitempty"`
+	DstFormat  Format `json:"dst_format,omitempty"`
+	Width      int    `json:"width,omitempty"`
+	Height     int    `json:"height,omitempty"`
+	BytesIn    int64  `json:"bytes_in"`
+	BytesOut   int64  `json:"bytes_out"`
+	DurationMS int64  `json:"duration_ms"`
+	Skipped    bool   `json:"skipped,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+type summaryRecord struct {
+	Type       string `json:"type,omitempty"`
+	Total      int    `json:"total"`
+	Converted  int    `json:"converted"`
+	Failed     int    `json:"failed"`
+	Skipped    int    `json:"skipped"`
+	BytesIn    int64  `json:"bytes_in"`
+	BytesOut   int64  `json:"bytes_out"`
+	DurationMS int64  `json:"duration_ms"`
+}
+
+type planRecord struct {
+	Type      string `json:"type,omitempty"`
+	Input     string `json:"input"`
+	Output    string `json:"output"`
+	SrcFormat Format `json:"src_format,omitempty"`
+	DstFormat Format `json:"dst_format,omitempty"`
+	Overwrite bool   `json:"overwrite"`
+	UpToDate  bool   `json:"up_to_date,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+func newPlanRecord(e PlanEntry) planRecord {
+	rec := planRecord{
+		Input:     e.Input,
+		Output:    e.Output,
+		SrcFormat: e.SrcFormat,
+		DstFormat: e.DstFormat,
+		Overwrite: e.Overwrite,
+		UpToDate:  e.UpToDate,
+	}
+	if e.Err != nil {
+		rec.Error = e.Err.Error()
+	}
+	return rec
+}
+
+func newResultRecord(r Result) resultRecord {
+	rec := resultRecord{
+		Input:      r.Input,
+		Output:     r.Output,
+		SrcFormat:  r.SrcFormat,
+		DstFormat:  r.DstFormat,
+		Width:      r.Width,
+		Height:     r.Height,
+		BytesIn:    r.BytesIn,
+		BytesOut:   r.BytesOut,
+		DurationMS: r.Duration.Milliseconds(),
+		Skipped:    r.Skipped,
+	}
+	if r.Err != nil {
+		rec.Error = r.Err.Error()
+	}
+	return rec
+}
+
+func newSummaryRecord(s Summary) summaryRecord {
+	return summaryRecord{
+		Total:      s.Total,
+		Converted:  s.Converted,
+		Failed:     s.Failed,
+		Skipped:    s.Skipped,
+		BytesIn:    s.BytesIn,
+		BytesOut:   s.BytesOut,
+		DurationMS: s.Duration.Milliseconds(),
+	}
+}
+
+// jsonReporter buffers every record and writes a single document on Close.
+// When no batch summary was reported (single file conversions) it is
+// computed from the records.
+type jsonReporter struct {
+	w       io.Writer
+	files   []resultRecord
+	plan    []planRecord
+	tally   Summary
+	summary *Summary
+}
+
+func (j *jsonReporter) Report(r Result) {
+	j.files = append(j.files, newResultRecord(r))
+	j.tally.Add(r)
+}
+
+func (j *jsonReporter) Summary(s Summary) {
+	j.summary = &s
+}
+
+func (j *jsonReporter) Plan(p Plan) {
+	for _, e := range p {
+		j.plan = append(j.plan, newPlanRecord(e))
+	}
+}
+
+func (j *jsonReporter) Close() error {
+	enc := json.NewEncoder(j.w)
+	enc.SetIndent("", "  ")
+
+	if j.plan != nil {
+		return enc.Encode(struct {
+			DryRun bool         `json:"dry_run"`
+			Plan   []planRecord `json:"plan"`
+		}{true, j.plan})
+	}
+
+	s := j.tally
+	if j.summary != nil {
+		s = *j.summary
+	}
+	return enc.Encode(struct {
+		Files   []resultRecord `json:"files"`
+		Summary summaryRecord  `json:"summary"`
+	}{j.files, newSummaryRecord(s)})
+}
+
+// ndjsonReporter streams one JSON object per line as results arrive. The
+// first write error is returned from Close.
+type ndjsonReporter struct {
+	enc     *json.Encoder
+	tally   Summary
+	flushed bool
+	err     error
+}
+
+func (n *ndjsonReporter) encode(v any) {
+	if err := n.enc.Encode(v); err != nil && n.err == nil {
+		n.err = fmt.Errorf("write report: %w", err)
+	}
+}
+
+func (n *ndjsonReporter) Report(r Result) {
+	rec := newResultRecord(r)
+	rec.Type = "file"
+	n.encode(rec)
+	n.tally.Add(r)
+}
+
+func (n *ndjsonReporter) Summary(s Summary) {
+	rec := newSummaryRecord(s)
+	rec.Type = "summary"
+	n.encode(rec)
+	n.flushed = true
+}
+
+func (n *ndjsonReporter) Plan(p Plan) {
+	for _, e := range p {
+		rec := newPlanRecord(e)
+		rec.Type = "plan"
+		n.encode(rec)
+	}
+	n.flushed = true
+}
+
+func (n *ndjsonReporter) Close() error {
+	if !n.flushed {
+		n.Summary(n.tally)
+	}
+	return n.err
+}
@@ -0,0 +1,138 @@
+package image
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNewReporterUnknownFormat(t *testing.T) {
+	if _, err := NewReporter(&bytes.Buffer{}, "xml"); err == nil {
+		t.Error("expected error for unknown output format")
+	}
+}
+
+func TestTextReporter(t *testing.T) {
+	tmpDir := t.TempDir()
+	srcPath := filepath.Join(tmpDir, "input.png")
+	if err := createTestPNG(srcPath, 10, 10); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	r, _ := NewReporter(&buf, OutputText)
+	opts := DefaultOptions()
+	opts.Reporter = r
+
+	dstPath := filepath.Join(tmpDir, "output.jpg")
+	if err := Convert(srcPath, dstPath, opts); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "Converted " + srcPath + " (png) -> " + dstPath + " (jpeg)\n"
+	if buf.String() != want {
+		t.Errorf("text output = %q, want %q", buf.String(), want)
+	}
+}
+
+func TestNDJSONReporterBatch(t *testing.T) {
+	tmpDir := t.TempDir()
+	for _, name := range []string{"a.png", "b.png"} {
+		if err := createTestPNG(filepath.Join(tmpDir, name), 20, 10); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var buf bytes.Buffer
+	r, _ := NewReporter(&buf, OutputNDJSON)
+	opts := DefaultOptions()
+	opts.Reporter = r
+
+	if err := ConvertBatch(tmpDir, "png", "jpg", opts); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	var records []map[string]any
+	sc := bufio.NewScanner(&buf)
+	for sc.Scan() {
+		var rec map[string]any
+		if err := json.Unmarshal(sc.Bytes(), &rec); err != nil {
+			t.Fatalf("invalid ndjson line %q: %v", sc.Text(), err)
+		}
+		records = append(records, rec)
+	}
+
+	if len(records) != 3 {
+		t.Fatalf("expected 2 file records and a summary, got %d lines", len(records))
+	}
+	first := records[0]
+	if first["type"] != "file" || first["width"] != float64(20) || first["height"] != float64(10) {
+		t.Errorf("unexpected file record: %v", first)
+	}
+	if first["bytes_in"].(float64) <= 0 || first["bytes_out"].(float64) <= 0 {
+		t.Errorf("expected byte counts in record: %v", first)
+	}
+	last := records[2]
+	if last["type"] != "summary" || last["converted"] != float64(2) || last["failed"] != float64(0) {
+		t.Errorf("unexpected summary record: %v", last)
+	}
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+func TestNDJSONReporterReturnsWriteErrors(t *testing.T) {
+	r, _ := NewReporter(failingWriter{}, OutputNDJSON)
+	r.Report(Result{Input: "a.png", Output: "a.jpg"})
+	r.Report(Result{Input: "b.png", Output: "b.jpg"})
+
+	err := r.Close()
+	if err == nil || !strings.Contains(err.Error(), "disk full") {
+		t.Errorf("Close() = %v, want the write error", err)
+	}
+}
+
+func TestJSONReporterRecordsErrors(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	var buf bytes.Buffer
+	r, _ := NewReporter(&buf, OutputJSON)
+	opts := DefaultOptions()
+	opts.Reporter = r
+
+	if err := Convert(filepath.Join(tmpDir, "missing.png"), filepath.Join(tmpDir, "out.jpg"), opts); err == nil {
+		t.Fatal("expected error for missing input")
+	}
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	var doc struct {
+		Files []struct {
+			Error string `json:"error"`
+		} `json:"files"`
+		Summary struct {
+			Total  int `json:"total"`
+			Failed int `json:"failed"`
+		} `json:"summary"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("invalid json output: %v", err)
+	}
+	if len(doc.Files) != 1 || !strings.Contains(doc.Files[0].Error, "open input file") {
+		t.Errorf("expected one failed record, got %+v", doc.Files)
+	}
+	if doc.Summary.Total != 1 || doc.Summary.Failed != 1 {
+		t.Errorf("unexpected summary: %+v", doc.Summary)
+	}
+}
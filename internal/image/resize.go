@@ -0,0 +1,43 @@
+package image
+
+import (
+	"image"
+	"math"
+
+	"golang.org/x/image/draw"
+)
+
+// Resize scales img to fit width x height. A zero dimension is derived from
+// the other one to keep the aspect ratio, and images are never upscaled.
+func Resize(img image.Image, width, height int) image.Image {
+	b := img.Bounds()
+	w, h := FitSize(b.Dx(), b.Dy(), width, height)
+	if w == b.Dx() && h == b.Dy() {
+		return img
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, b, draw.Src, nil)
+	return dst
+}
+
+// FitSize returns the size Resize scales a srcW x srcH image to.
+func FitSize(srcW, srcH, width, height int) (int, int) {
+	if srcW == 0 || srcH == 0 || (width <= 0 && height <= 0) {
+		return srcW, srcH
+	}
+
+	// One scale for both axes, the smaller of the two that fit, so the
+	// image keeps its aspect ratio.
+	scale := 1.0
+	if width > 0 {
+		scale = min(scale, float64(width)/float64(srcW))
+	}
+	if height > 0 {
+		scale = min(scale, float64(height)/float64(srcH))
+	}
+	if scale >= 1 {
+		return srcW, srcH
+	}
+	return max(int(math.Round(float64(srcW)*scale)), 1), max(int(math.Round(float64(srcH)*scale)), 1)
+}
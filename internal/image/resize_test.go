@@ -0,0 +1,29 @@
+package image
+
+import "testing"
+
+func TestResize(t *testing.T) {
+	img := createTestImage(200, 100, false)
+
+	tests := []struct {
+		name          string
+		width, height int
+		wantW, wantH  int
+	}{
+		{"width only", 100, 0, 100, 50},
+		{"height only", 0, 25, 50, 25},
+		{"both", 40, 40, 40, 20},
+		{"both, height limits", 100, 25, 50, 25},
+		{"one axis already fits", 400, 50, 100, 50},
+		{"no upscale", 400, 0, 200, 100},
+		{"unchanged", 0, 0, 200, 100},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := Resize(img, tt.width, tt.height).Bounds()
+			if b.Dx() != tt.wantW || b.Dy() != tt.wantH {
+				t.Errorf("Resize(%d, %d) = %dx%d, want %dx%d", tt.width, tt.height, b.Dx(), b.Dy(), tt.wantW, tt.wantH)
+			}
+		})
+	}
+}
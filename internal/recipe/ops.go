@@ -0,0 +1,139 @@
+package recipe
+
+import (
+	"fmt"
+	goimage "image"
+	"image/color"
+
+	"github.com/mahamedmuse/photon/internal/image"
+)
+
+// Operation is one step of a recipe's operation chain. Exactly one field
+// must be set:
+//
+//   - resize: {width: 800}
+//   - rotate: 90
+//   - flip: horizontal
+//   - grayscale: true
+type Operation struct {
+	Resize    *ResizeOp `yaml:"resize,omitempty" json:"resize,omitempty"`
+	Rotate    int       `yaml:"rotate,omitempty" json:"rotate,omitempty"`
+	Flip      string    `yaml:"flip,omitempty" json:"flip,omitempty"`
+	Grayscale bool      `yaml:"grayscale,omitempty" json:"grayscale,omitempty"`
+}
+
+type ResizeOp struct {
+	Width  int `yaml:"width,omitempty" json:"width,omitempty"`
+	Height int `yaml:"height,omitempty" json:"height,omitempty"`
+}
+
+func (op Operation) validate() error {
+	set := 0
+	if op.Resize != nil {
+		set++
+		if op.Resize.Width < 0 || op.Resize.Height < 0 || (op.Resize.Width == 0 && op.Resize.Height == 0) {
+			return fmt.Errorf("resize needs a positive width or height")
+		}
+	}
+	if op.Rotate != 0 {
+		set++
+		if op.Rotate != 90 && op.Rotate != 180 && op.Rotate != 270 {
+			return fmt.Errorf("rotate must be 90, 180 or 270, got %d", op.Rotate)
+		}
+	}
+	if op.Flip != "" {
+		set++
+		if op.Flip != "horizontal" && op.Flip != "vertical" {
+			return fmt.Errorf("flip must be horizontal or vertical, got %q", op.Flip)
+		}
+	}
+	if op.Grayscale {
+		set++
+	}
+
+	if set != 1 {
+		return fmt.Errorf("each operation must set exactly one of resize, rotate, flip or grayscale")
+	}
+	return nil
+}
+
+func (op Operation) apply(img goimage.Image) goimage.Image {
+	switch {
+	case op.Resize != nil:
+		return image.Resize(img, op.Resize.Width, op.Resize.Height)
+	case op.Rotate != 0:
+		return rotate(img, op.Rotate)
+	case op.Flip != "":
+		return flip(img, op.Flip == "horizontal")
+	case op.Grayscale:
+		return grayscale(img)
+	}
+	return img
+}
+
+func applyAll(img goimage.Image, ops []Operation) goimage.Image {
+	for _, op := range ops {
+		img = op.apply(img)
+	}
+	return img
+}
+
+func rotate(img goimage.Image, degrees int) goimage.Image {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+
+	var dst *goimage.RGBA
+	if degrees == 180 {
+		dst = goimage.NewRGBA(goimage.Rect(0, 0, w, h))
+	} else {
+		dst = goimage.NewRGBA(goimage.Rect(0, 0, h, w))
+	}
+
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			c := img.At(b.Min.X+x, b.Min.Y+y)
+			switch degrees {
+			case 90:
+				dst.Set(h-1-y, x, c)
+			case 180:
+				dst.Set(w-1-x, h-1-y, c)
+			case 270:
+				dst.Set(y, w-1-x, c)
+			}
+		}
+	}
+	return dst
+}
+
+func flip(img goimage.Image, horizontal bool) goimage.Image {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	dst := goimage.NewRGBA(goimage.Rect(0, 0, w, h))
+
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			c := img.At(b.Min.X+x, b.Min.Y+y)
+			if horizontal {
+				dst.Set(w-1-x, y, c)
+			} else {
+				dst.Set(x, h-1-y, c)
+			}
+		}
+	}
+	return dst
+}
+
+func grayscale(img goimage.Image) goimage.Image {
+	b := img.Bounds()
+	dst := goimage.NewNRGBA(b)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			// color.GrayModel's weights, applied to the unpremultiplied
+			// color so the alpha channel is kept as it is.
+			c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+			v := uint8((19595*uint32(c.R) + 38470*uint32(c.G) + 7471*uint32(c.B) + 1<<15) >> 16)
+			dst.SetNRGBA(x, y, color.NRGBA{R: v, G: v, B: v, A: c.A})
+		}
+	}
+	return dst
+}
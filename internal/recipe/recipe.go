@@ -0,0 +1,307 @@
+// Package recipe runs declarative multi-output conversion jobs described in
+// a YAML or JSON file.
+package recipe
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	goimage "image"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/mahamedmuse/photon/internal/image"
+	"gopkg.in/yaml.v3"
+)
+
+// Recipe describes a set of inputs, the operations applied to all of them,
+// and the outputs produced from each input. Relative paths are resolved
+// against the directory of the recipe file.
+type Recipe struct {
+	Inputs     []string    `yaml:"inputs" json:"inputs"`
+	OutputDir  string      `yaml:"output_dir,omitempty" json:"output_dir,omitempty"`
+	Operations []Operation `yaml:"operations,omitempty" json:"operations,omitempty"`
+	Outputs    []Output    `yaml:"outputs" json:"outputs"`
+
+	dir string
+}
+
+// Output is one derivative written for every input. Its operations run
+// after the recipe-wide ones.
+type Output struct {
+	Name       string      `yaml:"name" json:"name"`
+	Format     string      `yaml:"format" json:"format"`
+	Quality    int         `yaml:"quality,omitempty" json:"quality,omitempty"`
+	Filename   string      `yaml:"filename,omitempty" json:"filename,omitempty"`
+	Dir        string      `yaml:"dir,omitempty" json:"dir,omitempty"`
+	Operations []Operation `yaml:"operations,omitempty" json:"operations,omitempty"`
+
+	format image.Format
+}
+
+// Load reads a recipe, as JSON when path ends in .json and as YAML
+// otherwise, and validates it.
+func Load(path string) (*Recipe, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read recipe: %w", err)
+	}
+
+	var r Recipe
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		err = dec.Decode(&r)
+	} else {
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		err = dec.Decode(&r)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parse recipe %s: %w", path, err)
+	}
+
+	r.dir = filepath.Dir(path)
+	if err := r.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid recipe %s: %w", path, err)
+	}
+	return &r, nil
+}
+
+func (r *Recipe) Validate() error {
+	if len(r.Inputs) == 0 {
+		return fmt.Errorf("no inputs")
+	}
+	if len(r.Outputs) == 0 {
+		return fmt.Errorf("no outputs")
+	}
+	for i, op := range r.Operations {
+		if err := op.validate(); err != nil {
+			return fmt.Errorf("operations[%d]: %w", i, err)
+		}
+	}
+
+	names := make(map[string]bool)
+	for i := range r.Outputs {
+		out := &r.Outputs[i]
+		if out.Name == "" {
+			return fmt.Errorf("outputs[%d]: missing name", i)
+		}
+		if names[out.Name] {
+			return fmt.Errorf("outputs[%d]: duplicate name %q", i, out.Name)
+		}
+		names[out.Name] = true
+
+		format, err := image.FormatFromExtension("." + strings.TrimPrefix(out.Format, "."))
+		if err != nil {
+			return fmt.Errorf("output %s: %w", out.Name, err)
+		}
+		out.format = format
+
+		if out.Quality < 0 || out.Quality > 100 {
+			return fmt.Errorf("output %s: quality must be between 1 and 100", out.Name)
+		}
+		if err := image.NameTemplate(out.Filename).Validate(); err != nil {
+			return fmt.Errorf("output %s: %w", out.Name, err)
+		}
+		for j, op := range out.Operations {
+			if err := op.validate(); err != nil {
+				return fmt.Errorf("output %s: operations[%d]: %w", out.Name, j, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (r *Recipe) path(p string) string {
+	if p == "" || filepath.IsAbs(p) {
+		return p
+	}
+	return filepath.Join(r.dir, p)
+}
+
+// inputs expands the input globs, keeping their order and dropping
+// duplicates.
+func (r *Recipe) inputs() ([]string, error) {
+	var files []string
+	seen := make(map[string]bool)
+	for _, pattern := range r.Inputs {
+		matches, err := filepath.Glob(r.path(pattern))
+		if err != nil {
+			return nil, fmt.Errorf("glob pattern %s: %w", pattern, err)
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("no files match %s", pattern)
+		}
+		for _, m := range matches {
+			if !seen[m] {
+				seen[m] = true
+				files = append(files, m)
+			}
+		}
+	}
+	return files, nil
+}
+
+// outputDir is where an output's files go: its dir if set, otherwise a
+// directory named after it under the recipe's output_dir.
+func (r *Recipe) outputDir(out Output) string {
+	if out.Dir != "" {
+		return r.path(filepath.Join(r.OutputDir, out.Dir))
+	}
+	return r.path(filepath.Join(r.OutputDir, out.Name))
+}
+
+func (out Output) quality() int {
+	if out.Quality == 0 {
+		return image.DefaultOptions().Quality
+	}
+	return out.Quality
+}
+
+func (out Output) options(opts image.Options) image.Options {
+	opts.Quality = out.quality()
+	opts.NameTemplate = image.NameTemplate(out.Filename)
+	return opts
+}
+
+// jobs names every output of every input. jobs[i][j] is output j of input i.
+// Two outputs naming the same file is an error, as one would overwrite the
+// other.
+func (r *Recipe) jobs(inputs []string, opts image.Options) ([][]image.Job, error) {
+	jobs := make([][]image.Job, len(inputs))
+	writtenBy := make(map[string]string)
+	for _, out := range r.Outputs {
+		named, err := image.NameJobs(inputs, r.outputDir(out), strings.ToLower(strings.TrimPrefix(out.Format, ".")), out.options(opts))
+		if err != nil {
+			return nil, fmt.Errorf("output %s: %w", out.Name, err)
+		}
+		for i, job := range named {
+			if other, ok := writtenBy[job.Output]; ok {
+				return nil, fmt.Errorf("outputs %s and %s both write %s", other, out.Name, job.Output)
+			}
+			writtenBy[job.Output] = out.Name
+			jobs[i] = append(jobs[i], job)
+		}
+	}
+	return jobs, nil
+}
+
+// Run produces every output for every input, decoding each input once.
+// opts supplies the reporter, dry-run flag and conflict policy; quality and
+// naming come from the recipe.
+func Run(r *Recipe, opts image.Options) error {
+	inputs, err := r.inputs()
+	if err != nil {
+		return err
+	}
+	jobs, err := r.jobs(inputs, opts)
+	if err != nil {
+		return err
+	}
+
+	if opts.DryRun {
+		var all []image.Job
+		for _, js := range jobs {
+			all = append(all, js...)
+		}
+		plan := image.PlanJobs(all)
+		if opts.Reporter != nil {
+			opts.Reporter.Plan(plan)
+		}
+		if n := plan.Failed(); n > 0 {
+			return fmt.Errorf("%d of %d outputs cannot be written", n, len(plan))
+		}
+		return nil
+	}
+
+	for _, out := range r.Outputs {
+		if !image.CanEncode(out.format) {
+			return fmt.Errorf("output %s: no encoder available for %s in this build", out.Name, out.format)
+		}
+		if err := os.MkdirAll(r.outputDir(out), 0755); err != nil {
+			return fmt.Errorf("create output directory: %w", err)
+		}
+	}
+
+	var summary image.Summary
+	var errors []string
+	for i, input := range inputs {
+		for _, res := range r.runInput(input, jobs[i], opts.BeforeWrite) {
+			summary.Add(res)
+			if opts.Reporter != nil {
+				opts.Reporter.Report(res)
+			}
+			if res.Err != nil {
+				errors = append(errors, fmt.Sprintf("%s -> %s: %v", res.Input, res.Output, res.Err))
+			}
+		}
+	}
+	if opts.Reporter != nil {
+		opts.Reporter.Summary(summary)
+	}
+
+	if len(errors) > 0 {
+		return fmt.Errorf("failed to write %d outputs:\n%s", len(errors), strings.Join(errors, "\n"))
+	}
+	return nil
+}
+
+// runInput decodes input once and writes each of its outputs, calling
+// beforeWrite first if set. The decode time is attributed to the first
+// output.
+func (r *Recipe) runInput(input string, jobs []image.Job, beforeWrite func(string) error) []image.Result {
+	start := time.Now()
+	results := make([]image.Result, len(jobs))
+	for j, job := range jobs {
+		results[j] = image.Result{Input: input, Output: job.Output, DstFormat: r.Outputs[j].format}
+	}
+
+	base, srcFormat, size, err := decode(input)
+	if err == nil {
+		base = applyAll(base, r.Operations)
+	}
+	for j, job := range jobs {
+		res := &results[j]
+		res.SrcFormat = srcFormat
+		res.BytesIn = size
+		if err != nil {
+			res.Err = err
+			continue
+		}
+
+		out := r.Outputs[j]
+		img := applyAll(base, out.Operations)
+		res.Width = img.Bounds().Dx()
+		res.Height = img.Bounds().Dy()
+		if beforeWrite != nil {
+			if res.Err = beforeWrite(job.Output); res.Err != nil {
+				continue
+			}
+		}
+		res.BytesOut, res.Err = image.WriteFile(job.Output, img, out.format, out.quality())
+		res.Duration = time.Since(start)
+		start = time.Now()
+	}
+	return results
+}
+
+func decode(path string) (goimage.Image, image.Format, int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, "", 0, fmt.Errorf("open input file: %w", err)
+	}
+	defer f.Close()
+
+	var size int64
+	if info, err := f.Stat(); err == nil {
+		size = info.Size()
+	}
+	img, format, err := image.Decode(f)
+	if err != nil {
+		return nil, "", size, err
+	}
+	return img, format, size, nil
+}
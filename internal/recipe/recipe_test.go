@@ -0,0 +1,177 @@
+package recipe
+
+import (
+	goimage "image"
+	"image/color"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mahamedmuse/photon/internal/image"
+	"github.com/mahamedmuse/photon/internal/testutil"
+)
+
+func writeRecipe(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func imageSize(t *testing.T, path string) (int, int) {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	cfg, _, err := image.DecodeConfig(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cfg.Width, cfg.Height
+}
+
+const testRecipe = `
+inputs:
+  - src/*.png
+output_dir: out
+operations:
+  - resize: {width: 100}
+outputs:
+  - name: full
+    format: jpg
+    quality: 80
+    filename: "{name}@2x.{ext}"
+  - name: small
+    format: png
+    operations:
+      - resize: {width: 50}
+      - rotate: 90
+`
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	os.Mkdir(filepath.Join(dir, "src"), 0755)
+	testutil.WritePNG(t, filepath.Join(dir, "src", "a.png"), 200, 100)
+	testutil.WritePNG(t, filepath.Join(dir, "src", "b.png"), 400, 400)
+
+	r, err := Load(writeRecipe(t, dir, "recipe.yaml", testRecipe))
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if err := Run(r, image.Options{}); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	tests := []struct {
+		path         string
+		wantW, wantH int
+	}{
+		{"out/full/a@2x.jpg", 100, 50},
+		{"out/full/b@2x.jpg", 100, 100},
+		{"out/small/a.png", 25, 50},
+		{"out/small/b.png", 50, 50},
+	}
+	for _, tt := range tests {
+		w, h := imageSize(t, filepath.Join(dir, tt.path))
+		if w != tt.wantW || h != tt.wantH {
+			t.Errorf("%s is %dx%d, want %dx%d", tt.path, w, h, tt.wantW, tt.wantH)
+		}
+	}
+}
+
+func TestRunDryRun(t *testing.T) {
+	dir := t.TempDir()
+	os.Mkdir(filepath.Join(dir, "src"), 0755)
+	testutil.WritePNG(t, filepath.Join(dir, "src", "a.png"), 10, 10)
+
+	r, err := Load(writeRecipe(t, dir, "recipe.yaml", testRecipe))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var sb strings.Builder
+	reporter, _ := image.NewReporter(&sb, image.OutputText)
+	if err := Run(r, image.Options{DryRun: true, Reporter: reporter}); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "out")); !os.IsNotExist(err) {
+		t.Error("dry run created the output directory")
+	}
+	if !strings.Contains(sb.String(), "Dry run: 2 files") {
+		t.Errorf("unexpected plan output:\n%s", sb.String())
+	}
+}
+
+func TestRunRejectsCollidingOutputs(t *testing.T) {
+	dir := t.TempDir()
+	testutil.WritePNG(t, filepath.Join(dir, "a.png"), 10, 10)
+	r, err := Load(writeRecipe(t, dir, "recipe.yaml", `
+inputs: [a.png]
+outputs:
+  - {name: big, format: png, dir: out}
+  - {name: small, format: png, dir: out, operations: [{resize: {width: 5}}]}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = Run(r, image.Options{})
+	if err == nil || !strings.Contains(err.Error(), "outputs big and small both write") {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "out")); !os.IsNotExist(err) {
+		t.Error("outputs were written despite the collision")
+	}
+}
+
+func TestGrayscaleKeepsAlpha(t *testing.T) {
+	img := goimage.NewNRGBA(goimage.Rect(0, 0, 1, 1))
+	img.SetNRGBA(0, 0, color.NRGBA{R: 200, G: 200, B: 200, A: 128})
+	got := grayscale(img).At(0, 0).(color.NRGBA)
+	if want := (color.NRGBA{R: 200, G: 200, B: 200, A: 128}); got != want {
+		t.Errorf("grayscale of a half-transparent gray = %v, want %v", got, want)
+	}
+}
+
+func TestLoadJSON(t *testing.T) {
+	dir := t.TempDir()
+	path := writeRecipe(t, dir, "recipe.json", `{
+		"inputs": ["*.png"],
+		"outputs": [{"name": "gray", "format": "png", "operations": [{"grayscale": true}, {"flip": "horizontal"}]}]
+	}`)
+
+	r, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if got := r.Outputs[0].Operations; len(got) != 2 || !got[0].Grayscale || got[1].Flip != "horizontal" {
+		t.Errorf("operations = %+v", got)
+	}
+}
+
+func TestLoadInvalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{"no outputs", "inputs: [a.png]\n", "no outputs"},
+		{"unknown format", "inputs: [a.png]\noutputs: [{name: x, format: xyz}]\n", "unsupported"},
+		{"duplicate name", "inputs: [a.png]\noutputs: [{name: x, format: png}, {name: x, format: jpg}]\n", "duplicate name"},
+		{"two ops in one step", "inputs: [a.png]\noperations: [{rotate: 90, grayscale: true}]\noutputs: [{name: x, format: png}]\n", "exactly one"},
+		{"bad rotate", "inputs: [a.png]\noperations: [{rotate: 45}]\noutputs: [{name: x, format: png}]\n", "rotate"},
+		{"unknown key", "inputs: [a.png]\nouputs: []\n", "ouputs"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Load(writeRecipe(t, t.TempDir(), "recipe.yaml", tt.content))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Load() error = %v, want containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,306 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	goimage "image"
+	"io"
+	"net/http"
+	"os"
+	"runtime"
+	"strconv"
+	"time"
+
+	"github.com/mahamedmuse/photon/internal/image"
+)
+
+type Config struct {
+	// MaxBytes limits the size of request bodies.
+	MaxBytes int64
+	// MaxPixels limits width*height of decoded images.
+	MaxPixels int
+	// MaxConcurrent limits how many images are decoded or encoded at once.
+	MaxConcurrent int
+	// MaxBodies limits how many request bodies and source images, each up
+	// to MaxBytes, are held in memory at once.
+	MaxBodies int
+	// ReadTimeout limits how long reading a request body may take.
+	ReadTimeout    time.Duration
+	DefaultQuality int
+}
+
+func DefaultConfig() Config {
+	return Config{
+		MaxBytes:       32 << 20,
+		MaxPixels:      50_000_000,
+		MaxConcurrent:  runtime.NumCPU(),
+		MaxBodies:      2 * runtime.NumCPU(),
+		ReadTimeout:    30 * time.Second,
+		DefaultQuality: 85,
+	}
+}
+
+// Server holds two budgets: bodies bounds the encoded images held in
+// memory and sem the images being decoded or encoded. A request that
+// needs both takes a body slot first.
+type Server struct {
+	cfg    Config
+	sem    chan struct{}
+	bodies chan struct{}
+	mux    *http.ServeMux
+}
+
+func New(cfg Config) *Server {
+	if cfg.MaxConcurrent < 1 {
+		cfg.MaxConcurrent = 1
+	}
+	if cfg.MaxBodies < 1 {
+		cfg.MaxBodies = cfg.MaxConcurrent
+	}
+
+	s := &Server{
+		cfg:    cfg,
+		sem:    make(chan struct{}, cfg.MaxConcurrent),
+		bodies: make(chan struct{}, cfg.MaxBodies),
+		mux:    http.NewServeMux(),
+	}
+	s.mux.HandleFunc("GET /healthz", s.handleHealth)
+	s.mux.HandleFunc("GET /formats", s.handleFormats)
+	s.mux.HandleFunc("POST /info", s.handleInfo)
+	s.mux.HandleFunc("POST /convert", s.handleConvert)
+	return s
+}
+
+// ServeImages enables GET /img/<path> for images under sourceDir, caching
+// transformed images in cacheDir. An empty cacheDir disables the cache.
+func (s *Server) ServeImages(sourceDir, cacheDir string) error {
+	t, err := newTransformer(s, sourceDir, cacheDir)
+	if err != nil {
+		return err
+	}
+	s.mux.Handle("GET /img/{path...}", t)
+	return nil
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+type httpError struct {
+	status int
+	err    error
+}
+
+func (e *httpError) Error() string {
+	return e.err.Error()
+}
+
+func errorf(status int, format string, args ...any) error {
+	return &httpError{status: status, err: fmt.Errorf(format, args...)}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	var he *httpError
+	if errors.As(err, &he) {
+		status = he.status
+	}
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+// acquire waits for a free conversion slot or for the request to be
+// cancelled.
+func (s *Server) acquire(r *http.Request) (func(), error) {
+	return wait(r, s.sem)
+}
+
+// reserve waits for room to hold a body or source image in memory.
+func (s *Server) reserve(r *http.Request) (func(), error) {
+	return wait(r, s.bodies)
+}
+
+func wait(r *http.Request, slots chan struct{}) (func(), error) {
+	select {
+	case slots <- struct{}{}:
+		return func() { <-slots }, nil
+	case <-r.Context().Done():
+		return nil, errorf(http.StatusServiceUnavailable, "request cancelled while waiting for a free slot")
+	}
+}
+
+// readBody reads the request body within ReadTimeout. The caller holds a
+// body slot.
+func (s *Server) readBody(w http.ResponseWriter, r *http.Request) ([]byte, error) {
+	if s.cfg.ReadTimeout > 0 {
+		http.NewResponseController(w).SetReadDeadline(time.Now().Add(s.cfg.ReadTimeout))
+	}
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, s.cfg.MaxBytes))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return nil, errorf(http.StatusRequestEntityTooLarge, "request body exceeds %d bytes", s.cfg.MaxBytes)
+		}
+		if errors.Is(err, os.ErrDeadlineExceeded) {
+			return nil, errorf(http.StatusRequestTimeout, "request body not read within %s", s.cfg.ReadTimeout)
+		}
+		return nil, errorf(http.StatusBadRequest, "read body: %v", err)
+	}
+	if len(data) == 0 {
+		return nil, errorf(http.StatusBadRequest, "empty request body")
+	}
+	return data, nil
+}
+
+// inspect reads the image header and enforces the pixel limit before any
+// pixels are decoded.
+func (s *Server) inspect(data []byte) (goimage.Config, image.Format, error) {
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return cfg, "", errorf(http.StatusUnsupportedMediaType, "%v", err)
+	}
+	if s.cfg.MaxPixels > 0 && cfg.Width*cfg.Height > s.cfg.MaxPixels {
+		return cfg, "", errorf(http.StatusRequestEntityTooLarge, "image is %dx%d, limit is %d pixels", cfg.Width, cfg.Height, s.cfg.MaxPixels)
+	}
+	return cfg, format, nil
+}
+
+func (s *Server) quality(r *http.Request) (int, error) {
+	q := r.URL.Query().Get("q")
+	if q == "" {
+		return s.cfg.DefaultQuality, nil
+	}
+	quality, err := strconv.Atoi(q)
+	if err != nil || quality < 1 || quality > 100 {
+		return 0, errorf(http.StatusBadRequest, "q must be between 1 and 100")
+	}
+	return quality, nil
+}
+
+func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{
+		"status":      "ok",
+		"in_flight":   len(s.sem),
+		"concurrency": cap(s.sem),
+	})
+}
+
+type formatInfo struct {
+	Format image.Format `json:"format"`
+	MIME   string       `json:"mime"`
+	Read   bool         `json:"read"`
+	Write  bool         `json:"write"`
+}
+
+func (s *Server) handleFormats(w http.ResponseWriter, r *http.Request) {
+	formats := []formatInfo{}
+	for _, f := range image.Formats() {
+		formats = append(formats, formatInfo{
+			Format: f,
+			MIME:   f.MIMEType(),
+			Read:   image.CanDecode(f),
+			Write:  image.CanEncode(f),
+		})
+	}
+	writeJSON(w, http.StatusOK, formats)
+}
+
+func (s *Server) handleInfo(w http.ResponseWriter, r *http.Request) {
+	release, err := s.reserve(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	defer release()
+
+	data, err := s.readBody(w, r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		writeError(w, errorf(http.StatusUnsupportedMediaType, "%v", err))
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"format": format,
+		"mime":   format.MIMEType(),
+		"width":  cfg.Width,
+		"height": cfg.Height,
+		"bytes":  len(data),
+	})
+}
+
+func (s *Server) handleConvert(w http.ResponseWriter, r *http.Request) {
+	to := r.URL.Query().Get("to")
+	if to == "" {
+		writeError(w, errorf(http.StatusBadRequest, "missing to parameter"))
+		return
+	}
+	dst, err := image.FormatFromExtension("." + to)
+	if err != nil {
+		writeError(w, errorf(http.StatusBadRequest, "%v", err))
+		return
+	}
+	if !image.CanEncode(dst) {
+		writeError(w, errorf(http.StatusBadRequest, "no encoder available for %s", dst))
+		return
+	}
+	quality, err := s.quality(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	release, err := s.reserve(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	defer release()
+
+	data, err := s.readBody(w, r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if _, _, err := s.inspect(data); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	// Slow uploads only hold a body slot; a conversion slot is taken once
+	// the body is in.
+	done, err := s.acquire(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	defer done()
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		writeError(w, errorf(http.StatusUnsupportedMediaType, "%v", err))
+		return
+	}
+
+	var out bytes.Buffer
+	if err := image.Encode(&out, img, dst, quality); err != nil {
+		writeError(w, fmt.Errorf("encode image: %w", err))
+		return
+	}
+
+	w.Header().Set("Content-Type", dst.MIMEType())
+	w.Header().Set("Content-Length", strconv.Itoa(out.Len()))
+	w.Write(out.Bytes())
+}
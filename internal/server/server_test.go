@@ -0,0 +1,175 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	goimage "image"
+	"image/png"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/mahamedmuse/photon/internal/image"
+)
+
+func testPNG(t *testing.T, w, h int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, goimage.NewRGBA(goimage.Rect(0, 0, w, h))); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestConvert(t *testing.T) {
+	srv := httptest.NewServer(New(DefaultConfig()))
+	defer srv.Close()
+
+	resp, err := http.Post(srv.URL+"/convert?to=jpg&q=80", "image/png", bytes.NewReader(testPNG(t, 20, 10)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200", resp.StatusCode)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "image/jpeg" {
+		t.Errorf("Content-Type = %q, want image/jpeg", ct)
+	}
+	_, format, err := image.Decode(resp.Body)
+	if err != nil || format != image.FormatJPEG {
+		t.Errorf("expected a jpeg body, got %s (%v)", format, err)
+	}
+}
+
+func TestConvertErrors(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.MaxBytes = 4096
+	cfg.MaxPixels = 100 * 100
+	srv := httptest.NewServer(New(cfg))
+	defer srv.Close()
+
+	tests := []struct {
+		name   string
+		query  string
+		body   []byte
+		status int
+	}{
+		{"missing to", "", testPNG(t, 10, 10), http.StatusBadRequest},
+		{"unknown format", "?to=xyz", testPNG(t, 10, 10), http.StatusBadRequest},
+		{"bad quality", "?to=jpg&q=500", testPNG(t, 10, 10), http.StatusBadRequest},
+		{"not an image", "?to=jpg", []byte("hello"), http.StatusUnsupportedMediaType},
+		{"too many pixels", "?to=jpg", testPNG(t, 200, 200), http.StatusRequestEntityTooLarge},
+		{"body too large", "?to=jpg", bytes.Repeat([]byte{0}, 8192), http.StatusRequestEntityTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := http.Post(srv.URL+"/convert"+tt.query, "image/png", bytes.NewReader(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+
+			if resp.StatusCode != tt.status {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.status)
+			}
+			var body map[string]string
+			if err := json.NewDecoder(resp.Body).Decode(&body); err != nil || body["error"] == "" {
+				t.Errorf("expected json error body, got %v (%v)", body, err)
+			}
+		})
+	}
+}
+
+func TestSlowUploadsDontHoldConversionSlots(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.MaxConcurrent, cfg.MaxBodies = 1, 2
+	cfg.ReadTimeout = 200 * time.Millisecond
+	srv := httptest.NewServer(New(cfg))
+	defer srv.Close()
+
+	// The slow upload sends nothing until after the timeout.
+	body, stall := io.Pipe()
+	defer stall.Close()
+	slow := make(chan int)
+	go func() {
+		resp, err := http.Post(srv.URL+"/convert?to=jpg", "image/png", body)
+		if err != nil {
+			slow <- 0
+			return
+		}
+		resp.Body.Close()
+		slow <- resp.StatusCode
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	resp, err := http.Post(srv.URL+"/convert?to=jpg", "image/png", bytes.NewReader(testPNG(t, 10, 10)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("status = %d while another upload stalls, want 200", resp.StatusCode)
+	}
+
+	select {
+	case status := <-slow:
+		if status != http.StatusRequestTimeout {
+			t.Errorf("stalled upload status = %d, want %d", status, http.StatusRequestTimeout)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("stalled upload never timed out")
+	}
+}
+
+func TestInfo(t *testing.T) {
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/info", bytes.NewReader(testPNG(t, 30, 20)))
+	New(DefaultConfig()).ServeHTTP(rec, req)
+
+	var info struct {
+		Format string `json:"format"`
+		Width  int    `json:"width"`
+		Height int    `json:"height"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&info); err != nil {
+		t.Fatal(err)
+	}
+	if info.Format != "png" || info.Width != 30 || info.Height != 20 {
+		t.Errorf("unexpected info: %+v", info)
+	}
+}
+
+func TestFormatsAndHealth(t *testing.T) {
+	s := New(DefaultConfig())
+
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/formats", nil))
+	var formats []formatInfo
+	if err := json.NewDecoder(rec.Body).Decode(&formats); err != nil {
+		t.Fatal(err)
+	}
+	if len(formats) != len(image.Formats()) {
+		t.Errorf("expected %d formats, got %d", len(image.Formats()), len(formats))
+	}
+	for _, f := range formats {
+		if f.Format == image.FormatHEIC && f.Write {
+			t.Error("HEIC should not be writable")
+		}
+	}
+
+	rec = httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("healthz status = %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/convert", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("GET /convert status = %d, want 405", rec.Code)
+	}
+}
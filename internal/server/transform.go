@@ -0,0 +1,328 @@
+package server
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mahamedmuse/photon/internal/image"
+)
+
+// maxDimension caps the w and h parameters of /img requests.
+const maxDimension = 8192
+
+type transformOptions struct {
+	width   int
+	height  int
+	quality int
+	format  image.Format
+	// negotiated is set when format came from the Accept header, so
+	// responses must vary on it.
+	negotiated bool
+}
+
+func (o transformOptions) key(sourceHash string) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\nw=%d\nh=%d\nq=%d\nfmt=%s\n", sourceHash, o.width, o.height, o.quality, o.format)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// source is what is known about a source file at a size and modification
+// time.
+type source struct {
+	size    int64
+	modTime time.Time
+	hash    string
+	format  image.Format
+}
+
+// transformer serves GET /img/<path> from a source directory, caching
+// results on disk under a key derived from the source content and options.
+type transformer struct {
+	s        *Server
+	root     *os.Root
+	cacheDir string
+
+	mu      sync.Mutex
+	sources map[string]source
+}
+
+func newTransformer(s *Server, sourceDir, cacheDir string) (*transformer, error) {
+	root, err := os.OpenRoot(sourceDir)
+	if err != nil {
+		return nil, fmt.Errorf("open source directory: %w", err)
+	}
+	if cacheDir != "" {
+		if err := os.MkdirAll(cacheDir, 0755); err != nil {
+			root.Close()
+			return nil, fmt.Errorf("create cache directory: %w", err)
+		}
+	}
+	return &transformer{
+		s:        s,
+		root:     root,
+		cacheDir: cacheDir,
+		sources:  make(map[string]source),
+	}, nil
+}
+
+func (t *transformer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rel := filepath.FromSlash(strings.TrimPrefix(r.PathValue("path"), "/"))
+	if rel == "" || !filepath.IsLocal(rel) {
+		writeError(w, errorf(http.StatusBadRequest, "invalid image path"))
+		return
+	}
+
+	src, data, release, err := t.source(r, rel)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	defer release()
+
+	opts, err := t.options(r, src.format)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	key := opts.key(src.hash)
+	etag := `"` + key[:32] + `"`
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "public, max-age=86400")
+	if opts.negotiated {
+		w.Header().Set("Vary", "Accept")
+	}
+	if matchETag(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	if out, err := t.cached(key, opts.format); err == nil {
+		writeImage(w, out, opts.format)
+		return
+	}
+
+	if data == nil {
+		free, err := t.s.reserve(r)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		defer free()
+		if data, err = t.read(rel); err != nil {
+			writeError(w, err)
+			return
+		}
+	}
+
+	done, err := t.s.acquire(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	defer done()
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		writeError(w, errorf(http.StatusUnsupportedMediaType, "%v", err))
+		return
+	}
+	img = image.Resize(img, opts.width, opts.height)
+
+	var out bytes.Buffer
+	if err := image.Encode(&out, img, opts.format, opts.quality); err != nil {
+		writeError(w, fmt.Errorf("encode image: %w", err))
+		return
+	}
+
+	t.store(key, opts.format, out.Bytes())
+	writeImage(w, out.Bytes(), opts.format)
+}
+
+func writeImage(w http.ResponseWriter, data []byte, format image.Format) {
+	w.Header().Set("Content-Type", format.MIMEType())
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	w.Write(data)
+}
+
+func matchETag(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == etag || candidate == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// source returns the source file's hash and format. They are kept while
+// the file's size and modification time are unchanged, so the file is only
+// read, and its content returned, when they aren't known yet. The content
+// is held under a body slot until release is called.
+func (t *transformer) source(r *http.Request, rel string) (src source, data []byte, release func(), err error) {
+	release = func() {}
+	info, err := t.root.Stat(rel)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return src, nil, release, errorf(http.StatusNotFound, "image not found")
+		}
+		return src, nil, release, errorf(http.StatusBadRequest, "%v", err)
+	}
+	if info.IsDir() {
+		return src, nil, release, errorf(http.StatusNotFound, "image not found")
+	}
+	if info.Size() > t.s.cfg.MaxBytes {
+		return src, nil, release, errorf(http.StatusRequestEntityTooLarge, "source image exceeds %d bytes", t.s.cfg.MaxBytes)
+	}
+
+	t.mu.Lock()
+	src, ok := t.sources[rel]
+	t.mu.Unlock()
+	if ok && src.size == info.Size() && src.modTime.Equal(info.ModTime()) {
+		return src, nil, release, nil
+	}
+
+	free, err := t.s.reserve(r)
+	if err != nil {
+		return src, nil, release, err
+	}
+	data, err = t.read(rel)
+	if err == nil {
+		_, src.format, err = t.s.inspect(data)
+	}
+	if err != nil {
+		free()
+		return source{}, nil, release, err
+	}
+	sum := sha256.Sum256(data)
+	src = source{size: info.Size(), modTime: info.ModTime(), hash: hex.EncodeToString(sum[:]), format: src.format}
+
+	t.mu.Lock()
+	t.sources[rel] = src
+	t.mu.Unlock()
+	return src, data, free, nil
+}
+
+func (t *transformer) read(rel string) ([]byte, error) {
+	f, err := t.root.Open(rel)
+	if err != nil {
+		return nil, errorf(http.StatusInternalServerError, "open source: %v", err)
+	}
+	defer f.Close()
+
+	data, err := io.ReadAll(io.LimitReader(f, t.s.cfg.MaxBytes+1))
+	if err != nil {
+		return nil, errorf(http.StatusInternalServerError, "read source: %v", err)
+	}
+	if int64(len(data)) > t.s.cfg.MaxBytes {
+		return nil, errorf(http.StatusRequestEntityTooLarge, "source image exceeds %d bytes", t.s.cfg.MaxBytes)
+	}
+	return data, nil
+}
+
+func (t *transformer) options(r *http.Request, src image.Format) (transformOptions, error) {
+	q := r.URL.Query()
+	var opts transformOptions
+
+	for _, dim := range []struct {
+		name string
+		dst  *int
+	}{{"w", &opts.width}, {"h", &opts.height}} {
+		v := q.Get(dim.name)
+		if v == "" {
+			continue
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxDimension {
+			return opts, errorf(http.StatusBadRequest, "%s must be between 1 and %d", dim.name, maxDimension)
+		}
+		*dim.dst = n
+	}
+
+	quality, err := t.s.quality(r)
+	if err != nil {
+		return opts, err
+	}
+	opts.quality = quality
+
+	switch f := q.Get("fmt"); f {
+	case "", "auto":
+		opts.format = negotiate(r.Header.Get("Accept"), src)
+		opts.negotiated = true
+	default:
+		format, err := image.FormatFromExtension("." + f)
+		if err != nil {
+			return opts, errorf(http.StatusBadRequest, "%v", err)
+		}
+		if !image.CanEncode(format) {
+			return opts, errorf(http.StatusBadRequest, "no encoder available for %s", format)
+		}
+		opts.format = format
+	}
+	return opts, nil
+}
+
+// negotiate picks the best output format the client accepts, preferring
+// AVIF, then WebP. Clients that accept neither get PNG for sources that may
+// carry transparency and JPEG otherwise.
+func negotiate(accept string, src image.Format) image.Format {
+	for _, f := range []image.Format{image.FormatAVIF, image.FormatWebP} {
+		if image.CanEncode(f) && strings.Contains(accept, f.MIMEType()) {
+			return f
+		}
+	}
+	if src == image.FormatPNG || src == image.FormatGIF {
+		return image.FormatPNG
+	}
+	return image.FormatJPEG
+}
+
+func (t *transformer) cachePath(key string, format image.Format) string {
+	return filepath.Join(t.cacheDir, key[:2], key+"."+string(format))
+}
+
+func (t *transformer) cached(key string, format image.Format) ([]byte, error) {
+	if t.cacheDir == "" {
+		return nil, fs.ErrNotExist
+	}
+	return os.ReadFile(t.cachePath(key, format))
+}
+
+// store writes a cache entry through a temporary file so concurrent readers
+// never see a partial image. Failures only cost a cache miss.
+func (t *transformer) store(key string, format image.Format, data []byte) {
+	if t.cacheDir == "" {
+		return
+	}
+	path := t.cachePath(key, format)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+	}
+}
@@ -0,0 +1,158 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mahamedmuse/photon/internal/image"
+)
+
+func newImageServer(t *testing.T) (*Server, string, string) {
+	t.Helper()
+	src := t.TempDir()
+	cache := t.TempDir()
+	os.MkdirAll(filepath.Join(src, "photos"), 0755)
+	if err := os.WriteFile(filepath.Join(src, "photos", "a.png"), testPNG(t, 200, 100), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	s := New(DefaultConfig())
+	if err := s.ServeImages(src, cache); err != nil {
+		t.Fatal(err)
+	}
+	return s, src, cache
+}
+
+func get(s *Server, target string, header map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestImageResizeAndCache(t *testing.T) {
+	s, _, cache := newImageServer(t)
+
+	rec := get(s, "/img/photos/a.png?w=50&fmt=jpg&q=70", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body.String())
+	}
+	cfg, format, err := image.DecodeConfig(rec.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if format != image.FormatJPEG || cfg.Width != 50 || cfg.Height != 25 {
+		t.Errorf("got %s %dx%d, want jpeg 50x25", format, cfg.Width, cfg.Height)
+	}
+
+	entries, _ := filepath.Glob(filepath.Join(cache, "*", "*.jpeg"))
+	if len(entries) != 1 {
+		t.Errorf("expected one cache entry, got %v", entries)
+	}
+
+	etag := rec.Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("expected an ETag")
+	}
+	rec = get(s, "/img/photos/a.png?w=50&fmt=jpg&q=70", map[string]string{"If-None-Match": etag})
+	if rec.Code != http.StatusNotModified {
+		t.Errorf("conditional request status = %d, want 304", rec.Code)
+	}
+
+	rec = get(s, "/img/photos/a.png?w=60&fmt=jpg&q=70", nil)
+	if rec.Header().Get("ETag") == etag {
+		t.Error("different options should produce a different ETag")
+	}
+}
+
+func TestImageSkipsReadingKnownSources(t *testing.T) {
+	s, src, _ := newImageServer(t)
+	rec := get(s, "/img/photos/a.png?w=50&fmt=jpg", nil)
+	etag := rec.Header().Get("ETag")
+
+	// Garbage of the same size and modification time is never read: the
+	// conditional request and the cache hit only stat the source.
+	path := filepath.Join(src, "photos", "a.png")
+	info, _ := os.Stat(path)
+	os.WriteFile(path, make([]byte, info.Size()), 0644)
+	os.Chtimes(path, info.ModTime(), info.ModTime())
+
+	rec = get(s, "/img/photos/a.png?w=50&fmt=jpg", map[string]string{"If-None-Match": etag})
+	if rec.Code != http.StatusNotModified {
+		t.Errorf("conditional request status = %d, want 304", rec.Code)
+	}
+	rec = get(s, "/img/photos/a.png?w=50&fmt=jpg", nil)
+	if rec.Code != http.StatusOK || rec.Header().Get("ETag") != etag {
+		t.Errorf("cached request status = %d, ETag %s", rec.Code, rec.Header().Get("ETag"))
+	}
+}
+
+func TestReadsWaitForABodySlot(t *testing.T) {
+	s, _, _ := newImageServer(t)
+	for range cap(s.bodies) {
+		s.bodies <- struct{}{}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	for _, req := range []*http.Request{
+		httptest.NewRequestWithContext(ctx, http.MethodGet, "/img/photos/a.png", nil),
+		httptest.NewRequestWithContext(ctx, http.MethodPost, "/info", bytes.NewReader(testPNG(t, 10, 10))),
+		httptest.NewRequestWithContext(ctx, http.MethodPost, "/convert?to=jpg", bytes.NewReader(testPNG(t, 10, 10))),
+	} {
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, req)
+		if rec.Code != http.StatusServiceUnavailable {
+			t.Errorf("%s %s status = %d with no body slot free, want 503", req.Method, req.URL, rec.Code)
+		}
+	}
+}
+
+func TestImageNegotiation(t *testing.T) {
+	s, _, _ := newImageServer(t)
+
+	rec := get(s, "/img/photos/a.png", map[string]string{"Accept": "text/html"})
+	if ct := rec.Header().Get("Content-Type"); ct != "image/png" {
+		t.Errorf("fallback for png source = %q, want image/png", ct)
+	}
+	if rec.Header().Get("Vary") != "Accept" {
+		t.Error("negotiated responses should vary on Accept")
+	}
+
+	want := image.FormatPNG
+	if image.CanEncode(image.FormatWebP) {
+		want = image.FormatWebP
+	}
+	if got := negotiate("image/webp,*/*", image.FormatPNG); got != want {
+		t.Errorf("negotiate webp = %s, want %s", got, want)
+	}
+	if got := negotiate("", image.FormatJPEG); got != image.FormatJPEG {
+		t.Errorf("negotiate without Accept = %s, want jpeg", got)
+	}
+}
+
+func TestImageErrors(t *testing.T) {
+	s, _, _ := newImageServer(t)
+
+	tests := map[string]int{
+		"/img/photos/missing.png":  http.StatusNotFound,
+		"/img/photos":              http.StatusNotFound,
+		"/img/photos/a.png?w=0":    http.StatusBadRequest,
+		"/img/photos/a.png?q=101":  http.StatusBadRequest,
+		"/img/photos/a.png?fmt=xx": http.StatusBadRequest,
+	}
+	for target, status := range tests {
+		if rec := get(s, target, nil); rec.Code != status {
+			t.Errorf("%s: status = %d, want %d", target, rec.Code, status)
+		}
+	}
+}
@@ -0,0 +1,232 @@
+package tui
+
+import (
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// The batch basket is selectedFiles, in the order files were added, with
+// selectedSizes holding each file's size. It survives moving between
+// directories.
+
+func (m *Model) isSelected(path string) bool {
+	_, ok := m.selectedSizes[path]
+	return ok
+}
+
+func (m *Model) selectFile(path string, size int64) {
+	if m.isSelected(path) {
+		return
+	}
+	if m.selectedSizes == nil {
+		m.selectedSizes = make(map[string]int64)
+	}
+	m.selectedSizes[path] = size
+	m.selectedFiles = append(m.selectedFiles, path)
+}
+
+func (m *Model) deselectFile(path string) {
+	if !m.isSelected(path) {
+		return
+	}
+	delete(m.selectedSizes, path)
+	m.selectedFiles = slices.DeleteFunc(m.selectedFiles, func(p string) bool { return p == path })
+}
+
+func (m *Model) clearSelection() {
+	m.selectedFiles = []string{}
+	m.selectedSizes = nil
+}
+
+func (m Model) selectedBytes() int64 {
+	var n int64
+	for _, size := range m.selectedSizes {
+		n += size
+	}
+	return n
+}
+
+// imagesUnder lists the images below dir, skipping hidden files and
+// directories unless they are shown.
+func (m Model) imagesUnder(dir string) []fileEntry {
+	var images []fileEntry
+	filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if path != dir && !m.config.ShowHiddenFiles && strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if e := newFileEntry(filepath.Dir(path), d); e.isImg {
+			images = append(images, e)
+		}
+		return nil
+	})
+	return images
+}
+
+// toggleFolder selects every image under dir, or deselects them if they
+// are all selected already.
+func (m *Model) toggleFolder(dir string) {
+	images := m.imagesUnder(dir)
+	all := len(images) > 0
+	for _, e := range images {
+		all = all && m.isSelected(e.path)
+	}
+	for _, e := range images {
+		if all {
+			m.deselectFile(e.path)
+		} else {
+			m.selectFile(e.path, e.size)
+		}
+	}
+	m.menuNotice = fmt.Sprintf("%d images in %s", len(images), filepath.Base(dir))
+	if all {
+		m.menuNotice = "Deselected " + m.menuNotice
+	} else {
+		m.menuNotice = "Selected " + m.menuNotice
+	}
+}
+
+// selectGlob adds the images in the current directory whose names match
+// pattern. Patterns containing a slash match paths relative to it, and a
+// leading "**/" matches names at any depth.
+func (m *Model) selectGlob(pattern string) {
+	pattern = strings.ToLower(pattern)
+	recursive := strings.Contains(pattern, "/")
+
+	var candidates []fileEntry
+	if recursive {
+		candidates = m.imagesUnder(m.currentDir)
+	} else {
+		for _, e := range m.allFiles {
+			if e.isImg {
+				candidates = append(candidates, e)
+			}
+		}
+	}
+
+	n := 0
+	for _, e := range candidates {
+		name := strings.ToLower(e.name)
+		if rel, err := filepath.Rel(m.currentDir, e.path); err == nil && recursive {
+			name = strings.ToLower(filepath.ToSlash(rel))
+		}
+		ok, err := filepath.Match(pattern, name)
+		if err != nil {
+			m.menuNotice = "Invalid pattern: " + err.Error()
+			return
+		}
+		if !ok && strings.HasPrefix(pattern, "**/") {
+			ok, _ = filepath.Match(strings.TrimPrefix(pattern, "**/"), strings.ToLower(e.name))
+		}
+		if ok && !m.isSelected(e.path) {
+			m.selectFile(e.path, e.size)
+			n++
+		}
+	}
+	m.menuNotice = fmt.Sprintf("Selected %d images matching %s", n, pattern)
+}
+
+// updateGlobInput edits the pattern typed after "g" in the batch browser.
+func (m *Model) updateGlobInput(msg tea.KeyMsg) {
+	switch msg.Type {
+	case tea.KeyRunes, tea.KeySpace:
+		m.globPattern += string(msg.Runes)
+	case tea.KeyBackspace:
+		if r := []rune(m.globPattern); len(r) > 0 {
+			m.globPattern = string(r[:len(r)-1])
+		}
+	case tea.KeyEnter:
+		m.globbing = false
+		if m.globPattern != "" {
+			m.selectGlob(m.globPattern)
+			m.applyView()
+		}
+	case tea.KeyEsc:
+		m.globbing = false
+	}
+}
+
+func (m Model) updateBasket(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch {
+	case key.Matches(msg, m.keys.Up):
+		if m.basketIndex > 0 {
+			m.basketIndex--
+		}
+	case key.Matches(msg, m.keys.Down):
+		if m.basketIndex < len(m.selectedFiles)-1 {
+			m.basketIndex++
+		}
+	case key.Matches(msg, m.keys.Remove):
+		if m.basketIndex < len(m.selectedFiles) {
+			m.deselectFile(m.selectedFiles[m.basketIndex])
+		}
+	case key.Matches(msg, m.keys.Clear):
+		m.clearSelection()
+	case key.Matches(msg, m.keys.Continue, m.keys.Select):
+		if len(m.selectedFiles) > 0 {
+			m.config.LastInputDir = m.currentDir
+			m.openPresetPicker()
+			return m, nil
+		}
+	case key.Matches(msg, m.keys.Basket):
+		m.state = stateBatchSelect
+		m.applyView()
+		return m, nil
+	}
+
+	m.basketIndex = max(min(m.basketIndex, len(m.selectedFiles)-1), 0)
+	return m, nil
+}
+
+func (m Model) viewBasket() string {
+	var s strings.Builder
+	s.WriteString(m.styles.Title.Render("Selected Images") + "\n")
+	s.WriteString(m.styles.Subtitle.Render(m.selectionSummary()) + "\n\n")
+
+	if len(m.selectedFiles) == 0 {
+		s.WriteString(m.styles.Subtitle.Render("Nothing selected yet"))
+		return m.styles.Box.Render(s.String())
+	}
+
+	rows := max(m.height-15, 5)
+	start := max(min(m.basketIndex-rows/2, len(m.selectedFiles)-rows), 0)
+	end := min(start+rows, len(m.selectedFiles))
+	for i := start; i < end; i++ {
+		path := m.selectedFiles[i]
+		cursor, style := "  ", m.styles.ImageFile
+		if i == m.basketIndex {
+			cursor, style = m.styles.SelectedItem.Render("▸ "), m.styles.SelectedItem
+		}
+		s.WriteString(mark(zoneItem, i, cursor+style.Render(filepath.Base(path))+"  "+
+			m.styles.Subtitle.Render(formatBytes(m.selectedSizes[path])+"  "+filepath.Dir(path))) + "\n")
+	}
+	if len(m.selectedFiles) > rows {
+		s.WriteString("\n" + m.styles.Subtitle.Render(fmt.Sprintf("(%d/%d)", m.basketIndex+1, len(m.selectedFiles))))
+	}
+
+	return m.styles.Box.Render(s.String())
+}
+
+// selectionSummary is e.g. "12 images, 34.2 MB, from 3 folders".
+func (m Model) selectionSummary() string {
+	dirs := make(map[string]bool)
+	for _, path := range m.selectedFiles {
+		dirs[filepath.Dir(path)] = true
+	}
+	summary := fmt.Sprintf("%d images, %s", len(m.selectedFiles), formatBytes(m.selectedBytes()))
+	if len(dirs) > 1 {
+		summary += fmt.Sprintf(", from %d folders", len(dirs))
+	}
+	return summary
+}
@@ -0,0 +1,102 @@
+package tui
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/mahamedmuse/photon/internal/testutil"
+)
+
+func basketDir(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	for _, name := range []string{"a.png", "b.jpg", "sub/c.png", "sub/deep/d.png", "sub/.hidden/e.png", "other/f.png"} {
+		path := filepath.Join(dir, name)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		testutil.WritePNG(t, path, 4, 4)
+	}
+	os.WriteFile(filepath.Join(dir, "sub", "notes.txt"), []byte("x"), 0644)
+	return dir
+}
+
+func TestBasketAcrossDirectories(t *testing.T) {
+	dir := basketDir(t)
+	m := testModel(stateBatchSelect)
+	m.loadFiles(dir)
+
+	press := func(k string) {
+		t.Helper()
+		msg := tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(k)}
+		if k == " " {
+			msg = tea.KeyMsg{Type: tea.KeySpace, Runes: []rune(k)}
+		}
+		next, _ := m.updateBatchSelect(msg)
+		m = next.(Model)
+	}
+
+	press("a")
+	if len(m.selectedFiles) != 2 {
+		t.Fatalf("a selected %v", m.selectedFiles)
+	}
+
+	m.loadFiles(filepath.Join(dir, "other"))
+	press("a")
+	m.loadFiles(dir)
+	if len(m.selectedFiles) != 3 {
+		t.Errorf("selections from other folders were lost: %v", m.selectedFiles)
+	}
+	for _, e := range m.files {
+		if e.isImg && !e.selected {
+			t.Errorf("%s should still show as selected", e.name)
+		}
+	}
+	if m.selectedBytes() == 0 {
+		t.Error("selected bytes not counted")
+	}
+
+	press("n")
+	if !slices.Equal(m.selectedFiles, []string{filepath.Join(dir, "other", "f.png")}) {
+		t.Errorf("n should only remove the listed images, left %v", m.selectedFiles)
+	}
+}
+
+func TestBasketFolderAndGlob(t *testing.T) {
+	dir := basketDir(t)
+	m := testModel(stateBatchSelect)
+	m.loadFiles(dir)
+
+	m.toggleFolder(filepath.Join(dir, "sub"))
+	if len(m.selectedFiles) != 2 {
+		t.Errorf("selecting sub recursively gave %v", m.selectedFiles)
+	}
+	m.toggleFolder(filepath.Join(dir, "sub"))
+	if len(m.selectedFiles) != 0 {
+		t.Errorf("toggling sub again left %v", m.selectedFiles)
+	}
+
+	m.selectGlob("*.PNG")
+	if !slices.Equal(m.selectedFiles, []string{filepath.Join(dir, "a.png")}) {
+		t.Errorf("*.PNG selected %v", m.selectedFiles)
+	}
+	m.clearSelection()
+	m.selectGlob("**/*.png")
+	if len(m.selectedFiles) != 4 {
+		t.Errorf("**/*.png selected %v", m.selectedFiles)
+	}
+	m.clearSelection()
+	m.selectGlob("sub/*/*.png")
+	if !slices.Equal(m.selectedFiles, []string{filepath.Join(dir, "sub", "deep", "d.png")}) {
+		t.Errorf("sub/*/*.png selected %v", m.selectedFiles)
+	}
+
+	m.basketIndex = 0
+	next, _ := m.updateBasket(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("x")})
+	m = next.(Model)
+	if len(m.selectedFiles) != 0 || len(m.selectedSizes) != 0 {
+		t.Errorf("x left %v", m.selectedFiles)
+	}
+}
@@ -0,0 +1,289 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/mahamedmuse/photon/internal/history"
+	"github.com/mahamedmuse/photon/internal/image"
+)
+
+type jobStatus int
+
+const (
+	jobPending jobStatus = iota
+	jobRunning
+	jobDone
+	jobFailed
+	jobCancelled
+)
+
+type batchResult struct {
+	input    string
+	output   string
+	status   jobStatus
+	err      error
+	bytesIn  int64
+	bytesOut int64
+	duration time.Duration
+}
+
+// batchStartedMsg and batchFileMsg stream a running batch's progress; the
+// index is the job's position in Model.batchResults.
+type batchStartedMsg struct{ index int }
+
+type batchFileMsg struct {
+	index int
+	res   image.Result
+}
+
+type batchDoneMsg struct{}
+
+// batchJournalMsg reports that the batch's journal couldn't be opened, so
+// the batch converts but can't be resumed.
+type batchJournalMsg struct{ err error }
+
+// progressReporter forwards each converted file's result to the TUI.
+type progressReporter struct {
+	events chan<- tea.Msg
+	index  int
+}
+
+func (p *progressReporter) Report(r image.Result) {
+	p.events <- batchFileMsg{index: p.index, res: r}
+}
+
+func (p *progressReporter) Summary(image.Summary) {}
+func (p *progressReporter) Plan(image.Plan)       {}
+func (p *progressReporter) Close() error          { return nil }
+
+func (m Model) startBatch() (tea.Model, tea.Cmd) {
+	if err := os.MkdirAll(m.batchOutputDir, 0755); err != nil {
+		m.batchResults = []batchResult{{status: jobFailed, err: err}}
+		m.state = stateBatchComplete
+		return m, nil
+	}
+	m.config.LastBatchJournal = filepath.Join(m.batchOutputDir, image.JournalName)
+	m.config.Save()
+
+	jobs := m.batchJobs()
+	m.batchResults = make([]batchResult, len(jobs))
+	for i, job := range jobs {
+		m.batchResults[i] = batchResult{input: job.Input, output: job.Output}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	events := make(chan tea.Msg)
+	m.batchCancel, m.batchEvents = cancel, events
+	m.batchCancelled = false
+	m.batchJournalErr = nil
+	m.batchStart = m.now()
+	m.state = stateBatchConverting
+	m.converting = true
+	return m, tea.Batch(m.doBatchConvert(ctx, jobs, events), waitForBatch(events))
+}
+
+// doBatchConvert converts jobs one at a time, sending progress to events
+// and closing it when done. Cancelling ctx stops it before the next file;
+// the journal keeps the rest pending so the batch can be resumed.
+func (m Model) doBatchConvert(ctx context.Context, jobs []image.Job, events chan<- tea.Msg) tea.Cmd {
+	return func() tea.Msg {
+		defer close(events)
+		opts := m.convertOptions()
+		journal, err := m.openBatchJournal(jobs, opts)
+		if err != nil {
+			events <- batchJournalMsg{err: err}
+		} else {
+			defer journal.Close()
+		}
+		rec, opts := m.historyLog.Record(history.Entry{Command: "batch"}, opts)
+		defer rec.Save()
+
+		for i, job := range jobs {
+			if ctx.Err() != nil {
+				return nil
+			}
+			events <- batchStartedMsg{index: i}
+			opts.Reporter = rec.Wrap(&progressReporter{events: events, index: i})
+			err := image.Convert(job.Input, job.Output, opts)
+			if journal != nil {
+				journal.Record(job, err)
+			}
+		}
+		return nil
+	}
+}
+
+func waitForBatch(events <-chan tea.Msg) tea.Cmd {
+	return func() tea.Msg {
+		if msg, ok := <-events; ok {
+			return msg
+		}
+		return batchDoneMsg{}
+	}
+}
+
+func (m Model) updateBatchProgress(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case batchStartedMsg:
+		m.batchResults[msg.index].status = jobRunning
+		m.batchIndex = msg.index
+	case batchFileMsg:
+		r := &m.batchResults[msg.index]
+		r.status, r.err = jobDone, msg.res.Err
+		if r.err != nil {
+			r.status = jobFailed
+		}
+		r.bytesIn, r.bytesOut, r.duration = msg.res.BytesIn, msg.res.BytesOut, msg.res.Duration
+	case batchJournalMsg:
+		m.batchJournalErr = msg.err
+	case batchDoneMsg:
+		for i := range m.batchResults {
+			if r := &m.batchResults[i]; r.status == jobPending || r.status == jobRunning {
+				r.status = jobCancelled
+			}
+		}
+		m.batchCancel()
+		m.converting = false
+		m.state = stateBatchComplete
+		return m, nil
+	}
+	return m, waitForBatch(m.batchEvents)
+}
+
+// cancelBatch stops a running batch after the file being converted.
+func (m *Model) cancelBatch() {
+	if m.batchCancel != nil && !m.batchCancelled {
+		m.batchCancel()
+		m.batchCancelled = true
+	}
+}
+
+func (m Model) batchCounts() (done, failed, cancelled int) {
+	for _, r := range m.batchResults {
+		switch r.status {
+		case jobDone:
+			done++
+		case jobFailed:
+			failed++
+		case jobCancelled:
+			cancelled++
+		}
+	}
+	return done, failed, cancelled
+}
+
+func (m Model) viewBatchConverting() string {
+	var s strings.Builder
+	title := "Converting..."
+	if m.batchCancelled {
+		title = "Cancelling after the current file..."
+	}
+	s.WriteString(m.styles.Title.Render(title) + "\n\n")
+
+	done, failed, _ := m.batchCounts()
+	total := len(m.batchResults)
+	finished := done + failed
+
+	barWidth := 40
+	filled := 0
+	if total > 0 {
+		filled = finished * barWidth / total
+	}
+	s.WriteString("[" + m.styles.SliderFilled.Render(strings.Repeat("=", filled)) + m.styles.SliderTrack.Render(strings.Repeat("-", barWidth-filled)) + "]")
+	s.WriteString(fmt.Sprintf("  %d/%d", finished, total))
+	if failed > 0 {
+		s.WriteString(m.styles.Error.Render(fmt.Sprintf("  %d failed", failed)))
+	}
+	s.WriteString("  " + m.styles.Subtitle.Render(m.now().Sub(m.batchStart).Round(time.Second).String()) + "\n\n")
+
+	// Keep the file being converted in view.
+	rows := max(m.height-16, 5)
+	start := max(min(m.batchIndex-rows/2, total-rows), 0)
+	end := min(start+rows, total)
+
+	s.WriteString(m.styles.Subtitle.Render(fmt.Sprintf("   %-30s %10s %10s %8s", "File", "In", "Out", "Time")) + "\n")
+	for _, r := range m.batchResults[start:end] {
+		s.WriteString(m.viewBatchRow(r) + "\n")
+	}
+	if total > rows {
+		s.WriteString(m.styles.Subtitle.Render(fmt.Sprintf("(%d-%d of %d)", start+1, end, total)))
+	}
+
+	return m.styles.Box.Render(s.String())
+}
+
+func (m Model) viewBatchRow(r batchResult) string {
+	name := filepath.Base(r.input)
+	if len([]rune(name)) > 30 {
+		name = string([]rune(name)[:29]) + "…"
+	}
+	var icon, in, out, took string
+	switch r.status {
+	case jobPending:
+		icon = m.styles.Subtitle.Render("·")
+	case jobRunning:
+		icon = m.spinner.View()
+	case jobDone:
+		icon = m.styles.Success.Render("✓")
+	case jobFailed:
+		icon = m.styles.Error.Render("✗")
+	case jobCancelled:
+		icon = m.styles.Warning.Render("-")
+	}
+	if r.status == jobDone {
+		in, out = formatBytes(r.bytesIn), formatBytes(r.bytesOut)
+	}
+	if r.status == jobDone || r.status == jobFailed {
+		took = r.duration.Round(time.Millisecond).String()
+	}
+	return fmt.Sprintf("%s  %-30s %10s %10s %8s", icon, name, in, out, took)
+}
+
+func (m Model) viewBatchComplete() string {
+	var s strings.Builder
+
+	done, failed, cancelled := m.batchCounts()
+	switch {
+	case cancelled > 0:
+		s.WriteString(m.styles.Warning.Render("⚠ Batch Cancelled") + "\n\n")
+	case failed > 0:
+		s.WriteString(m.styles.Warning.Render("⚠ Batch Complete (with errors)") + "\n\n")
+	default:
+		s.WriteString(m.styles.Success.Render("✓ Batch Complete") + "\n\n")
+	}
+
+	s.WriteString(fmt.Sprintf("🖼  Converted: %d/%d images\n", done, len(m.batchResults)))
+	if cancelled > 0 {
+		s.WriteString(fmt.Sprintf("⏹  Cancelled: %d (resume from the menu)\n", cancelled))
+	}
+	var in, out int64
+	for _, r := range m.batchResults {
+		if r.status == jobDone {
+			in, out = in+r.bytesIn, out+r.bytesOut
+		}
+	}
+	if done > 0 {
+		s.WriteString(fmt.Sprintf("💾 Size:      %s → %s\n", formatBytes(in), formatBytes(out)))
+	}
+	s.WriteString(fmt.Sprintf("📁 Output:    %s\n\n", m.styles.Subtitle.Render(m.batchOutputDir)))
+	if m.batchJournalErr != nil {
+		s.WriteString(m.styles.Warning.Render("⚠ Can't be resumed: "+m.batchJournalErr.Error()) + "\n\n")
+	}
+
+	// Show errors if any
+	for _, r := range m.batchResults {
+		if r.status == jobFailed {
+			s.WriteString(m.styles.Error.Render("✗ ") + filepath.Base(r.input) + ": " + r.err.Error() + "\n")
+		}
+	}
+
+	s.WriteString("\nPress any key to continue...")
+
+	return m.styles.Box.Render(s.String())
+}
@@ -0,0 +1,133 @@
+package tui
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/mahamedmuse/photon/internal/config"
+	"github.com/mahamedmuse/photon/internal/image"
+	"github.com/mahamedmuse/photon/internal/testutil"
+)
+
+// runBatch starts a batch over inputs and feeds its progress back into the
+// model until it completes, calling onMsg with each message first.
+func runBatch(t *testing.T, inputs []string, onMsg func(*Model, tea.Msg)) Model {
+	t.Helper()
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	m := NewModel()
+	m.batchMode = true
+	m.selectedFiles = inputs
+	m.outputFormat = "jpg"
+	m.quality = 80
+	m.batchOutputDir = filepath.Join(t.TempDir(), "out")
+
+	next, cmd := m.startBatch()
+	m = next.(Model)
+	cmds := cmd().(tea.BatchMsg)
+	go cmds[0]()
+	wait := cmds[1]
+
+	for m.state == stateBatchConverting {
+		msg := wait()
+		if onMsg != nil {
+			onMsg(&m, msg)
+		}
+		next, cmd := m.Update(msg)
+		m = next.(Model)
+		if m.state == stateBatchConverting {
+			wait = cmd
+		}
+	}
+	return m
+}
+
+func TestBatchProgress(t *testing.T) {
+	dir := t.TempDir()
+	var inputs []string
+	for _, name := range []string{"a.png", "b.png"} {
+		testutil.WritePNG(t, filepath.Join(dir, name), 16, 16)
+		inputs = append(inputs, filepath.Join(dir, name))
+	}
+	broken := filepath.Join(dir, "c.png")
+	os.WriteFile(broken, []byte("not an image"), 0644)
+	inputs = append(inputs, broken)
+
+	var running int
+	m := runBatch(t, inputs, func(m *Model, msg tea.Msg) {
+		if _, ok := msg.(batchStartedMsg); ok {
+			running++
+		}
+	})
+
+	if running != 3 {
+		t.Errorf("got %d started messages, want 3", running)
+	}
+	done, failed, cancelled := m.batchCounts()
+	if done != 2 || failed != 1 || cancelled != 0 {
+		t.Errorf("done %d, failed %d, cancelled %d; want 2, 1, 0", done, failed, cancelled)
+	}
+	if r := m.batchResults[0]; r.bytesIn == 0 || r.bytesOut == 0 {
+		t.Errorf("missing sizes: %+v", r)
+	}
+	if _, err := os.Stat(m.batchResults[1].output); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestBatchCancel(t *testing.T) {
+	dir := t.TempDir()
+	var inputs []string
+	for _, name := range []string{"a.png", "b.png", "c.png", "d.png"} {
+		testutil.WritePNG(t, filepath.Join(dir, name), 16, 16)
+		inputs = append(inputs, filepath.Join(dir, name))
+	}
+
+	m := runBatch(t, inputs, func(m *Model, msg tea.Msg) {
+		if _, ok := msg.(batchStartedMsg); ok && !m.batchCancelled {
+			next, _ := m.Update(tea.KeyMsg{Type: tea.KeyEsc})
+			*m = next.(Model)
+			if m.state != stateBatchConverting || !m.batchCancelled {
+				t.Fatal("esc should cancel the batch, not leave the screen")
+			}
+		}
+	})
+
+	done, _, cancelled := m.batchCounts()
+	if done != 1 || cancelled != 3 {
+		t.Errorf("done %d, cancelled %d; want 1, 3", done, cancelled)
+	}
+	for _, r := range m.batchResults[1:] {
+		if _, err := os.Stat(r.output); err == nil {
+			t.Errorf("%s was written after cancelling", r.output)
+		}
+	}
+}
+
+func TestResumeLastBatchOptions(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	dir := t.TempDir()
+	testutil.WritePNG(t, filepath.Join(dir, "a.png"), 16, 16)
+	jobs := []image.Job{{Input: filepath.Join(dir, "a.png"), Output: filepath.Join(dir, "out", "a.jpg")}}
+	os.Mkdir(filepath.Join(dir, "out"), 0755)
+	journal, err := image.CreateJournal(filepath.Join(dir, "out"), jobs, image.Options{Quality: 60, Width: 8, Metadata: image.MetadataStrip})
+	if err != nil {
+		t.Fatal(err)
+	}
+	journal.Close()
+
+	m := NewModel()
+	m.config.LastBatchJournal = journal.Path
+	m.preset = &config.Preset{Quality: 90, Lossless: true, Width: 100}
+	m.resumeLastBatch()
+
+	if m.state != stateBatchConfirm || m.preset != nil {
+		t.Fatalf("state %v, preset %+v", m.state, m.preset)
+	}
+	opts := m.convertOptions()
+	if opts.Quality != 60 || opts.Width != 8 || opts.Lossless || opts.Metadata != image.MetadataStrip {
+		t.Errorf("resumed with %+v", opts)
+	}
+}
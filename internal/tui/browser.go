@@ -0,0 +1,396 @@
+package tui
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/mahamedmuse/photon/internal/image"
+)
+
+type sortKey int
+
+const (
+	sortName sortKey = iota
+	sortSize
+	sortModified
+	sortDimensions
+)
+
+func (k sortKey) String() string {
+	return [...]string{"name", "size", "modified", "dimensions"}[k]
+}
+
+// browserFilters are cycled with "f": everything, any image, then one
+// format at a time.
+var browserFilters = []string{"", "images", "png", "jpg", "gif", "webp", "bmp", "tiff", "avif", "heic"}
+
+var imageExts = map[string]bool{
+	".png": true, ".jpg": true, ".jpeg": true, ".gif": true,
+	".webp": true, ".bmp": true, ".tiff": true, ".tif": true,
+	".avif": true, ".heic": true, ".heif": true,
+}
+
+// browserRows is how many entries the current browser shows at once.
+func (m Model) browserRows() int {
+	rows := m.height - 16
+	if m.state == stateBatchSelect {
+		rows -= 2
+	}
+	return max(rows, 5)
+}
+
+// applyView rebuilds the visible entries from the directory listing with
+// the search query, filter and sort order, keeping the highlighted entry
+// when it is still listed.
+func (m *Model) applyView() {
+	var current string
+	if m.fileIndex < len(m.files) {
+		current = m.files[m.fileIndex].path
+	}
+
+	var parent, dirs, files []fileEntry
+	scores := make(map[string]int)
+	for _, e := range m.allFiles {
+		if e.name == ".." {
+			if m.query == "" {
+				parent = append(parent, e)
+			}
+			continue
+		}
+		if !e.isDir && (m.state == stateSelectOutputDir || !matchesFilter(e, m.filter)) {
+			continue
+		}
+		if m.query != "" {
+			score, ok := fuzzyScore(m.query, e.name)
+			if !ok {
+				continue
+			}
+			scores[e.path] = score
+		}
+		e.selected = m.isSelected(e.path)
+		if e.isDir {
+			dirs = append(dirs, e)
+		} else {
+			files = append(files, e)
+		}
+	}
+
+	less := func(a, b fileEntry) int {
+		if m.query != "" && scores[a.path] != scores[b.path] {
+			return scores[b.path] - scores[a.path]
+		}
+		var c int
+		switch m.sortBy {
+		case sortSize:
+			c = cmpInt64(a.size, b.size)
+		case sortModified:
+			c = a.modTime.Compare(b.modTime)
+		case sortDimensions:
+			c = cmpInt64(int64(a.width)*int64(a.height), int64(b.width)*int64(b.height))
+		}
+		if c == 0 {
+			c = strings.Compare(strings.ToLower(a.name), strings.ToLower(b.name))
+		}
+		if m.sortDesc {
+			c = -c
+		}
+		return c
+	}
+	slices.SortStableFunc(dirs, less)
+	slices.SortStableFunc(files, less)
+
+	m.files = slices.Concat(parent, dirs, files)
+	m.fileIndex, m.scrollOffset = 0, 0
+	for i, e := range m.files {
+		if e.path == current {
+			m.fileIndex = i
+		}
+	}
+	m.ensureVisible()
+}
+
+func cmpInt64(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func matchesFilter(e fileEntry, filter string) bool {
+	switch filter {
+	case "":
+		return true
+	case "images":
+		return e.isImg
+	}
+	want, err := image.FormatFromExtension("." + filter)
+	if err != nil {
+		return false
+	}
+	got, err := image.FormatFromExtension(e.name)
+	return err == nil && got == want
+}
+
+// dimensionsMsg carries the image dimensions of the files in dir, by path.
+// Files that couldn't be read are left out.
+type dimensionsMsg struct {
+	dir  string
+	dims map[string][2]int
+}
+
+// syncDimensions starts reading the dimensions of the listed images in the
+// background when the browser sorts by them; applyView sorts again once
+// they arrive.
+func (m *Model) syncDimensions() tea.Cmd {
+	if m.sortBy != sortDimensions || m.dimsLoading == m.currentDir {
+		return nil
+	}
+	switch m.state {
+	case stateSelectInput, stateBatchSelect:
+	default:
+		return nil
+	}
+	var paths []string
+	for _, e := range m.allFiles {
+		if e.isImg && !e.dimsLoaded {
+			paths = append(paths, e.path)
+		}
+	}
+	if len(paths) == 0 {
+		return nil
+	}
+
+	dir := m.currentDir
+	m.dimsLoading = dir
+	return func() tea.Msg {
+		dims := make(map[string][2]int)
+		for _, path := range paths {
+			if w, h, err := imageDimensions(path); err == nil {
+				dims[path] = [2]int{w, h}
+			}
+		}
+		return dimensionsMsg{dir: dir, dims: dims}
+	}
+}
+
+func (m *Model) setDimensions(msg dimensionsMsg) {
+	if msg.dir == m.dimsLoading {
+		m.dimsLoading = ""
+	}
+	if msg.dir != m.currentDir {
+		return
+	}
+	for i := range m.allFiles {
+		e := &m.allFiles[i]
+		if e.isImg && !e.dimsLoaded {
+			e.width, e.height = msg.dims[e.path][0], msg.dims[e.path][1]
+			e.dimsLoaded = true
+		}
+	}
+	m.applyView()
+}
+
+func imageDimensions(path string) (int, int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer f.Close()
+	cfg, _, err := image.DecodeConfig(f)
+	if err != nil {
+		return 0, 0, err
+	}
+	return cfg.Width, cfg.Height, nil
+}
+
+// fuzzyScore matches query against name as a case-insensitive
+// subsequence. Consecutive characters and matches at the start of a word
+// score higher.
+func fuzzyScore(query, name string) (int, bool) {
+	q := []rune(strings.ToLower(query))
+	n := []rune(strings.ToLower(name))
+
+	score, qi, prev := 0, 0, -2
+	for i := 0; i < len(n) && qi < len(q); i++ {
+		if n[i] != q[qi] {
+			continue
+		}
+		score++
+		if i == prev+1 {
+			score += 3
+		}
+		if i == 0 || !unicode.IsLetter(n[i-1]) && !unicode.IsDigit(n[i-1]) {
+			score += 2
+		}
+		prev = i
+		qi++
+	}
+	if qi < len(q) {
+		return 0, false
+	}
+	return score, true
+}
+
+func (m *Model) ensureVisible() {
+	rows := m.browserRows()
+	if m.fileIndex < m.scrollOffset {
+		m.scrollOffset = m.fileIndex
+	}
+	if m.fileIndex >= m.scrollOffset+rows {
+		m.scrollOffset = m.fileIndex - rows + 1
+	}
+}
+
+func (m *Model) moveTo(i int) {
+	m.fileIndex = max(min(i, len(m.files)-1), 0)
+	m.ensureVisible()
+}
+
+// updateBrowserKeys handles the navigation, search, filter and sort keys
+// shared by the file browsers. It reports whether msg was used.
+func (m *Model) updateBrowserKeys(msg tea.KeyMsg) bool {
+	if m.searching {
+		switch msg.Type {
+		case tea.KeyRunes, tea.KeySpace:
+			m.query += string(msg.Runes)
+			m.applyView()
+			return true
+		case tea.KeyBackspace:
+			if r := []rune(m.query); len(r) > 0 {
+				m.query = string(r[:len(r)-1])
+				m.applyView()
+			}
+			return true
+		case tea.KeyEnter:
+			m.searching = false
+			return true
+		}
+	}
+
+	switch {
+	case key.Matches(msg, m.keys.Up):
+		m.moveTo(m.fileIndex - 1)
+	case key.Matches(msg, m.keys.Down):
+		m.moveTo(m.fileIndex + 1)
+	case key.Matches(msg, m.keys.PageUp):
+		m.moveTo(m.fileIndex - m.browserRows())
+	case key.Matches(msg, m.keys.PageDown):
+		m.moveTo(m.fileIndex + m.browserRows())
+	case key.Matches(msg, m.keys.Home):
+		m.moveTo(0)
+	case key.Matches(msg, m.keys.End):
+		m.moveTo(len(m.files) - 1)
+	case key.Matches(msg, m.keys.Search):
+		m.searching = true
+	case key.Matches(msg, m.keys.Filter):
+		if m.state == stateSelectOutputDir {
+			return false
+		}
+		m.filter = browserFilters[(slices.Index(browserFilters, m.filter)+1)%len(browserFilters)]
+		m.applyView()
+	case key.Matches(msg, m.keys.Sort):
+		m.sortBy = (m.sortBy + 1) % (sortDimensions + 1)
+		m.applyView()
+	case key.Matches(msg, m.keys.Reverse):
+		m.sortDesc = !m.sortDesc
+		m.applyView()
+	default:
+		// Shift+letter jumps to the next entry starting with that letter.
+		r := msg.Runes
+		if msg.Type != tea.KeyRunes || len(r) != 1 || !unicode.IsUpper(r[0]) {
+			return false
+		}
+		m.jumpTo(unicode.ToLower(r[0]))
+	}
+	return true
+}
+
+func (m *Model) jumpTo(letter rune) {
+	for step := 1; step <= len(m.files); step++ {
+		i := (m.fileIndex + step) % len(m.files)
+		if first, _ := utf8.DecodeRuneInString(m.files[i].name); unicode.ToLower(first) == letter {
+			m.moveTo(i)
+			return
+		}
+	}
+}
+
+// clearSearch leaves search mode and drops the query.
+func (m *Model) clearSearch() {
+	m.searching = false
+	if m.query != "" {
+		m.query = ""
+		m.applyView()
+	}
+}
+
+// viewBrowserStatus is the search, filter and sort line under a browser's
+// title, empty when none of them is in use.
+func (m Model) viewBrowserStatus() string {
+	var parts []string
+	if m.searching || m.query != "" {
+		search := "/" + m.query
+		if m.searching {
+			search += "▏"
+		}
+		parts = append(parts, search)
+	}
+	if m.filter != "" && m.state != stateSelectOutputDir {
+		parts = append(parts, "filter: "+m.filter)
+	}
+	if m.sortBy != sortName || m.sortDesc {
+		order := "↑"
+		if m.sortDesc {
+			order = "↓"
+		}
+		parts = append(parts, fmt.Sprintf("sort: %s %s", m.sortBy, order))
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return m.styles.Warning.Render(strings.Join(parts, " • ")) + "\n"
+}
+
+// viewEntryDetail shows the attribute the list is sorted by after an
+// entry's name.
+func (m Model) viewEntryDetail(e fileEntry) string {
+	var detail string
+	switch {
+	case e.isDir:
+	case m.sortBy == sortSize:
+		detail = formatBytes(e.size)
+	case m.sortBy == sortModified:
+		detail = e.modTime.Format("2006-01-02 15:04")
+	case m.sortBy == sortDimensions && e.width > 0:
+		detail = fmt.Sprintf("%d×%d", e.width, e.height)
+	}
+	if detail == "" {
+		return ""
+	}
+	return "  " + m.styles.Subtitle.Render(detail)
+}
+
+func newFileEntry(dir string, e os.DirEntry) fileEntry {
+	entry := fileEntry{
+		name:  e.Name(),
+		path:  filepath.Join(dir, e.Name()),
+		isDir: e.IsDir(),
+	}
+	if !e.IsDir() {
+		entry.isImg = imageExts[strings.ToLower(filepath.Ext(e.Name()))]
+		if info, err := e.Info(); err == nil {
+			entry.size, entry.modTime = info.Size(), info.ModTime()
+		}
+	}
+	return entry
+}
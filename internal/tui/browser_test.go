@@ -0,0 +1,139 @@
+package tui
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/mahamedmuse/photon/internal/testutil"
+)
+
+func names(files []fileEntry) []string {
+	var n []string
+	for _, f := range files {
+		n = append(n, f.name)
+	}
+	return n
+}
+
+func keys(m *Model, ks ...string) {
+	for _, k := range ks {
+		var msg tea.KeyMsg
+		switch k {
+		case "enter":
+			msg = tea.KeyMsg{Type: tea.KeyEnter}
+		case "backspace":
+			msg = tea.KeyMsg{Type: tea.KeyBackspace}
+		case "end":
+			msg = tea.KeyMsg{Type: tea.KeyEnd}
+		case "pgdown":
+			msg = tea.KeyMsg{Type: tea.KeyPgDown}
+		default:
+			msg = tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(k)}
+		}
+		m.updateBrowserKeys(msg)
+	}
+}
+
+func browserDir(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	testutil.WritePNG(t, filepath.Join(dir, "big.png"), 64, 64)
+	testutil.WritePNG(t, filepath.Join(dir, "small.png"), 4, 4)
+	testutil.WritePNG(t, filepath.Join(dir, "wide.jpg"), 80, 8)
+	os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("hello"), 0644)
+	os.Mkdir(filepath.Join(dir, "zdir"), 0755)
+	now := time.Now()
+	for _, name := range []string{"big.png", "small.png", "notes.txt"} {
+		os.Chtimes(filepath.Join(dir, name), now, now)
+	}
+	os.Chtimes(filepath.Join(dir, "wide.jpg"), now.Add(-time.Hour), now.Add(-time.Hour))
+	return dir
+}
+
+func TestFuzzyScore(t *testing.T) {
+	if _, ok := fuzzyScore("bgp", "big.png"); !ok {
+		t.Error("bgp should match big.png")
+	}
+	if _, ok := fuzzyScore("pb", "big.png"); ok {
+		t.Error("pb should not match big.png")
+	}
+	prefix, _ := fuzzyScore("sm", "small.png")
+	scattered, _ := fuzzyScore("sm", "has_mango.png")
+	if prefix <= scattered {
+		t.Errorf("prefix match scored %d, scattered %d", prefix, scattered)
+	}
+}
+
+func TestBrowserFilterAndSort(t *testing.T) {
+	m := testModel(stateSelectInput)
+	m.loadFiles(browserDir(t))
+
+	check := func(want ...string) {
+		t.Helper()
+		if got := names(m.files); !slices.Equal(got, want) {
+			t.Errorf("got %v, want %v", got, want)
+		}
+	}
+	check("..", "zdir", "big.png", "notes.txt", "small.png", "wide.jpg")
+
+	keys(&m, "f")
+	check("..", "zdir", "big.png", "small.png", "wide.jpg")
+	keys(&m, "f", "f")
+	check("..", "zdir", "wide.jpg")
+	m.filter = ""
+
+	keys(&m, "o")
+	for i := 3; i < len(m.files); i++ {
+		if m.files[i].size < m.files[i-1].size {
+			t.Errorf("not sorted by size: %v", names(m.files))
+		}
+	}
+	keys(&m, "o")
+	check("..", "zdir", "wide.jpg", "big.png", "notes.txt", "small.png")
+	keys(&m, "o", "r")
+	// Dimensions are read in the background and sort the list on arrival.
+	if m.files[2].dimsLoaded {
+		t.Error("dimensions were read while handling the key")
+	}
+	m.setDimensions(m.syncDimensions()().(dimensionsMsg))
+	check("..", "zdir", "big.png", "wide.jpg", "small.png", "notes.txt")
+	if m.syncDimensions() != nil {
+		t.Error("dimensions are read again")
+	}
+}
+
+func TestBrowserSearchAndNavigation(t *testing.T) {
+	m := testModel(stateSelectInput)
+	m.loadFiles(browserDir(t))
+
+	keys(&m, "/", "p", "n", "g")
+	if got := names(m.files); !slices.Equal(got, []string{"big.png", "small.png"}) {
+		t.Errorf("search for png listed %v", got)
+	}
+	keys(&m, "backspace", "backspace", "backspace", "w", "enter")
+	if got := names(m.files); !slices.Equal(got, []string{"wide.jpg"}) || m.searching {
+		t.Errorf("search for w listed %v, still searching: %v", got, m.searching)
+	}
+	m.clearSearch()
+	if len(m.files) != 6 {
+		t.Errorf("clearing the search left %d entries", len(m.files))
+	}
+
+	keys(&m, "end")
+	if m.files[m.fileIndex].name != "wide.jpg" {
+		t.Errorf("end moved to %s", m.files[m.fileIndex].name)
+	}
+	keys(&m, "S")
+	if m.files[m.fileIndex].name != "small.png" {
+		t.Errorf("S jumped to %s", m.files[m.fileIndex].name)
+	}
+	keys(&m, "pgdown")
+	if m.fileIndex != len(m.files)-1 {
+		t.Errorf("page down moved to %d", m.fileIndex)
+	}
+}
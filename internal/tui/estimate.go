@@ -0,0 +1,166 @@
+package tui
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	goimage "image"
+	"image/draw"
+	"math"
+	"os"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/mahamedmuse/photon/internal/image"
+)
+
+// estimateDelay is how long the quality slider has to rest before the
+// sample is encoded again.
+const estimateDelay = 300 * time.Millisecond
+
+// estimatePixels is the size, in pixels, of the scaled down copy the size
+// and metrics are estimated on.
+const estimatePixels = 512 * 512
+
+// estimate is the outcome of encoding a sample image at the chosen settings.
+type estimate struct {
+	sample     string
+	inputSize  int64
+	outputSize int64
+
+	// compared is false when the output format can't be decoded back, in
+	// which case only the size is known.
+	compared   bool
+	psnr, ssim float64
+
+	before, after string
+}
+
+type estimateTickMsg struct{ key string }
+
+type estimateMsg struct {
+	key string
+	est estimate
+	err error
+}
+
+// estimator keeps the last decoded sample so moving the slider only costs
+// an encode.
+type estimator struct {
+	mu  sync.Mutex
+	key string
+	img goimage.Image
+}
+
+func (e *estimator) source(path string) (goimage.Image, int64, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	key := fmt.Sprintf("%s|%d|%d", path, info.Size(), info.ModTime().UnixNano())
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.key == key {
+		return e.img, info.Size(), nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer f.Close()
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return nil, 0, fmt.Errorf("decode image: %w", err)
+	}
+	e.key, e.img = key, img
+	return img, info.Size(), nil
+}
+
+// run estimates converting the image at path to format with opts. The size
+// and metrics come from encoding a copy scaled down to estimatePixels and
+// are scaled back up; the before and after views encode the centre of the
+// image at full resolution, cols x rows cells of it, where compression
+// artefacts show. Cancelling ctx stops it between steps.
+func (e *estimator) run(ctx context.Context, path string, format image.Format, opts image.Options, cols, rows int) (estimate, error) {
+	src, size, err := e.source(path)
+	if err != nil {
+		return estimate{}, err
+	}
+	b := src.Bounds()
+	w, h := image.FitSize(b.Dx(), b.Dy(), opts.Width, opts.Height)
+	if w == 0 || h == 0 {
+		return estimate{}, fmt.Errorf("image is empty")
+	}
+	sw, sh := w, h
+	if w*h > estimatePixels {
+		scale := math.Sqrt(float64(estimatePixels) / float64(w*h))
+		sw, sh = max(int(float64(w)*scale), 1), max(int(float64(h)*scale), 1)
+	}
+
+	sample := image.Resize(src, sw, sh)
+	if err := ctx.Err(); err != nil {
+		return estimate{}, err
+	}
+	var buf bytes.Buffer
+	if err := image.Encode(&buf, sample, format, opts.Quality); err != nil {
+		return estimate{}, fmt.Errorf("encode image: %w", err)
+	}
+	sb := sample.Bounds()
+	est := estimate{
+		sample:     path,
+		inputSize:  size,
+		outputSize: int64(float64(buf.Len()) * float64(w*h) / float64(sb.Dx()*sb.Dy())),
+	}
+
+	out, _, err := image.Decode(&buf)
+	if err != nil {
+		return est, nil
+	}
+	if err := ctx.Err(); err != nil {
+		return estimate{}, err
+	}
+	est.compared = true
+	est.psnr, est.ssim = image.PSNR(sample, out), image.SSIM(sample, out)
+	if err := ctx.Err(); err != nil {
+		return estimate{}, err
+	}
+
+	// The centre of the output, taken from the source and scaled like the
+	// whole image would be.
+	r := centerRect(b, cols*b.Dx()/w, rows*2*b.Dy()/h)
+	before := image.Resize(crop(src, r), cols, rows*2)
+	buf.Reset()
+	if err := image.Encode(&buf, before, format, opts.Quality); err != nil {
+		return estimate{}, fmt.Errorf("encode image: %w", err)
+	}
+	after, _, err := image.Decode(&buf)
+	if err != nil {
+		return est, nil
+	}
+	cb := before.Bounds()
+	est.before = halfBlocks(before, cb.Dx(), cb.Dy()/2, cols, rows)
+	est.after = halfBlocks(after, cb.Dx(), cb.Dy()/2, cols, rows)
+	return est, nil
+}
+
+func centerRect(b goimage.Rectangle, w, h int) goimage.Rectangle {
+	w, h = min(w, b.Dx()), min(h, b.Dy())
+	p := b.Min.Add(goimage.Pt((b.Dx()-w)/2, (b.Dy()-h)/2))
+	return goimage.Rectangle{Min: p, Max: p.Add(goimage.Pt(w, h))}
+}
+
+func crop(img goimage.Image, r goimage.Rectangle) goimage.Image {
+	dst := goimage.NewRGBA(goimage.Rect(0, 0, r.Dx(), r.Dy()))
+	draw.Draw(dst, dst.Bounds(), img, r.Min, draw.Src)
+	return dst
+}
+
+func runEstimate(ctx context.Context, e *estimator, key, path string, format image.Format, opts image.Options, cols, rows int) tea.Cmd {
+	return func() tea.Msg {
+		est, err := e.run(ctx, path, format, opts, cols, rows)
+		return estimateMsg{key: key, est: est, err: err}
+	}
+}
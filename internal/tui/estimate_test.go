@@ -0,0 +1,71 @@
+package tui
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/mahamedmuse/photon/internal/image"
+	"github.com/mahamedmuse/photon/internal/testutil"
+)
+
+func TestEstimate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "a.png")
+	testutil.WritePNG(t, path, 120, 80)
+
+	var e estimator
+	low, err := e.run(context.Background(), path, image.FormatJPEG, image.Options{Quality: 10}, 20, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	high, err := e.run(context.Background(), path, image.FormatJPEG, image.Options{Quality: 95}, 20, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if low.outputSize == 0 || low.outputSize >= high.outputSize {
+		t.Errorf("quality 10 gave %d bytes, quality 95 %d", low.outputSize, high.outputSize)
+	}
+	if !high.compared || high.psnr <= low.psnr || high.ssim <= 0 || high.ssim > 1 {
+		t.Errorf("unexpected metrics: low %+v, high %+v", low, high)
+	}
+	for _, view := range []string{high.before, high.after} {
+		lines := strings.Split(view, "\n")
+		if len(lines) != 4 || lipgloss.Width(lines[0]) != 20 {
+			t.Errorf("crop is %d lines of %d cells, want 4 of 20", len(lines), lipgloss.Width(lines[0]))
+		}
+	}
+
+	resized, err := e.run(context.Background(), path, image.FormatPNG, image.Options{Width: 30}, 20, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resized.outputSize >= high.inputSize {
+		t.Errorf("resized output is %d bytes, input %d", resized.outputSize, high.inputSize)
+	}
+}
+
+func TestEstimateLargeImage(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "a.png")
+	testutil.WritePNG(t, path, 1600, 1200)
+
+	var e estimator
+	est, err := e.run(context.Background(), path, image.FormatPNG, image.Options{}, 20, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The estimate is scaled up from a quarter-megapixel copy.
+	if est.outputSize < est.inputSize/2 || est.outputSize > est.inputSize*2 {
+		t.Errorf("estimated %d bytes for a %d byte PNG", est.outputSize, est.inputSize)
+	}
+	if lines := strings.Split(est.before, "\n"); len(lines) != 4 || lipgloss.Width(lines[0]) != 20 {
+		t.Errorf("crop is %d lines of %d cells, want 4 of 20", len(lines), lipgloss.Width(lines[0]))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := e.run(ctx, path, image.FormatPNG, image.Options{}, 20, 4); err != context.Canceled {
+		t.Errorf("cancelled run returned %v", err)
+	}
+}
@@ -0,0 +1,112 @@
+package tui
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mahamedmuse/photon/internal/config"
+	"github.com/mahamedmuse/photon/internal/testutil"
+)
+
+func estimated(m Model) bool {
+	return !m.estimating && m.est.sample == m.estimateSample()
+}
+
+func TestFlowConvert(t *testing.T) {
+	h := newHarness(t, nil)
+	testutil.WritePNG(t, filepath.Join("pictures", "photo.png"), 32, 24)
+	os.WriteFile(filepath.Join("pictures", "notes.txt"), []byte("notes"), 0644)
+	h.snapshot("menu")
+
+	h.press("enter")
+	h.snapshot("browser")
+
+	h.press("down", "down", "enter")
+	h.snapshot("preset")
+
+	h.press("enter")
+	h.snapshot("format")
+
+	h.press("right", "right", "enter") // WEBP → AVIF → JPG
+	h.waitFor("the estimate", estimated)
+	h.snapshot("quality")
+
+	h.press("left", "enter")
+	h.snapshot("output")
+
+	h.press("enter")
+	h.snapshot("confirm")
+
+	h.press("y")
+	h.waitFor("the conversion", func(m Model) bool { return m.state == stateComplete })
+	h.snapshot("complete")
+
+	if h.m.convErr != nil {
+		t.Fatal(h.m.convErr)
+	}
+	if _, err := os.Stat(h.m.outputFile); err != nil {
+		t.Errorf("output not written: %v", err)
+	}
+	recent := h.config().RecentFiles
+	if len(recent) != 1 || recent[0].Quality != h.m.quality || !recent[0].ConvertedAt.Equal(harnessTime) {
+		t.Errorf("recent files = %+v", recent)
+	}
+}
+
+func TestFlowBatch(t *testing.T) {
+	h := newHarness(t, nil)
+	for _, name := range []string{"a.png", "b.png", "c.png"} {
+		testutil.WritePNG(t, filepath.Join("pictures", name), 16, 16)
+	}
+
+	h.press("down", "enter")
+	h.snapshot("select")
+
+	// Pick a and c, then open the basket.
+	h.press("down", "space", "down", "down", "space", "b")
+	h.snapshot("basket")
+
+	h.press("c", "enter", "enter")
+	h.waitFor("the estimate", estimated)
+	h.press("enter")
+	h.snapshot("confirm")
+
+	h.press("p")
+	h.snapshot("plan")
+
+	h.press("y")
+	h.waitFor("the batch", func(m Model) bool { return m.state == stateBatchComplete })
+
+	dir := filepath.Join("out", "batch_webp_2026-01-02_15-04-05")
+	for _, name := range []string{"a.webp", "c.webp"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("%s not converted: %v", name, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "b.webp")); err == nil {
+		t.Error("b.png was converted without being selected")
+	}
+}
+
+func TestFlowSettings(t *testing.T) {
+	h := newHarness(t, func(cfg *config.Config) { cfg.DefaultQuality = 80 })
+
+	h.press("down", "down", "down", "down", "down", "enter")
+	h.snapshot("settings")
+
+	// Quality 80 → 90, format webp → tiff, overwrite off, theme light.
+	h.press("right", "right", "down", "right", "right", "down", "down", "down", "down", "enter", "down", "right")
+	h.snapshot("changed")
+
+	h.press("down", "enter")
+	if h.m.state != stateMenu {
+		t.Fatalf("Back left the TUI in state %v", h.m.state)
+	}
+
+	cfg := h.config()
+	if cfg.DefaultQuality != 90 || cfg.DefaultFormat != "tiff" || cfg.ConfirmOverwrite || cfg.Theme != "light" {
+		t.Errorf("saved quality %d, format %s, confirm overwrite %v, theme %s",
+			cfg.DefaultQuality, cfg.DefaultFormat, cfg.ConfirmOverwrite, cfg.Theme)
+	}
+}
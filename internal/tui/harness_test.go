@@ -0,0 +1,172 @@
+package tui
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/spinner"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/x/ansi"
+	"github.com/mahamedmuse/photon/internal/config"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// testdata is resolved before the harness changes directory.
+var testdata, _ = filepath.Abs("testdata")
+
+// harnessTime is the harness clock, so batch folders and recent files get
+// the same names on every run.
+var harnessTime = time.Date(2026, 1, 2, 15, 4, 5, 0, time.UTC)
+
+// harness drives a Model the way tea.Program does, without a terminal: it
+// feeds messages to Update and runs the commands that come back. The
+// model works in a temporary directory that is also the working directory,
+// so the paths on screen are relative and the snapshots are the same on
+// every machine:
+//
+//	config/config.json  the user config
+//	state/              the history
+//	pictures/           where the browsers start
+//	out/                the output directory
+type harness struct {
+	t    *testing.T
+	m    Model
+	msgs chan tea.Msg
+	done chan struct{}
+	step int
+}
+
+// newHarness writes the config, lets setup change it first, and opens the
+// TUI on an 80x40 terminal at the main menu.
+func newHarness(t *testing.T, setup func(*config.Config)) *harness {
+	t.Helper()
+	root := t.TempDir()
+	t.Chdir(root)
+	os.Mkdir("pictures", 0755)
+
+	path := filepath.Join("config", "config.json")
+	cfg, err := config.LoadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.LastInputDir, cfg.OutputDir = "pictures", "out"
+	if setup != nil {
+		setup(&cfg)
+	}
+	if err := cfg.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	h := &harness{
+		t:    t,
+		m:    NewModelIn(Env{ConfigPath: path, HistoryDir: "state", WorkDir: root, Now: func() time.Time { return harnessTime }}),
+		msgs: make(chan tea.Msg),
+		done: make(chan struct{}),
+	}
+	t.Cleanup(func() { close(h.done) })
+	h.send(tea.WindowSizeMsg{Width: 80, Height: 40})
+	return h
+}
+
+// config reloads the config the TUI saved.
+func (h *harness) config() config.Config {
+	h.t.Helper()
+	cfg, err := config.LoadFile(filepath.Join("config", "config.json"))
+	if err != nil {
+		h.t.Fatal(err)
+	}
+	return cfg
+}
+
+func (h *harness) send(msgs ...tea.Msg) {
+	for _, msg := range msgs {
+		next, cmd := h.m.Update(msg)
+		h.m = next.(Model)
+		h.run(cmd)
+	}
+}
+
+// press sends each key, named as in the key bindings: "enter", "down",
+// "space", "y".
+func (h *harness) press(keys ...string) {
+	for _, k := range keys {
+		if k == "space" {
+			k = " "
+		}
+		msg, _ := keyMsg(key.NewBinding(key.WithKeys(k)))
+		h.send(msg)
+	}
+}
+
+func (h *harness) run(cmd tea.Cmd) {
+	if cmd == nil {
+		return
+	}
+	go func() {
+		select {
+		case h.msgs <- cmd():
+		case <-h.done:
+		}
+	}()
+}
+
+// waitFor handles the messages the commands send until cond holds.
+// Spinner ticks are dropped, which keeps the spinners still.
+func (h *harness) waitFor(what string, cond func(Model) bool) {
+	h.t.Helper()
+	timeout := time.After(30 * time.Second)
+	for !cond(h.m) {
+		select {
+		case msg := <-h.msgs:
+			switch msg := msg.(type) {
+			case tea.BatchMsg:
+				for _, cmd := range msg {
+					h.run(cmd)
+				}
+			case spinner.TickMsg:
+			default:
+				h.send(msg)
+			}
+		case <-timeout:
+			h.t.Fatalf("timed out waiting for %s; screen:\n%s", what, h.screen())
+		}
+	}
+}
+
+var trailingSpace = regexp.MustCompile(`(?m)[ \t]+$`)
+
+// screen is the view as the terminal shows it, without colors.
+func (h *harness) screen() string {
+	return trailingSpace.ReplaceAllString(ansi.Strip(h.m.View()), "")
+}
+
+// snapshot compares the screen with testdata/<test>/<n>-<name>.golden,
+// numbered in the order the test takes them. Run the tests with -update
+// to write the files.
+func (h *harness) snapshot(name string) {
+	h.t.Helper()
+	h.step++
+	path := filepath.Join(testdata, h.t.Name(), fmt.Sprintf("%02d-%s.golden", h.step, name))
+	got := h.screen()
+	if *update {
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := os.WriteFile(path, []byte(got), 0644); err != nil {
+			h.t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		h.t.Fatalf("%v (run with -update to create it)", err)
+	}
+	if got != string(want) {
+		h.t.Errorf("screen differs from %s:\n--- got\n%s\n--- want\n%s", path, got, want)
+	}
+}
@@ -0,0 +1,174 @@
+package tui
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/mahamedmuse/photon/internal/history"
+	"github.com/mahamedmuse/photon/internal/image"
+)
+
+// historyDoneMsg reports a re-run or undo started from the history screen.
+type historyDoneMsg struct {
+	notice string
+	err    error
+}
+
+// openHistory loads the history, newest first, and shows it.
+func (m *Model) openHistory() {
+	if m.historyLog == nil {
+		m.menuNotice = "History is unavailable"
+		return
+	}
+	entries, err := m.historyLog.Load()
+	if err != nil {
+		m.menuNotice = err.Error()
+		return
+	}
+	if len(entries) == 0 {
+		m.menuNotice = "No conversions recorded yet"
+		return
+	}
+	slices.Reverse(entries)
+	m.historyEntries = entries
+	m.historyIndex = 0
+	m.historyNotice, m.undoArmed = "", ""
+	m.state = stateHistory
+}
+
+func (m Model) updateHistory(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	if m.historyBusy {
+		return m, nil
+	}
+	if !key.Matches(msg, m.keys.Undo) {
+		m.undoArmed = ""
+	}
+
+	switch {
+	case key.Matches(msg, m.keys.Up):
+		if m.historyIndex > 0 {
+			m.historyIndex--
+		}
+	case key.Matches(msg, m.keys.Down):
+		if m.historyIndex < len(m.historyEntries)-1 {
+			m.historyIndex++
+		}
+	case key.Matches(msg, m.keys.Select, m.keys.Rerun):
+		e := m.historyEntries[m.historyIndex]
+		m.historyBusy = true
+		m.historyNotice = fmt.Sprintf("Converting %d files again...", len(e.Files))
+		return m, rerunHistory(m.historyLog, e)
+	case key.Matches(msg, m.keys.Undo):
+		e := m.historyEntries[m.historyIndex]
+		if e.Undone {
+			m.historyNotice = "Already undone"
+			break
+		}
+		if m.undoArmed != e.ID {
+			m.undoArmed = e.ID
+			m.historyNotice = fmt.Sprintf("Press %s again to remove %d outputs and restore the files they replaced", m.keys.Undo.Help().Key, len(e.Files))
+			break
+		}
+		m.undoArmed = ""
+		m.historyBusy = true
+		return m, undoHistory(m.historyLog, e)
+	}
+	return m, nil
+}
+
+func rerunHistory(log *history.Log, e history.Entry) tea.Cmd {
+	return func() tea.Msg {
+		rec, opts := log.Record(history.Entry{Command: "rerun", Recipe: e.Recipe}, e.Options.Apply(image.DefaultOptions()))
+		err := history.Rerun(e, opts)
+		rec.Save()
+		return historyDoneMsg{notice: fmt.Sprintf("Converted %d files again", len(e.Files)), err: err}
+	}
+}
+
+func undoHistory(log *history.Log, e history.Entry) tea.Cmd {
+	return func() tea.Msg {
+		err := log.Undo(e)
+		return historyDoneMsg{notice: fmt.Sprintf("Undid %d files", len(e.Files)), err: err}
+	}
+}
+
+// historyDone shows the outcome of a re-run or undo and reloads the list,
+// keeping the highlighted entry.
+func (m Model) historyDone(msg historyDoneMsg) (tea.Model, tea.Cmd) {
+	m.historyBusy = false
+	m.historyNotice = msg.notice
+	if msg.err != nil {
+		m.historyNotice = strings.SplitN(msg.err.Error(), "\n", 2)[0]
+	}
+
+	id := m.historyEntries[m.historyIndex].ID
+	if entries, err := m.historyLog.Load(); err == nil {
+		slices.Reverse(entries)
+		m.historyEntries = entries
+		m.historyIndex = max(slices.IndexFunc(entries, func(e history.Entry) bool { return e.ID == id }), 0)
+	}
+	return m, nil
+}
+
+func (m Model) historyStatus(e history.Entry) string {
+	switch {
+	case e.Undone:
+		return m.styles.Warning.Render("undone")
+	case e.Failed() > 0:
+		return m.styles.Error.Render(fmt.Sprintf("%d failed", e.Failed()))
+	}
+	return m.styles.Success.Render("✓")
+}
+
+func (m Model) viewHistory() string {
+	var s strings.Builder
+	s.WriteString(m.styles.Title.Render("History") + "\n\n")
+
+	rows := max(m.height-22, 5)
+	start := max(min(m.historyIndex-rows/2, len(m.historyEntries)-rows), 0)
+	end := min(start+rows, len(m.historyEntries))
+	for i := start; i < end; i++ {
+		e := m.historyEntries[i]
+		cursor, style := "  ", m.styles.Item
+		if i == m.historyIndex {
+			cursor, style = m.styles.SelectedItem.Render("▸ "), m.styles.SelectedItem
+		}
+		line := style.Render(fmt.Sprintf("%s  %-8s %4d files", e.Time.Local().Format("2006-01-02 15:04"), e.Command, len(e.Files)))
+		s.WriteString(mark(zoneItem, i, cursor+line+"  "+m.historyStatus(e)) + "\n")
+	}
+
+	e := m.historyEntries[m.historyIndex]
+	s.WriteString("\n" + m.styles.Subtitle.Render(e.ID) + "\n")
+	if e.Recipe != "" {
+		s.WriteString(m.styles.Subtitle.Render("Recipe "+e.Recipe) + "\n")
+	} else {
+		settings := fmt.Sprintf("Quality %d", e.Options.Quality)
+		if e.Options.Width > 0 || e.Options.Height > 0 {
+			settings += fmt.Sprintf(", fit %dx%d", e.Options.Width, e.Options.Height)
+		}
+		s.WriteString(m.styles.Subtitle.Render(settings) + "\n")
+	}
+	const shown = 5
+	for _, f := range e.Files[:min(shown, len(e.Files))] {
+		line := filepath.Base(f.Input) + " → " + f.Output
+		switch {
+		case f.Error != "":
+			line = m.styles.Error.Render("✗ ") + line
+		case f.Backup != "":
+			line += m.styles.Subtitle.Render("  (replaced)")
+		}
+		s.WriteString("  " + line + "\n")
+	}
+	if len(e.Files) > shown {
+		s.WriteString(m.styles.Subtitle.Render(fmt.Sprintf("  ... and %d more", len(e.Files)-shown)) + "\n")
+	}
+
+	if m.historyNotice != "" {
+		s.WriteString("\n" + m.styles.Warning.Render(m.historyNotice))
+	}
+	return m.styles.Box.Render(s.String())
+}
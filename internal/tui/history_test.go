@@ -0,0 +1,46 @@
+package tui
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mahamedmuse/photon/internal/testutil"
+)
+
+func TestHistoryUndo(t *testing.T) {
+	dir := t.TempDir()
+	var inputs []string
+	for _, name := range []string{"a.png", "b.png"} {
+		testutil.WritePNG(t, filepath.Join(dir, name), 16, 16)
+		inputs = append(inputs, filepath.Join(dir, name))
+	}
+	m := runBatch(t, inputs, nil)
+
+	m.state, m.menuIndex = stateMenu, 3
+	m = send(m, enter)
+	if m.state != stateHistory || len(m.historyEntries) != 1 || len(m.historyEntries[0].Files) != 2 {
+		t.Fatalf("state %v, entries %+v", m.state, m.historyEntries)
+	}
+
+	m = send(m, typeText("u")...)
+	if m.undoArmed == "" {
+		t.Fatal("first u should ask for confirmation")
+	}
+	next, cmd := m.updateHistory(typeText("u")[0])
+	m = next.(Model)
+	if cmd == nil || !m.historyBusy {
+		t.Fatal("second u should start the undo")
+	}
+	next, _ = m.Update(cmd())
+	m = next.(Model)
+
+	if !m.historyEntries[0].Undone || m.historyBusy {
+		t.Errorf("entry not marked undone: %+v, notice %q", m.historyEntries[0], m.historyNotice)
+	}
+	for _, r := range m.batchResults {
+		if _, err := os.Stat(r.output); !os.IsNotExist(err) {
+			t.Errorf("%s still exists after undo", r.output)
+		}
+	}
+}
@@ -0,0 +1,220 @@
+package tui
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/help"
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// keyMap holds every key binding of the TUI. The "keys" setting rebinds
+// them by action name, see keyMap.actions.
+type keyMap struct {
+	Up, Down, Left, Right       key.Binding
+	PageUp, PageDown, Home, End key.Binding
+	Select, Back, Quit          key.Binding
+
+	// File browsers
+	Search, Filter, Sort, Reverse, Hidden, UseFolder key.Binding
+
+	// Batch selection and the basket
+	Toggle, AddAll, RemoveAll, Glob, Basket, Continue, Remove, Clear key.Binding
+
+	// Confirmations and running batches
+	Yes, No, Plan, Cancel key.Binding
+
+	// Recent files, history and favorites
+	OpenFolder, Rerun, Undo, MoveUp, MoveDown key.Binding
+
+	// Output step
+	NextToOriginal, ChooseFolder, FreeName key.Binding
+}
+
+func defaultKeyMap() keyMap {
+	return keyMap{
+		Up:       binding("up", "up", "k"),
+		Down:     binding("down", "down", "j"),
+		Left:     binding("left", "left", "h"),
+		Right:    binding("right", "right", "l"),
+		PageUp:   binding("page up", "pgup", "ctrl+b"),
+		PageDown: binding("page down", "pgdown", "ctrl+f"),
+		Home:     binding("first", "home"),
+		End:      binding("last", "end"),
+		Select:   binding("select", "enter"),
+		Back:     binding("back", "esc"),
+		Quit:     binding("quit", "q", "ctrl+c"),
+
+		Search:    binding("search", "/"),
+		Filter:    binding("filter", "f"),
+		Sort:      binding("sort", "o"),
+		Reverse:   binding("reverse", "r"),
+		Hidden:    binding("toggle hidden", "tab"),
+		UseFolder: binding("select current", "s"),
+
+		Toggle:    binding("toggle", " "),
+		AddAll:    binding("add listed", "a"),
+		RemoveAll: binding("remove listed", "n"),
+		Glob:      binding("select by pattern", "g"),
+		Basket:    binding("review selection", "b"),
+		Continue:  binding("continue", "c"),
+		Remove:    binding("remove", "x", "delete"),
+		Clear:     binding("clear all", "X"),
+
+		Yes:    binding("confirm", "y"),
+		No:     binding("cancel", "n"),
+		Plan:   binding("preview plan", "p"),
+		Cancel: binding("cancel the remaining files", "esc", "c"),
+
+		OpenFolder: binding("open folder", "o"),
+		Rerun:      binding("run again", "r"),
+		Undo:       binding("undo", "u"),
+		MoveUp:     binding("move up", "shift+up", "K"),
+		MoveDown:   binding("move down", "shift+down", "J"),
+
+		NextToOriginal: binding("next to original", "tab"),
+		ChooseFolder:   binding("choose folder", "ctrl+o"),
+		FreeName:       binding("free name", "ctrl+r"),
+	}
+}
+
+// actions names the bindings for the "keys" setting.
+func (k *keyMap) actions() map[string]*key.Binding {
+	return map[string]*key.Binding{
+		"up": &k.Up, "down": &k.Down, "left": &k.Left, "right": &k.Right,
+		"page_up": &k.PageUp, "page_down": &k.PageDown, "home": &k.Home, "end": &k.End,
+		"select": &k.Select, "back": &k.Back, "quit": &k.Quit,
+		"search": &k.Search, "filter": &k.Filter, "sort": &k.Sort, "reverse": &k.Reverse,
+		"toggle_hidden": &k.Hidden, "use_folder": &k.UseFolder,
+		"toggle": &k.Toggle, "add_all": &k.AddAll, "remove_all": &k.RemoveAll, "glob": &k.Glob,
+		"basket": &k.Basket, "continue": &k.Continue, "remove": &k.Remove, "clear": &k.Clear,
+		"yes": &k.Yes, "no": &k.No, "plan": &k.Plan, "cancel": &k.Cancel,
+		"open_folder": &k.OpenFolder, "rerun": &k.Rerun, "undo": &k.Undo,
+		"move_up": &k.MoveUp, "move_down": &k.MoveDown,
+		"next_to_original": &k.NextToOriginal, "choose_folder": &k.ChooseFolder, "free_name": &k.FreeName,
+	}
+}
+
+// newKeyMap applies the "keys" setting to the defaults. Unknown actions
+// are reported and skipped.
+func newKeyMap(overrides map[string][]string) (keyMap, error) {
+	k := defaultKeyMap()
+	actions := k.actions()
+	var unknown []string
+	for name, keys := range overrides {
+		b, ok := actions[name]
+		if !ok {
+			unknown = append(unknown, name)
+			continue
+		}
+		keys = slices.Clone(keys)
+		for i, s := range keys {
+			if s == "space" {
+				keys[i] = " "
+			}
+		}
+		*b = binding(b.Help().Desc, keys...)
+	}
+	if len(unknown) > 0 {
+		slices.Sort(unknown)
+		return k, fmt.Errorf("keys: unknown action %s", strings.Join(unknown, ", "))
+	}
+	return k, nil
+}
+
+// binding binds keys to an action, labelled in the help by the first key.
+// Without keys the action is disabled and left out of the help.
+func binding(desc string, keys ...string) key.Binding {
+	if len(keys) == 0 {
+		return key.NewBinding(key.WithHelp("", desc), key.WithDisabled())
+	}
+	return key.NewBinding(key.WithKeys(keys...), key.WithHelp(keyLabel(keys[0]), desc))
+}
+
+func keyLabel(k string) string {
+	switch k {
+	case "up":
+		return "↑"
+	case "down":
+		return "↓"
+	case "left":
+		return "←"
+	case "right":
+		return "→"
+	case " ":
+		return "space"
+	}
+	return k
+}
+
+// as is b with another description, for screens where it means more than
+// its default help says.
+func as(b key.Binding, desc string) key.Binding {
+	b.SetHelp(b.Help().Key, desc)
+	return b
+}
+
+// pair shows two bindings as one help entry, e.g. "↑/↓ navigate".
+func pair(a, b key.Binding, desc string) key.Binding {
+	return key.NewBinding(
+		key.WithKeys(append(a.Keys(), b.Keys()...)...),
+		key.WithHelp(a.Help().Key+"/"+b.Help().Key, desc),
+	)
+}
+
+// helpKeys lists the bindings shown in the footer for the current screen.
+func (m Model) helpKeys() []key.Binding {
+	k := m.keys
+	nav := pair(k.Up, k.Down, "navigate")
+	sort := pair(k.Sort, k.Reverse, "sort")
+	switch m.state {
+	case stateMenu:
+		return []key.Binding{nav, k.Select, k.Quit}
+	case stateSelectOutput:
+		return []key.Binding{k.NextToOriginal, k.ChooseFolder, k.FreeName, as(k.Select, "continue"), k.Back}
+	case stateSelectInput:
+		return []key.Binding{nav, k.Select, k.Search, k.Filter, sort, k.Hidden, k.Back}
+	case stateSelectPreset:
+		return []key.Binding{nav, k.Select, k.Back}
+	case stateRecentFiles:
+		return []key.Binding{nav, as(k.Select, "convert again"), k.OpenFolder, k.Remove, k.Back}
+	case stateHistory:
+		return []key.Binding{nav, as(k.Select, "run again"), k.Undo, k.Back}
+	case stateSelectFormat:
+		return []key.Binding{pair(k.Left, k.Right, "select format"), as(k.Select, "confirm"), k.Back}
+	case stateQuality:
+		return []key.Binding{pair(k.Left, k.Right, "adjust quality"), as(k.Select, "confirm"), k.Back}
+	case stateSettings:
+		return []key.Binding{nav, pair(k.Left, k.Right, "adjust"), as(k.Select, "toggle"), k.Back}
+	case stateFavorites:
+		return []key.Binding{nav, as(k.Toggle, "toggle favorite"), pair(k.MoveUp, k.MoveDown, "move up/down"), as(k.Select, "done")}
+	case stateSelectOutputDir:
+		return []key.Binding{nav, as(k.Select, "open dir"), k.UseFolder, k.Search, sort, k.Back}
+	case stateBatchSelect:
+		return []key.Binding{nav, as(k.Toggle, "select file/folder"), pair(k.AddAll, k.RemoveAll, "add/remove listed"),
+			k.Glob, k.Basket, k.Continue, k.Search, k.Filter, sort, k.Back}
+	case stateBasket:
+		return []key.Binding{nav, k.Remove, k.Clear, k.Continue, as(k.Basket, "back to files")}
+	case stateBatchConverting:
+		return []key.Binding{k.Cancel}
+	case stateBatchConfirm:
+		return []key.Binding{k.Yes, k.Plan, k.No}
+	case stateBatchPlan:
+		return []key.Binding{pair(k.Up, k.Down, "scroll"), k.Yes, as(k.Plan, "back"), k.No}
+	}
+	return []key.Binding{as(k.Back, "back to menu"), k.Quit}
+}
+
+func (m Model) viewHelp() string {
+	h := help.New()
+	h.Styles = m.styles.help
+	return m.styles.Help.Render(h.ShortHelpView(m.helpKeys()))
+}
+
+// typing reports whether msg is text being typed into a field, so that
+// single-letter bindings such as quit don't fire.
+func (m Model) typing(msg tea.KeyMsg) bool {
+	return msg.Type == tea.KeyRunes && (m.searching || m.state == stateSelectOutput)
+}
@@ -0,0 +1,117 @@
+package tui
+
+import (
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/mahamedmuse/photon/internal/config"
+)
+
+func TestKeyMapOverrides(t *testing.T) {
+	k, err := newKeyMap(map[string][]string{
+		"quit":   {"ctrl+q"},
+		"toggle": {"space", "x"},
+		"undo":   {},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key.Matches(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("q")}, k.Quit) {
+		t.Error("q still quits")
+	}
+	if !key.Matches(tea.KeyMsg{Type: tea.KeyCtrlQ}, k.Quit) || k.Quit.Help().Key != "ctrl+q" {
+		t.Errorf("quit = %v, %q", k.Quit.Keys(), k.Quit.Help().Key)
+	}
+	if !key.Matches(tea.KeyMsg{Type: tea.KeySpace, Runes: []rune(" ")}, k.Toggle) || k.Toggle.Help().Key != "space" {
+		t.Errorf("toggle = %q", k.Toggle.Keys())
+	}
+	if k.Undo.Enabled() {
+		t.Error("an empty list should unbind undo")
+	}
+	if k.Up.Help().Key != "↑" || !key.Matches(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("k")}, k.Up) {
+		t.Error("up lost its default keys")
+	}
+
+	if _, err := newKeyMap(map[string][]string{"jump": {"J"}}); err == nil || !strings.Contains(err.Error(), "jump") {
+		t.Errorf("unknown action error = %v", err)
+	}
+}
+
+func TestKeyMapActionsCoverEveryBinding(t *testing.T) {
+	k := defaultKeyMap()
+	if n := reflect.TypeFor[keyMap]().NumField(); len(k.actions()) != n {
+		t.Errorf("%d actions for %d bindings", len(k.actions()), n)
+	}
+}
+
+func TestHelpFollowsBindings(t *testing.T) {
+	m := testModel(stateMenu)
+	m.keys, _ = newKeyMap(map[string][]string{"quit": {"ctrl+q"}})
+
+	help := m.viewHelp()
+	if !strings.Contains(help, "ctrl+q quit") || !strings.Contains(help, "↑/↓ navigate") {
+		t.Errorf("help = %q", help)
+	}
+}
+
+func TestModelsKeepTheirOwnKeysAndStyles(t *testing.T) {
+	t.Setenv("NO_COLOR", "")
+	dir := t.TempDir()
+	newModel := func(name string, setup func(*config.Config)) Model {
+		path := filepath.Join(dir, name, "config.json")
+		cfg, err := config.LoadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		setup(&cfg)
+		if err := cfg.Save(); err != nil {
+			t.Fatal(err)
+		}
+		return NewModelIn(Env{ConfigPath: path})
+	}
+
+	a := newModel("a", func(cfg *config.Config) { cfg.Keys = map[string][]string{"quit": {"ctrl+q"}} })
+	b := newModel("b", func(cfg *config.Config) { cfg.Theme = "nord" })
+
+	if keys := a.keys.Quit.Keys(); !slices.Equal(keys, []string{"ctrl+q"}) {
+		t.Errorf("a quits on %v", keys)
+	}
+	if keys := b.keys.Quit.Keys(); slices.Contains(keys, "ctrl+q") {
+		t.Errorf("b quits on %v", keys)
+	}
+	if a.styles.Logo.GetForeground() != themes["default"].primary {
+		t.Error("b's theme changed a's styles")
+	}
+	if b.styles.Logo.GetForeground() != themes["nord"].primary {
+		t.Error("b doesn't use its own theme")
+	}
+}
+
+// testModel is a bare Model with the default keys and styles and the real
+// clock.
+func testModel(s state) Model {
+	return Model{state: s, height: 40, now: time.Now, keys: defaultKeyMap(), styles: newStyles(themes["default"])}
+}
+
+func TestThemes(t *testing.T) {
+	for _, name := range config.Themes {
+		if _, ok := themes[name]; !ok {
+			t.Errorf("no theme %q", name)
+		}
+	}
+
+	t.Setenv("NO_COLOR", "")
+	if themeFor("nord") != themes["nord"] || themeFor("missing") != themes["default"] {
+		t.Error("themeFor picked the wrong theme")
+	}
+	t.Setenv("NO_COLOR", "1")
+	if !themeFor("nord").mono {
+		t.Error("NO_COLOR should pick the mono theme")
+	}
+}
@@ -1,16 +1,22 @@
 package tui
 
 import (
+	"context"
 	"fmt"
+	"math"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"time"
 
+	"github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/bubbles/spinner"
+	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 	"github.com/mahamedmuse/photon/internal/config"
+	"github.com/mahamedmuse/photon/internal/history"
 	"github.com/mahamedmuse/photon/internal/image"
 )
 
@@ -20,6 +26,7 @@ const (
 	stateMenu state = iota
 	stateSelectInput
 	stateSelectOutput
+	stateSelectPreset
 	stateSelectFormat
 	stateQuality
 	stateConfirm
@@ -28,43 +35,119 @@ const (
 	stateSettings
 	stateSelectOutputDir
 	stateBatchSelect
+	stateBasket
 	stateBatchConfirm
+	stateBatchPlan
 	stateBatchConverting
 	stateBatchComplete
+	stateRecentFiles
+	stateFavorites
+	stateHistory
 )
 
+// outputFormats are the formats offered by the format picker and settings.
+var outputFormats = []string{"png", "jpg", "gif", "webp", "bmp", "tiff", "avif"}
+
 type fileEntry struct {
 	name     string
 	path     string
 	isDir    bool
 	isImg    bool
 	selected bool
+
+	size          int64
+	modTime       time.Time
+	width, height int
+	dimsLoaded    bool
+}
+
+// Env is where the TUI keeps what outlives it. NewModel uses the user's;
+// tests point it at a temporary directory.
+type Env struct {
+	// ConfigPath is the user config file.
+	ConfigPath string
+	// HistoryDir holds the history log and its backups; empty keeps no
+	// history.
+	HistoryDir string
+	// WorkDir is where the project config is looked up from.
+	WorkDir string
+	// Now names batch folders and dates recent files.
+	Now func() time.Time
 }
 
 type Model struct {
 	state         state
 	config        config.Config
+	configErr     error
 	width, height int
+	now           func() time.Time
+	keys          keyMap
+	styles        styles
 
 	// Menu
-	menuIndex int
-	menuItems []string
+	menuIndex  int
+	menuItems  []string
+	menuNotice string
 
 	// File browser
 	currentDir   string
+	allFiles     []fileEntry
 	files        []fileEntry
 	fileIndex    int
+	query        string
+	searching    bool
+	filter       string
+	sortBy       sortKey
+	sortDesc     bool
+	dimsLoading  string
 	inputFile    string
 	outputFile   string
 	scrollOffset int
 
+	// Preview
+	preview     *previewer
+	previewKey  string
+	previewView string
+	previewErr  error
+
+	// Output step
+	outputDir      string
+	outputName     textinput.Model
+	nextToOriginal bool
+	overwritePath  string
+	outputNotice   string
+	dirPickerFor   state
+
+	// Preset selection
+	presetNames []string
+	presetIndex int
+	preset      *config.Preset
+	presetName  string
+
+	// Recent files
+	recentIndex int
+
+	// History
+	historyLog     *history.Log
+	historyEntries []history.Entry
+	historyIndex   int
+	historyBusy    bool
+	historyNotice  string
+	undoArmed      string
+
 	// Format selection
 	formats      []string
 	formatIndex  int
 	outputFormat string
 
 	// Quality
-	quality int
+	quality        int
+	estimator      *estimator
+	estimateKey    string
+	estimating     bool
+	estimateCancel context.CancelFunc
+	est            estimate
+	estimateErr    error
 
 	// Conversion
 	spinner    spinner.Model
@@ -73,43 +156,105 @@ type Model struct {
 	convDone   bool
 
 	// Settings
-	settingIndex int
+	settingIndex  int
+	favoriteIndex int
 
 	// Batch mode
-	batchMode      bool
-	selectedFiles  []string
-	batchOutputDir string
-	batchResults   []batchResult
-	batchIndex     int
+	batchMode       bool
+	selectedFiles   []string
+	selectedSizes   map[string]int64
+	basketIndex     int
+	globbing        bool
+	globPattern     string
+	batchOutputDir  string
+	batchResults    []batchResult
+	batchIndex      int
+	batchCancel     context.CancelFunc
+	batchCancelled  bool
+	batchJournalErr error
+	batchEvents     <-chan tea.Msg
+	batchStart      time.Time
+	batchPlan       image.Plan
+	planOffset      int
+
+	// resumeJobs and resumeOptions are the pending jobs of a resumed
+	// batch and the settings it started with.
+	resumeJobs    []image.Job
+	resumeOptions image.Options
+
+	// Mouse
+	lastClick click
+	dragging  bool
+	// dragZone is the slider being dragged, found when the drag started.
+	dragZone zone
 }
 
-type batchResult struct {
-	input   string
-	output  string
-	success bool
-	err     error
+// NewModel returns the TUI for the user's config and working directory.
+func NewModel() Model {
+	path, err := config.Path()
+	historyDir, _ := history.Dir()
+	wd, _ := os.Getwd()
+	m := NewModelIn(Env{ConfigPath: path, HistoryDir: historyDir, WorkDir: wd, Now: time.Now})
+	if err != nil {
+		m.configErr = err
+	}
+	return m
 }
 
-func NewModel() Model {
-	cfg, _ := config.Load()
+// NewModelIn returns the TUI working in env.
+func NewModelIn(env Env) Model {
+	cfg, configErr := config.LoadFile(env.ConfigPath)
+	if configErr == nil && env.WorkDir != "" {
+		_, configErr = cfg.LoadProject(env.WorkDir)
+	}
+	if configErr == nil {
+		configErr = cfg.Validate()
+	}
+	if cfg.DefaultQuality < 1 || cfg.DefaultQuality > 100 {
+		cfg.DefaultQuality = config.DefaultConfig().DefaultQuality
+	}
+	st := newStyles(themeFor(cfg.Theme))
+	keys, keysErr := newKeyMap(cfg.Keys)
+	if configErr == nil {
+		configErr = keysErr
+	}
 
 	s := spinner.New()
 	s.Spinner = spinner.Dot
-	s.Style = lipgloss.NewStyle().Foreground(lipgloss.Color("#7C3AED"))
+	s.Style = st.Spinner
+
+	var historyLog *history.Log
+	if env.HistoryDir != "" {
+		historyLog = history.In(env.HistoryDir)
+		historyLog.Limit = cfg.HistoryLimit
+		historyLog.Off = !cfg.History
+	}
+	if env.Now == nil {
+		env.Now = time.Now
+	}
 
 	return Model{
 		state:     stateMenu,
 		config:    cfg,
+		configErr: configErr,
+		now:       env.Now,
+		keys:      keys,
+		styles:    st,
 		menuIndex: 0,
 		menuItems: []string{
 			"🖼  Convert Image",
 			"📚 Batch Convert",
 			"🕐 Recent Files",
+			"📜 History",
+			"↻  Resume Last Batch",
 			"⚙  Settings",
 			"🚪 Quit",
 		},
-		formats:    []string{"png", "jpg", "gif", "webp", "bmp", "tiff", "avif"},
+		formats:    outputFormats,
 		quality:    cfg.DefaultQuality,
+		preview:    newPreviewer(detectPreviewProtocol()),
+		estimator:  &estimator{},
+		historyLog: historyLog,
 		spinner:    s,
 		currentDir: cfg.LastInputDir,
 	}
@@ -120,15 +265,76 @@ func (m Model) Init() tea.Cmd {
 }
 
 func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	next, cmd := m.update(msg)
+	nm := next.(Model)
+	cmd = tea.Batch(cmd, nm.syncPreview(), nm.syncEstimate(), nm.syncDimensions())
+	return nm, cmd
+}
+
+func (m Model) update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
+	case dimensionsMsg:
+		m.setDimensions(msg)
+		return m, nil
+
+	case previewTickMsg:
+		// The cursor may have moved on while the tick was pending.
+		if msg.key != m.previewKey {
+			return m, nil
+		}
+		cols, rows := m.previewSize()
+		return m, renderPreview(m.preview, msg.key, msg.path, cols, rows)
+
+	case previewMsg:
+		if msg.key == m.previewKey {
+			m.previewView = msg.view
+			m.previewErr = msg.err
+		}
+		return m, nil
+
+	case estimateTickMsg:
+		if msg.key != m.estimateKey {
+			return m, nil
+		}
+		format, err := image.FormatFromExtension("." + m.outputFormat)
+		if err != nil {
+			m.estimating, m.estimateErr = false, err
+			return m, nil
+		}
+		cols, rows := m.estimateSize()
+		ctx, cancel := context.WithCancel(context.Background())
+		m.estimateCancel = cancel
+		return m, runEstimate(ctx, m.estimator, msg.key, m.estimateSample(), format, m.convertOptions(), cols, rows)
+
+	case estimateMsg:
+		if msg.key == m.estimateKey {
+			m.estimating = false
+			m.est, m.estimateErr = msg.est, msg.err
+		}
+		return m, nil
+
+	case tea.MouseMsg:
+		return m.updateMouse(msg)
+
 	case tea.WindowSizeMsg:
 		m.width = msg.Width
 		m.height = msg.Height
 		return m, nil
 
 	case tea.KeyMsg:
-		switch msg.String() {
-		case "ctrl+c", "q":
+		if m.globbing {
+			m.updateGlobInput(msg)
+			return m, nil
+		}
+		if m.state == stateBatchConverting {
+			if key.Matches(msg, m.keys.Cancel, m.keys.Quit) {
+				m.cancelBatch()
+			}
+			return m, nil
+		}
+
+		switch {
+		case key.Matches(msg, m.keys.Quit) && !m.typing(msg):
 			if m.state == stateMenu {
 				m.config.Save()
 				return m, tea.Quit
@@ -136,7 +342,20 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.state = stateMenu
 			return m, nil
 
-		case "esc":
+		case key.Matches(msg, m.keys.Back):
+			if m.searching || m.query != "" {
+				m.clearSearch()
+				return m, nil
+			}
+			if m.state == stateSelectOutputDir && m.dirPickerFor == stateSelectOutput {
+				m.state = stateSelectOutput
+				return m, nil
+			}
+			if m.state == stateBasket {
+				m.state = stateBatchSelect
+				m.applyView()
+				return m, nil
+			}
 			if m.state != stateMenu {
 				m.state = stateMenu
 			}
@@ -147,9 +366,11 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		case stateMenu:
 			return m.updateMenu(msg)
 		case stateSelectInput:
-			return m.updateFileBrowser(msg, true)
+			return m.updateFileBrowser(msg)
 		case stateSelectOutput:
-			return m.updateFileBrowser(msg, false)
+			return m.updateOutputStep(msg)
+		case stateSelectPreset:
+			return m.updatePresetSelect(msg)
 		case stateSelectFormat:
 			return m.updateFormatSelect(msg)
 		case stateQuality:
@@ -158,12 +379,22 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			return m.updateConfirm(msg)
 		case stateSettings:
 			return m.updateSettings(msg)
+		case stateFavorites:
+			return m.updateFavorites(msg)
 		case stateSelectOutputDir:
 			return m.updateOutputDirBrowser(msg)
 		case stateBatchSelect:
 			return m.updateBatchSelect(msg)
+		case stateBasket:
+			return m.updateBasket(msg)
 		case stateBatchConfirm:
 			return m.updateBatchConfirm(msg)
+		case stateBatchPlan:
+			return m.updateBatchPlan(msg)
+		case stateRecentFiles:
+			return m.updateRecentFiles(msg)
+		case stateHistory:
+			return m.updateHistory(msg)
 		case stateBatchComplete:
 			if msg.String() != "" {
 				m.state = stateMenu
@@ -182,32 +413,41 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.convErr = msg.err
 		m.state = stateComplete
 		if msg.err == nil {
-			m.config.AddRecentFile(m.inputFile)
+			m.config.AddRecentFile(config.RecentFile{
+				Path:        m.inputFile,
+				Output:      m.outputFile,
+				Format:      m.outputFormat,
+				Quality:     m.quality,
+				Preset:      m.presetName,
+				ConvertedAt: m.now(),
+			})
 			m.config.Save()
 		}
 		return m, nil
 
-	case batchDoneMsg:
-		m.converting = false
-		m.batchResults = msg.results
-		m.state = stateBatchComplete
-		return m, nil
+	case batchStartedMsg, batchFileMsg, batchDoneMsg:
+		return m.updateBatchProgress(msg)
+
+	case historyDoneMsg:
+		return m.historyDone(msg)
 	}
 
 	return m, nil
 }
 
 func (m Model) updateMenu(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
-	switch msg.String() {
-	case "up", "k":
+	m.menuNotice = ""
+
+	switch {
+	case key.Matches(msg, m.keys.Up):
 		if m.menuIndex > 0 {
 			m.menuIndex--
 		}
-	case "down", "j":
+	case key.Matches(msg, m.keys.Down):
 		if m.menuIndex < len(m.menuItems)-1 {
 			m.menuIndex++
 		}
-	case "enter":
+	case key.Matches(msg, m.keys.Select):
 		switch m.menuIndex {
 		case 0: // Convert Image
 			m.batchMode = false
@@ -215,12 +455,24 @@ func (m Model) updateMenu(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 			m.state = stateSelectInput
 		case 1: // Batch Convert
 			m.batchMode = true
-			m.selectedFiles = []string{}
+			m.clearSelection()
+			m.resumeJobs = nil
 			m.loadFiles(m.currentDir)
 			m.state = stateBatchSelect
-		case 3: // Settings
+		case 2: // Recent Files
+			if len(m.config.RecentFiles) == 0 {
+				m.menuNotice = "No recent files"
+				break
+			}
+			m.recentIndex = 0
+			m.state = stateRecentFiles
+		case 3: // History
+			m.openHistory()
+		case 4: // Resume Last Batch
+			m.resumeLastBatch()
+		case 5: // Settings
 			m.state = stateSettings
-		case 4: // Quit
+		case 6: // Quit
 			m.config.Save()
 			return m, tea.Quit
 		}
@@ -228,235 +480,428 @@ func (m Model) updateMenu(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	return m, nil
 }
 
+// resumeLastBatch loads the pending jobs of the last batch's journal and
+// moves to the batch confirm step.
+func (m *Model) resumeLastBatch() {
+	path := m.config.LastBatchJournal
+	if path == "" {
+		m.menuNotice = "No batch to resume"
+		return
+	}
+
+	journal, err := image.OpenJournal(path)
+	if err != nil {
+		m.menuNotice = err.Error()
+		return
+	}
+	pending := journal.Pending()
+	journal.Close()
+
+	if len(pending) == 0 {
+		m.menuNotice = "Last batch already completed"
+		return
+	}
+
+	m.batchMode = true
+	m.resumeJobs = pending
+	m.resumeOptions = journal.Options(image.Options{})
+	m.batchOutputDir = filepath.Dir(path)
+	m.preset = nil
+	m.quality = journal.Quality
+	m.outputFormat = strings.TrimPrefix(filepath.Ext(pending[0].Output), ".")
+	m.clearSelection()
+	for _, job := range pending {
+		var size int64
+		if info, err := os.Stat(job.Input); err == nil {
+			size = info.Size()
+		}
+		m.selectFile(job.Input, size)
+	}
+	m.state = stateBatchConfirm
+}
+
 func (m *Model) loadFiles(dir string) {
 	m.currentDir = dir
-	m.files = []fileEntry{}
+	m.allFiles = []fileEntry{}
+	m.files = nil
 	m.fileIndex = 0
 	m.scrollOffset = 0
+	m.query, m.searching = "", false
 
 	if dir != "/" {
-		m.files = append(m.files, fileEntry{
+		m.allFiles = append(m.allFiles, fileEntry{
 			name:  "..",
 			path:  filepath.Dir(dir),
 			isDir: true,
 		})
 	}
 
-	entries, err := os.ReadDir(dir)
-	if err != nil {
-		return
+	entries, _ := os.ReadDir(dir)
+	for _, e := range entries {
+		if !m.config.ShowHiddenFiles && strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
+		m.allFiles = append(m.allFiles, newFileEntry(dir, e))
 	}
+	m.applyView()
+}
 
-	imageExts := map[string]bool{
-		".png": true, ".jpg": true, ".jpeg": true, ".gif": true,
-		".webp": true, ".bmp": true, ".tiff": true, ".tif": true,
-		".avif": true, ".heic": true, ".heif": true,
+func (m Model) updateFileBrowser(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	if m.updateBrowserKeys(msg) {
+		return m, nil
 	}
 
-	var dirs, files []fileEntry
-	for _, e := range entries {
-		if !m.config.ShowHiddenFiles && strings.HasPrefix(e.Name(), ".") {
-			continue
+	switch {
+	case key.Matches(msg, m.keys.Select):
+		if m.fileIndex < len(m.files) {
+			entry := m.files[m.fileIndex]
+			if entry.isDir {
+				m.loadFiles(entry.path)
+			} else if entry.isImg {
+				m.inputFile = entry.path
+				m.config.LastInputDir = m.currentDir
+				m.openPresetPicker()
+			}
 		}
+	case key.Matches(msg, m.keys.Hidden):
+		m.config.ShowHiddenFiles = !m.config.ShowHiddenFiles
+		m.loadFiles(m.currentDir)
+	}
+	return m, nil
+}
 
-		entry := fileEntry{
-			name:  e.Name(),
-			path:  filepath.Join(dir, e.Name()),
-			isDir: e.IsDir(),
+func (m Model) updateRecentFiles(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	m.menuNotice = ""
+	recent := m.config.RecentFiles
+	if len(recent) == 0 {
+		m.state = stateMenu
+		return m, nil
+	}
+
+	switch {
+	case key.Matches(msg, m.keys.Up):
+		if m.recentIndex > 0 {
+			m.recentIndex--
+		}
+	case key.Matches(msg, m.keys.Down):
+		if m.recentIndex < len(recent)-1 {
+			m.recentIndex++
 		}
+	case key.Matches(msg, m.keys.Select):
+		m.rerunRecent(recent[m.recentIndex])
+	case key.Matches(msg, m.keys.OpenFolder):
+		m.openRecentFolder(recent[m.recentIndex])
+	case key.Matches(msg, m.keys.Remove):
+		m.config.RemoveRecentFile(recent[m.recentIndex].Path)
+		m.config.Save()
+		if m.recentIndex >= len(m.config.RecentFiles) && m.recentIndex > 0 {
+			m.recentIndex--
+		}
+		if len(m.config.RecentFiles) == 0 {
+			m.state = stateMenu
+		}
+	}
+	return m, nil
+}
+
+// rerunRecent converts r again with the settings and output it was last
+// converted with, or goes through the pickers when they weren't recorded.
+// An output that exists now goes through the output step's overwrite
+// prompt when the config asks for it.
+func (m *Model) rerunRecent(r config.RecentFile) {
+	if _, err := os.Stat(r.Path); err != nil {
+		m.menuNotice = "File no longer exists"
+		return
+	}
+
+	m.batchMode = false
+	m.inputFile = r.Path
+	if r.Format == "" {
+		m.openPresetPicker()
+		return
+	}
 
-		if !e.IsDir() {
-			ext := strings.ToLower(filepath.Ext(e.Name()))
-			entry.isImg = imageExts[ext]
+	m.preset = nil
+	m.presetName = ""
+	if r.Preset != "" {
+		if p, err := m.config.Preset(r.Preset); err == nil {
+			m.preset = &p
+			m.presetName = r.Preset
 		}
+	}
+	m.outputFormat = r.Format
+	m.quality = r.Quality
+	if m.quality == 0 {
+		m.quality = m.config.DefaultQuality
+	}
+	m.outputFile = r.Output
+	if info, err := os.Stat(filepath.Dir(r.Output)); r.Output == "" || err != nil || !info.IsDir() {
+		m.resolveOutputFile()
+	}
+	if outputExists(m.outputFile) && m.config.ConfirmOverwrite {
+		m.editOutput()
+		return
+	}
+	m.state = stateConfirm
+}
 
-		if e.IsDir() {
-			dirs = append(dirs, entry)
-		} else {
-			files = append(files, entry)
+// openRecentFolder shows r's folder in the file browser with r selected.
+func (m *Model) openRecentFolder(r config.RecentFile) {
+	dir := filepath.Dir(r.Path)
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		m.menuNotice = "Folder no longer exists"
+		return
+	}
+
+	m.batchMode = false
+	m.loadFiles(dir)
+	for i, f := range m.files {
+		if f.path == r.Path {
+			m.fileIndex = i
 		}
 	}
+	m.state = stateSelectInput
+	m.ensureVisible()
+}
 
-	m.files = append(m.files, dirs...)
-	m.files = append(m.files, files...)
+// openPresetPicker lists "Custom" followed by the configured presets.
+// It also resets the format picker to favorites first with the default
+// format selected.
+func (m *Model) openPresetPicker() {
+	m.presetNames = append([]string{"Custom"}, m.config.PresetNames()...)
+	m.presetIndex = 0
+
+	m.formats = m.orderedFormats()
+	m.formatIndex = max(slices.Index(m.formats, m.config.DefaultFormat), 0)
+
+	m.state = stateSelectPreset
 }
 
-func (m Model) updateFileBrowser(msg tea.KeyMsg, isInput bool) (tea.Model, tea.Cmd) {
-	maxVisible := m.height - 15
-	if maxVisible < 5 {
-		maxVisible = 5
+// orderedFormats lists the favorite formats in their configured order,
+// followed by the rest.
+func (m Model) orderedFormats() []string {
+	var formats []string
+	for _, f := range m.config.FavoriteFormats {
+		if slices.Contains(outputFormats, f) && !slices.Contains(formats, f) {
+			formats = append(formats, f)
+		}
 	}
+	for _, f := range outputFormats {
+		if !slices.Contains(formats, f) {
+			formats = append(formats, f)
+		}
+	}
+	return formats
+}
 
-	switch msg.String() {
-	case "up", "k":
-		if m.fileIndex > 0 {
-			m.fileIndex--
-			if m.fileIndex < m.scrollOffset {
-				m.scrollOffset = m.fileIndex
-			}
+func (m Model) updatePresetSelect(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch {
+	case key.Matches(msg, m.keys.Up):
+		if m.presetIndex > 0 {
+			m.presetIndex--
 		}
-	case "down", "j":
-		if m.fileIndex < len(m.files)-1 {
-			m.fileIndex++
-			if m.fileIndex >= m.scrollOffset+maxVisible {
-				m.scrollOffset = m.fileIndex - maxVisible + 1
-			}
+	case key.Matches(msg, m.keys.Down):
+		if m.presetIndex < len(m.presetNames)-1 {
+			m.presetIndex++
 		}
-	case "enter":
-		if m.fileIndex < len(m.files) {
-			entry := m.files[m.fileIndex]
-			if entry.isDir {
-				m.loadFiles(entry.path)
-			} else if isInput && entry.isImg {
-				m.inputFile = entry.path
-				m.config.LastInputDir = m.currentDir
-				m.state = stateSelectFormat
+	case key.Matches(msg, m.keys.Select):
+		m.preset = nil
+		m.presetName = ""
+		m.quality = m.config.DefaultQuality
+		if m.presetIndex > 0 {
+			p, err := m.config.Preset(m.presetNames[m.presetIndex])
+			if err != nil {
+				m.menuNotice = err.Error()
+				return m, nil
+			}
+			m.preset = &p
+			m.presetName = m.presetNames[m.presetIndex]
+			if p.Quality != 0 {
+				m.quality = p.Quality
+			}
+			for i, f := range m.formats {
+				if f == p.Format {
+					m.formatIndex = i
+				}
 			}
 		}
-	case "tab":
-		m.config.ShowHiddenFiles = !m.config.ShowHiddenFiles
-		m.loadFiles(m.currentDir)
+		m.menuNotice = ""
+		m.state = stateSelectFormat
 	}
 	return m, nil
 }
 
 func (m Model) updateFormatSelect(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
-	switch msg.String() {
-	case "up", "k", "left", "h":
+	switch {
+	case key.Matches(msg, m.keys.Up, m.keys.Left):
 		if m.formatIndex > 0 {
 			m.formatIndex--
 		}
-	case "down", "j", "right", "l":
+	case key.Matches(msg, m.keys.Down, m.keys.Right):
 		if m.formatIndex < len(m.formats)-1 {
 			m.formatIndex++
 		}
-	case "enter":
+	case key.Matches(msg, m.keys.Select):
 		m.outputFormat = m.formats[m.formatIndex]
-		ext := filepath.Ext(m.inputFile)
-		base := strings.TrimSuffix(filepath.Base(m.inputFile), ext)
-		if err := m.config.EnsureOutputDir(); err == nil {
-			m.outputFile = filepath.Join(m.config.OutputDir, base+"."+m.outputFormat)
-		} else {
-			m.outputFile = filepath.Join(filepath.Dir(m.inputFile), base+"."+m.outputFormat)
-		}
 		m.state = stateQuality
 	}
 	return m, nil
 }
 
+func (m Model) convertOptions() image.Options {
+	if m.resumeJobs != nil {
+		return m.resumeOptions
+	}
+	opts := image.Options{NameTemplate: image.NameTemplate(m.config.NameTemplate)}
+	if m.preset != nil {
+		opts = m.preset.Apply(opts)
+	}
+	// The quality screen has the last word, starting from the preset's.
+	opts.Quality = m.quality
+	return opts
+}
+
+// nameJobs names outputs with the configured template, falling back to the
+// input's base name if the template can't be expanded.
+func (m Model) nameJobs(inputs []string, dir string) []image.Job {
+	opts := m.convertOptions()
+	jobs, err := image.NameJobs(inputs, dir, m.outputFormat, opts)
+	if err != nil {
+		opts.NameTemplate = image.DefaultNameTemplate
+		jobs, _ = image.NameJobs(inputs, dir, m.outputFormat, opts)
+	}
+	return jobs
+}
+
+func (m *Model) resolveOutputFile() {
+	dir := filepath.Dir(m.inputFile)
+	if err := m.config.EnsureOutputDir(); err == nil {
+		dir = m.config.OutputDir
+	}
+	m.outputFile = m.nameJobs([]string{m.inputFile}, dir)[0].Output
+}
+
 func (m Model) updateQuality(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
-	switch msg.String() {
-	case "left", "h":
+	switch {
+	case key.Matches(msg, m.keys.Left):
 		if m.quality > 1 {
 			m.quality -= 5
 			if m.quality < 1 {
 				m.quality = 1
 			}
 		}
-	case "right", "l":
+	case key.Matches(msg, m.keys.Right):
 		if m.quality < 100 {
 			m.quality += 5
 			if m.quality > 100 {
 				m.quality = 100
 			}
 		}
-	case "enter":
+	case key.Matches(msg, m.keys.Select):
 		if m.batchMode {
+			m.prepareBatchOutputDir()
 			m.state = stateBatchConfirm
 		} else {
-			m.state = stateConfirm
+			m.openOutputStep()
 		}
 	}
 	return m, nil
 }
 
 func (m Model) updateConfirm(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
-	switch msg.String() {
-	case "y", "enter":
+	switch {
+	case key.Matches(msg, m.keys.Yes, m.keys.Select):
 		m.state = stateConverting
 		m.converting = true
 		return m, m.doConvert()
-	case "n", "esc":
+	case key.Matches(msg, m.keys.No, m.keys.Back):
 		m.state = stateMenu
 	}
 	return m, nil
 }
 
 func (m Model) updateOutputDirBrowser(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
-	maxVisible := m.height - 15
-	if maxVisible < 5 {
-		maxVisible = 5
+	if m.updateBrowserKeys(msg) {
+		return m, nil
 	}
 
-	switch msg.String() {
-	case "up", "k":
-		if m.fileIndex > 0 {
-			m.fileIndex--
-			if m.fileIndex < m.scrollOffset {
-				m.scrollOffset = m.fileIndex
-			}
-		}
-	case "down", "j":
-		if m.fileIndex < len(m.files)-1 {
-			m.fileIndex++
-			if m.fileIndex >= m.scrollOffset+maxVisible {
-				m.scrollOffset = m.fileIndex - maxVisible + 1
-			}
-		}
-	case "enter":
+	switch {
+	case key.Matches(msg, m.keys.Select):
 		if m.fileIndex < len(m.files) {
 			entry := m.files[m.fileIndex]
 			if entry.isDir {
 				m.loadFiles(entry.path)
 			}
 		}
-	case "s":
+	case key.Matches(msg, m.keys.UseFolder):
+		if m.dirPickerFor == stateSelectOutput {
+			m.outputDir = m.currentDir
+			m.nextToOriginal = false
+			m.state = stateSelectOutput
+			break
+		}
 		m.config.OutputDir = m.currentDir
 		m.config.Save()
 		m.state = stateSettings
-	case "tab":
+	case key.Matches(msg, m.keys.Hidden):
 		m.config.ShowHiddenFiles = !m.config.ShowHiddenFiles
 		m.loadFiles(m.currentDir)
 	}
 	return m, nil
 }
 
-func (m Model) updateSettings(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
-	settings := []string{"Default Quality", "Default Format", "Output Directory", "Show Hidden Files", "Confirm Overwrite", "Back"}
+var settingNames = []string{"Default Quality", "Default Format", "Favorite Formats", "Output Directory", "Show Hidden Files", "Confirm Overwrite", "Theme", "Back"}
 
-	switch msg.String() {
-	case "up", "k":
+func (m Model) updateSettings(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch {
+	case key.Matches(msg, m.keys.Up):
 		if m.settingIndex > 0 {
 			m.settingIndex--
 		}
-	case "down", "j":
-		if m.settingIndex < len(settings)-1 {
+	case key.Matches(msg, m.keys.Down):
+		if m.settingIndex < len(settingNames)-1 {
 			m.settingIndex++
 		}
-	case "left", "h":
+	case key.Matches(msg, m.keys.Left):
 		switch m.settingIndex {
 		case 0:
 			if m.config.DefaultQuality > 10 {
 				m.config.DefaultQuality -= 5
 			}
+		case 1:
+			m.config.DefaultFormat = cycleFormat(m.config.DefaultFormat, -1)
+		case 6:
+			m.cycleTheme(-1)
 		}
-	case "right", "l":
+	case key.Matches(msg, m.keys.Right):
 		switch m.settingIndex {
 		case 0:
 			if m.config.DefaultQuality < 100 {
 				m.config.DefaultQuality += 5
 			}
+		case 1:
+			m.config.DefaultFormat = cycleFormat(m.config.DefaultFormat, 1)
+		case 6:
+			m.cycleTheme(1)
 		}
-	case "enter", " ":
+	case key.Matches(msg, m.keys.Select, m.keys.Toggle):
 		switch m.settingIndex {
+		case 1:
+			m.config.DefaultFormat = cycleFormat(m.config.DefaultFormat, 1)
 		case 2:
-			m.loadFiles(m.config.OutputDir)
-			m.state = stateSelectOutputDir
+			m.favoriteIndex = 0
+			m.state = stateFavorites
 		case 3:
-			m.config.ShowHiddenFiles = !m.config.ShowHiddenFiles
+			m.dirPickerFor = stateSettings
+			m.state = stateSelectOutputDir
+			m.loadFiles(m.config.OutputDir)
 		case 4:
-			m.config.ConfirmOverwrite = !m.config.ConfirmOverwrite
+			m.config.ShowHiddenFiles = !m.config.ShowHiddenFiles
 		case 5:
+			m.config.ConfirmOverwrite = !m.config.ConfirmOverwrite
+		case 6:
+			m.cycleTheme(1)
+		case 7:
 			m.config.Save()
 			m.state = stateMenu
 		}
@@ -464,160 +909,242 @@ func (m Model) updateSettings(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	return m, nil
 }
 
-type conversionDoneMsg struct {
-	err error
+// cycleTheme switches to the next or previous theme and restyles the TUI
+// at once.
+func (m *Model) cycleTheme(step int) {
+	i := max(slices.Index(config.Themes, m.config.Theme), 0)
+	m.config.Theme = config.Themes[(i+step+len(config.Themes))%len(config.Themes)]
+	m.styles = newStyles(themeFor(m.config.Theme))
+	m.spinner.Style = m.styles.Spinner
 }
 
-type batchDoneMsg struct {
-	results []batchResult
+func cycleFormat(current string, step int) string {
+	i := slices.Index(outputFormats, current)
+	if i < 0 {
+		return outputFormats[0]
+	}
+	return outputFormats[(i+step+len(outputFormats))%len(outputFormats)]
 }
 
-func (m Model) doConvert() tea.Cmd {
-	return func() tea.Msg {
-		opts := image.Options{
-			Quality: m.quality,
+// updateFavorites toggles favorites with space and reorders them with
+// shift+up/down (K/J). The list shows favorites first, in order.
+func (m Model) updateFavorites(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	formats := m.orderedFormats()
+	favs := m.config.FavoriteFormats
+
+	switch {
+	case key.Matches(msg, m.keys.Up):
+		if m.favoriteIndex > 0 {
+			m.favoriteIndex--
 		}
-		err := image.Convert(m.inputFile, m.outputFile, opts)
-		return conversionDoneMsg{err: err}
+	case key.Matches(msg, m.keys.Down):
+		if m.favoriteIndex < len(formats)-1 {
+			m.favoriteIndex++
+		}
+	case key.Matches(msg, m.keys.Toggle, m.keys.Remove):
+		f := formats[m.favoriteIndex]
+		if i := slices.Index(favs, f); i >= 0 {
+			m.config.FavoriteFormats = slices.Delete(slices.Clone(favs), i, i+1)
+		} else {
+			m.config.FavoriteFormats = append(slices.Clone(favs), f)
+		}
+		// Keep the cursor on the format as it moves between groups.
+		m.favoriteIndex = slices.Index(m.orderedFormats(), f)
+	case key.Matches(msg, m.keys.MoveUp, m.keys.MoveDown):
+		i := slices.Index(favs, formats[m.favoriteIndex])
+		j := i - 1
+		if key.Matches(msg, m.keys.MoveDown) {
+			j = i + 1
+		}
+		if i < 0 || j < 0 || j >= len(favs) {
+			break
+		}
+		favs = slices.Clone(favs)
+		favs[i], favs[j] = favs[j], favs[i]
+		m.config.FavoriteFormats = favs
+		m.favoriteIndex = j
+	case key.Matches(msg, m.keys.Select):
+		m.state = stateSettings
 	}
+	return m, nil
 }
 
-func (m Model) doBatchConvert() tea.Cmd {
-	return func() tea.Msg {
-		results := []batchResult{}
-		opts := image.Options{
-			Quality: m.quality,
-		}
-
-		for _, inputPath := range m.selectedFiles {
-			ext := filepath.Ext(inputPath)
-			base := strings.TrimSuffix(filepath.Base(inputPath), ext)
-			outputPath := filepath.Join(m.batchOutputDir, base+"."+m.outputFormat)
-
-			err := image.Convert(inputPath, outputPath, opts)
-			results = append(results, batchResult{
-				input:   inputPath,
-				output:  outputPath,
-				success: err == nil,
-				err:     err,
-			})
-		}
+type conversionDoneMsg struct {
+	err error
+}
 
-		return batchDoneMsg{results: results}
+func (m Model) doConvert() tea.Cmd {
+	return func() tea.Msg {
+		rec, opts := m.historyLog.Record(history.Entry{Command: "convert"}, m.convertOptions())
+		err := image.Convert(m.inputFile, m.outputFile, opts)
+		rec.Save()
+		return conversionDoneMsg{err: err}
 	}
 }
 
 func (m Model) updateBatchSelect(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
-	maxVisible := m.height - 15
-	if maxVisible < 5 {
-		maxVisible = 5
+	m.menuNotice = ""
+	if m.globbing {
+		m.updateGlobInput(msg)
+		return m, nil
+	}
+	if m.updateBrowserKeys(msg) {
+		return m, nil
 	}
 
-	switch msg.String() {
-	case "up", "k":
-		if m.fileIndex > 0 {
-			m.fileIndex--
-			if m.fileIndex < m.scrollOffset {
-				m.scrollOffset = m.fileIndex
-			}
-		}
-	case "down", "j":
-		if m.fileIndex < len(m.files)-1 {
-			m.fileIndex++
-			if m.fileIndex >= m.scrollOffset+maxVisible {
-				m.scrollOffset = m.fileIndex - maxVisible + 1
-			}
-		}
-	case " ": // Space to toggle selection
+	switch {
+	case key.Matches(msg, m.keys.Toggle): // A file, or a whole folder
 		if m.fileIndex < len(m.files) {
-			entry := &m.files[m.fileIndex]
-			if entry.isImg {
-				entry.selected = !entry.selected
-				if entry.selected {
-					m.selectedFiles = append(m.selectedFiles, entry.path)
-				} else {
-					// Remove from selected
-					for i, p := range m.selectedFiles {
-						if p == entry.path {
-							m.selectedFiles = append(m.selectedFiles[:i], m.selectedFiles[i+1:]...)
-							break
-						}
-					}
-				}
+			entry := m.files[m.fileIndex]
+			switch {
+			case entry.isDir && entry.name != "..":
+				m.toggleFolder(entry.path)
+			case !entry.isImg:
+			case m.isSelected(entry.path):
+				m.deselectFile(entry.path)
+			default:
+				m.selectFile(entry.path, entry.size)
 			}
+			m.applyView()
 		}
-	case "a": // Select all images
-		m.selectedFiles = []string{}
-		for i := range m.files {
-			if m.files[i].isImg {
-				m.files[i].selected = true
-				m.selectedFiles = append(m.selectedFiles, m.files[i].path)
+	case key.Matches(msg, m.keys.AddAll):
+		for _, e := range m.files {
+			if e.isImg {
+				m.selectFile(e.path, e.size)
 			}
 		}
-	case "n": // Deselect all
-		m.selectedFiles = []string{}
-		for i := range m.files {
-			m.files[i].selected = false
+		m.applyView()
+	case key.Matches(msg, m.keys.RemoveAll):
+		for _, e := range m.files {
+			m.deselectFile(e.path)
 		}
-	case "enter":
+		m.applyView()
+	case key.Matches(msg, m.keys.Glob):
+		m.globbing, m.globPattern = true, ""
+	case key.Matches(msg, m.keys.Basket):
+		m.basketIndex = 0
+		m.state = stateBasket
+	case key.Matches(msg, m.keys.Select):
 		if m.fileIndex < len(m.files) {
 			entry := m.files[m.fileIndex]
 			if entry.isDir {
 				m.loadFiles(entry.path)
 			} else if len(m.selectedFiles) > 0 {
 				m.config.LastInputDir = m.currentDir
-				m.state = stateSelectFormat
+				m.openPresetPicker()
 			}
 		}
-	case "c": // Continue with selection
+	case key.Matches(msg, m.keys.Continue):
 		if len(m.selectedFiles) > 0 {
 			m.config.LastInputDir = m.currentDir
-			m.state = stateSelectFormat
+			m.openPresetPicker()
 		}
-	case "tab":
+	case key.Matches(msg, m.keys.Hidden):
 		m.config.ShowHiddenFiles = !m.config.ShowHiddenFiles
 		m.loadFiles(m.currentDir)
 	}
 	return m, nil
 }
 
+// prepareBatchOutputDir picks the timestamped output directory for a batch
+// so the confirm and plan screens can show where files will go.
+func (m *Model) prepareBatchOutputDir() {
+	timestamp := m.now().Format("2006-01-02_15-04-05")
+	name := fmt.Sprintf("batch_%s_%s", m.outputFormat, timestamp)
+
+	// Create output directory in ~/Downloads/photon with timestamp
+	if err := m.config.EnsureOutputDir(); err == nil {
+		m.batchOutputDir = filepath.Join(m.config.OutputDir, name)
+	} else {
+		// Fallback to current directory
+		m.batchOutputDir = filepath.Join(m.currentDir, name)
+	}
+}
+
+func (m Model) openBatchJournal(jobs []image.Job, opts image.Options) (*image.Journal, error) {
+	if m.resumeJobs != nil {
+		return image.OpenJournal(filepath.Join(m.batchOutputDir, image.JournalName))
+	}
+	return image.CreateJournal(m.batchOutputDir, jobs, opts)
+}
+
+func (m Model) batchJobs() []image.Job {
+	if m.resumeJobs != nil {
+		return m.resumeJobs
+	}
+
+	return m.nameJobs(m.selectedFiles, m.batchOutputDir)
+}
+
 func (m Model) updateBatchConfirm(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
-	switch msg.String() {
-	case "y", "enter":
-		// Create output directory in ~/Downloads/photon with timestamp
-		if err := m.config.EnsureOutputDir(); err == nil {
-			timestamp := time.Now().Format("2006-01-02_15-04-05")
-			m.batchOutputDir = filepath.Join(m.config.OutputDir, fmt.Sprintf("batch_%s_%s", m.outputFormat, timestamp))
-		} else {
-			// Fallback to current directory
-			timestamp := time.Now().Format("2006-01-02_15-04-05")
-			m.batchOutputDir = filepath.Join(m.currentDir, fmt.Sprintf("batch_%s_%s", m.outputFormat, timestamp))
-		}
-		os.MkdirAll(m.batchOutputDir, 0755)
+	switch {
+	case key.Matches(msg, m.keys.Yes, m.keys.Select):
+		return m.startBatch()
+	case key.Matches(msg, m.keys.Plan):
+		m.batchPlan = image.PlanJobs(m.batchJobs())
+		m.planOffset = 0
+		m.state = stateBatchPlan
+	case key.Matches(msg, m.keys.No, m.keys.Back):
+		m.state = stateMenu
+	}
+	return m, nil
+}
 
-		m.state = stateBatchConverting
-		m.converting = true
-		return m, m.doBatchConvert()
-	case "n", "esc":
+// planRows is how many entries the batch plan shows at once.
+func (m Model) planRows() int {
+	return max(m.height-17, 5)
+}
+
+func (m Model) updateBatchPlan(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch {
+	case key.Matches(msg, m.keys.Up):
+		if m.planOffset > 0 {
+			m.planOffset--
+		}
+	case key.Matches(msg, m.keys.Down):
+		if m.planOffset < len(m.batchPlan)-m.planRows() {
+			m.planOffset++
+		}
+	case key.Matches(msg, m.keys.Yes, m.keys.Select):
+		if m.batchPlan.Failed() == 0 {
+			return m.startBatch()
+		}
+	case key.Matches(msg, m.keys.Plan) || msg.Type == tea.KeyBackspace:
+		m.state = stateBatchConfirm
+	case key.Matches(msg, m.keys.No):
 		m.state = stateMenu
 	}
 	return m, nil
 }
 
 func (m Model) View() string {
+	view := zoneMarker.ReplaceAllString(m.render(), "")
+	if m.preview != nil && m.preview.protocol == previewKitty && m.previewView == "" {
+		// The renderer only rewrites text, which leaves a kitty image on
+		// screen; delete it when no preview is shown. The renderer skips
+		// unchanged lines, so this is only sent when the image goes away.
+		view += kittyClear
+	}
+	return view
+}
+
+// render draws the screen with zone markers around what can be clicked.
+func (m Model) render() string {
 	var s strings.Builder
 
 	// Header
-	header := LogoStyle.Render(LogoSmall) + "  " + SubtitleStyle.Render("Image Format Converter")
+	header := m.styles.Logo.Render(LogoSmall) + "  " + m.styles.Subtitle.Render("Image Format Converter")
 	s.WriteString(header + "\n\n")
 
 	switch m.state {
 	case stateMenu:
 		s.WriteString(m.viewMenu())
 	case stateSelectInput:
-		s.WriteString(m.viewFileBrowser("Select Input Image"))
+		s.WriteString(m.withPreview(m.viewFileBrowser("Select Input Image")))
 	case stateSelectOutput:
-		s.WriteString(m.viewFileBrowser("Select Output Location"))
+		s.WriteString(m.viewOutputStep())
+	case stateSelectPreset:
+		s.WriteString(m.viewPresetSelect())
 	case stateSelectFormat:
 		s.WriteString(m.viewFormatSelect())
 	case stateQuality:
@@ -630,16 +1157,26 @@ func (m Model) View() string {
 		s.WriteString(m.viewComplete())
 	case stateSettings:
 		s.WriteString(m.viewSettings())
+	case stateFavorites:
+		s.WriteString(m.viewFavorites())
 	case stateSelectOutputDir:
 		s.WriteString(m.viewOutputDirBrowser())
 	case stateBatchSelect:
-		s.WriteString(m.viewBatchSelect())
+		s.WriteString(m.withPreview(m.viewBatchSelect()))
+	case stateBasket:
+		s.WriteString(m.viewBasket())
 	case stateBatchConfirm:
 		s.WriteString(m.viewBatchConfirm())
+	case stateBatchPlan:
+		s.WriteString(m.viewBatchPlan())
 	case stateBatchConverting:
 		s.WriteString(m.viewBatchConverting())
 	case stateBatchComplete:
 		s.WriteString(m.viewBatchComplete())
+	case stateRecentFiles:
+		s.WriteString(m.viewRecentFiles())
+	case stateHistory:
+		s.WriteString(m.viewHistory())
 	}
 
 	// Footer help
@@ -650,30 +1187,35 @@ func (m Model) View() string {
 
 func (m Model) viewMenu() string {
 	var s strings.Builder
-	s.WriteString(TitleStyle.Render("Main Menu") + "\n\n")
+	s.WriteString(m.styles.Title.Render("Main Menu") + "\n\n")
 
 	for i, item := range m.menuItems {
 		cursor := "  "
-		style := ItemStyle
+		style := m.styles.Item
 		if i == m.menuIndex {
-			cursor = SelectedItemStyle.Render("▸ ")
-			style = SelectedItemStyle
+			cursor = m.styles.SelectedItem.Render("▸ ")
+			style = m.styles.SelectedItem
 		}
-		s.WriteString(cursor + style.Render(item) + "\n")
+		s.WriteString(mark(zoneItem, i, cursor+style.Render(item)) + "\n")
+	}
+
+	if m.menuNotice != "" {
+		s.WriteString("\n" + m.styles.Warning.Render(m.menuNotice))
+	}
+	if m.configErr != nil {
+		s.WriteString("\n" + m.styles.Error.Render(m.configErr.Error()))
 	}
 
-	return BoxStyle.Render(s.String())
+	return m.styles.Box.Render(s.String())
 }
 
 func (m Model) viewFileBrowser(title string) string {
 	var s strings.Builder
-	s.WriteString(TitleStyle.Render(title) + "\n")
-	s.WriteString(SubtitleStyle.Render(m.currentDir) + "\n\n")
+	s.WriteString(m.styles.Title.Render(title) + "\n")
+	s.WriteString(m.styles.Subtitle.Render(m.currentDir) + "\n")
+	s.WriteString(m.viewBrowserStatus() + "\n")
 
-	maxVisible := m.height - 15
-	if maxVisible < 5 {
-		maxVisible = 5
-	}
+	maxVisible := m.browserRows()
 
 	end := m.scrollOffset + maxVisible
 	if end > len(m.files) {
@@ -686,46 +1228,210 @@ func (m Model) viewFileBrowser(title string) string {
 		var style lipgloss.Style
 
 		if i == m.fileIndex {
-			cursor = SelectedItemStyle.Render("▸ ")
+			cursor = m.styles.SelectedItem.Render("▸ ")
 		}
 
 		icon := "  "
 		if entry.isDir {
 			icon = "📁 "
-			style = DirStyle
+			style = m.styles.Dir
 		} else if entry.isImg {
 			icon = "🖼  "
-			style = ImageFileStyle
+			style = m.styles.ImageFile
 		} else {
 			icon = "📄 "
-			style = FileStyle
+			style = m.styles.File
 		}
 
 		if i == m.fileIndex {
-			style = SelectedItemStyle
+			style = m.styles.SelectedItem
 		}
 
-		s.WriteString(cursor + icon + style.Render(entry.name) + "\n")
+		s.WriteString(mark(zoneItem, i, cursor+icon+style.Render(entry.name)+m.viewEntryDetail(entry)) + "\n")
+	}
+	if len(m.files) == 0 {
+		s.WriteString(m.styles.Subtitle.Render("  No matching files") + "\n")
 	}
 
 	if len(m.files) > maxVisible {
-		s.WriteString(fmt.Sprintf("\n%s", SubtitleStyle.Render(fmt.Sprintf("(%d/%d)", m.fileIndex+1, len(m.files)))))
+		s.WriteString(fmt.Sprintf("\n%s", m.styles.Subtitle.Render(fmt.Sprintf("(%d/%d)", m.fileIndex+1, len(m.files)))))
+	}
+
+	return m.styles.Box.Render(s.String())
+}
+
+// previewSize is the preview pane's image area in cells, or zero when the
+// terminal is too narrow to show it next to the file list.
+func (m Model) previewSize() (int, int) {
+	if m.preview == nil || m.preview.protocol == previewOff || m.width < 90 {
+		return 0, 0
+	}
+	return min(40, m.width/3), max(m.height-17, 5)
+}
+
+// syncPreview schedules rendering the highlighted image when it changed,
+// once the cursor has rested on it for previewDelay, and drops the preview
+// when the browser is left.
+func (m *Model) syncPreview() tea.Cmd {
+	var key, path string
+	cols, rows := m.previewSize()
+	if (m.state == stateSelectInput || m.state == stateBatchSelect) && cols > 0 &&
+		m.fileIndex < len(m.files) && m.files[m.fileIndex].isImg {
+		path = m.files[m.fileIndex].path
+		key = fmt.Sprintf("%s|%dx%d", path, cols, rows)
+	}
+	if key == m.previewKey {
+		return nil
+	}
+
+	m.previewKey, m.previewView, m.previewErr = key, "", nil
+	if key == "" {
+		return nil
+	}
+	return tea.Tick(previewDelay, func(time.Time) tea.Msg { return previewTickMsg{key: key, path: path} })
+}
+
+func (m Model) withPreview(list string) string {
+	cols, rows := m.previewSize()
+	if cols == 0 {
+		return list
+	}
+
+	var s strings.Builder
+	s.WriteString(m.styles.Title.Render("Preview") + "\n")
+	switch {
+	case m.previewKey == "":
+		s.WriteString(m.styles.Subtitle.Render("No image selected"))
+	case m.previewErr != nil:
+		s.WriteString(m.styles.Error.Render(m.previewErr.Error()))
+	case m.previewView == "":
+		s.WriteString(m.styles.Subtitle.Render("Loading…"))
+	default:
+		s.WriteString(m.previewView)
 	}
 
-	return BoxStyle.Render(s.String())
+	pane := m.styles.Box.Width(cols + 6).Height(rows + 4).Render(s.String())
+	return lipgloss.JoinHorizontal(lipgloss.Top, list, " ", pane)
+}
+
+func (m Model) viewRecentFiles() string {
+	var s strings.Builder
+	s.WriteString(m.styles.Title.Render("Recent Files") + "\n\n")
+
+	for i, r := range m.config.RecentFiles {
+		cursor := "  "
+		style := m.styles.ImageFile
+		if i == m.recentIndex {
+			cursor = m.styles.SelectedItem.Render("▸ ")
+			style = m.styles.SelectedItem
+		}
+
+		line := style.Render(filepath.Base(r.Path))
+		if r.Format != "" {
+			line += " → " + strings.ToUpper(r.Format)
+		}
+		if info, err := os.Stat(r.Path); err == nil {
+			line += "  " + m.styles.Subtitle.Render(formatBytes(info.Size()))
+		} else {
+			line += "  " + m.styles.Error.Render("missing")
+		}
+		s.WriteString(mark(zoneItem, i, cursor+line) + "\n")
+	}
+
+	r := m.config.RecentFiles[m.recentIndex]
+	s.WriteString("\n" + m.styles.Subtitle.Render(r.Path) + "\n")
+	if r.Format != "" {
+		settings := fmt.Sprintf("%s, quality %d", strings.ToUpper(r.Format), r.Quality)
+		if r.Preset != "" {
+			settings += ", preset " + r.Preset
+		}
+		s.WriteString(m.styles.Subtitle.Render("Last: "+settings) + "\n")
+	}
+	if !r.ConvertedAt.IsZero() {
+		s.WriteString(m.styles.Subtitle.Render("Converted "+r.ConvertedAt.Format("2006-01-02 15:04")) + "\n")
+	}
+
+	if m.menuNotice != "" {
+		s.WriteString("\n" + m.styles.Warning.Render(m.menuNotice))
+	}
+
+	return m.styles.Box.Render(s.String())
+}
+
+func formatBytes(n int64) string {
+	switch {
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(n)/(1<<10))
+	}
+	return fmt.Sprintf("%d B", n)
+}
+
+func (m Model) viewPresetSelect() string {
+	var s strings.Builder
+	s.WriteString(m.styles.Title.Render("Select Preset") + "\n\n")
+
+	for i, name := range m.presetNames {
+		cursor := "  "
+		style := m.styles.Item
+		if i == m.presetIndex {
+			cursor = m.styles.SelectedItem.Render("▸ ")
+			style = m.styles.SelectedItem
+		}
+		s.WriteString(mark(zoneItem, i, cursor+style.Render(name)) + "\n")
+	}
+	s.WriteString("\n")
+
+	if m.presetIndex == 0 {
+		s.WriteString(m.styles.Subtitle.Render("Choose format and quality yourself"))
+	} else if p, err := m.config.Preset(m.presetNames[m.presetIndex]); err != nil {
+		s.WriteString(m.styles.Error.Render(err.Error()))
+	} else {
+		s.WriteString(m.styles.Subtitle.Render(describePreset(p)))
+	}
+
+	if m.menuNotice != "" {
+		s.WriteString("\n" + m.styles.Warning.Render(m.menuNotice))
+	}
+
+	return m.styles.Box.Render(s.String())
+}
+
+func describePreset(p config.Preset) string {
+	var parts []string
+	if p.Format != "" {
+		parts = append(parts, strings.ToUpper(p.Format))
+	}
+	if p.Lossless {
+		parts = append(parts, "lossless")
+	} else if p.Quality != 0 {
+		parts = append(parts, fmt.Sprintf("quality %d", p.Quality))
+	}
+	if p.Width != 0 || p.Height != 0 {
+		parts = append(parts, fmt.Sprintf("fit %dx%d", p.Width, p.Height))
+	}
+	if p.Metadata != "" {
+		parts = append(parts, p.Metadata+" metadata")
+	}
+	return strings.Join(parts, " • ")
 }
 
 func (m Model) viewFormatSelect() string {
 	var s strings.Builder
-	s.WriteString(TitleStyle.Render("Select Output Format") + "\n\n")
-	s.WriteString(SubtitleStyle.Render("Input: "+filepath.Base(m.inputFile)) + "\n\n")
+	s.WriteString(m.styles.Title.Render("Select Output Format") + "\n\n")
+	s.WriteString(m.styles.Subtitle.Render("Input: "+filepath.Base(m.inputFile)) + "\n\n")
 
 	for i, format := range m.formats {
-		style := FormatBadge
+		style := m.styles.FormatBadge
 		if i == m.formatIndex {
-			style = FormatBadgeSelected
+			style = m.styles.FormatBadgeSelected
 		}
-		s.WriteString(style.Render(strings.ToUpper(format)) + " ")
+		label := strings.ToUpper(format)
+		if slices.Contains(m.config.FavoriteFormats, format) {
+			label = "★ " + label
+		}
+		s.WriteString(mark(zoneItem, i, style.Render(label)) + " ")
 	}
 	s.WriteString("\n\n")
 
@@ -740,23 +1446,23 @@ func (m Model) viewFormatSelect() string {
 	}
 
 	selected := m.formats[m.formatIndex]
-	s.WriteString(SubtitleStyle.Render(info[selected]))
+	s.WriteString(m.styles.Subtitle.Render(info[selected]))
 
-	return BoxStyle.Render(s.String())
+	return m.styles.Box.Render(s.String())
 }
 
 func (m Model) viewQuality() string {
 	var s strings.Builder
-	s.WriteString(TitleStyle.Render("Set Quality") + "\n\n")
+	s.WriteString(m.styles.Title.Render("Set Quality") + "\n\n")
 
 	// Quality bar
 	barWidth := 40
 	filled := (m.quality * barWidth) / 100
 	empty := barWidth - filled
 
-	filledBar := SliderFilled.Render(strings.Repeat("=", filled))
-	emptyBar := SliderTrack.Render(strings.Repeat("-", empty))
-	s.WriteString("[" + filledBar + emptyBar + "]")
+	filledBar := m.styles.SliderFilled.Render(strings.Repeat("=", filled))
+	emptyBar := m.styles.SliderTrack.Render(strings.Repeat("-", empty))
+	s.WriteString("[" + mark(zoneSlider, 0, filledBar+emptyBar) + "]")
 	s.WriteString(fmt.Sprintf("  %d%%\n\n", m.quality))
 
 	// Quality hint
@@ -773,41 +1479,142 @@ func (m Model) viewQuality() string {
 	default:
 		hint = "Maximum quality, largest file size"
 	}
-	s.WriteString(SubtitleStyle.Render(hint))
+	s.WriteString(m.styles.Subtitle.Render(hint))
+
+	if estimate := m.viewEstimate(); estimate != "" {
+		s.WriteString("\n\n" + estimate)
+	}
+
+	return m.styles.Box.Render(s.String())
+}
+
+// estimateSample is the image the quality screen encodes: the input file,
+// or the first selected file in batch mode.
+func (m Model) estimateSample() string {
+	if m.batchMode {
+		if len(m.selectedFiles) > 0 {
+			return m.selectedFiles[0]
+		}
+		return ""
+	}
+	return m.inputFile
+}
+
+// estimateSize is the size in cells of each before/after crop.
+func (m Model) estimateSize() (int, int) {
+	return min(max((m.width-20)/2, 8), 30), 6
+}
+
+// syncEstimate schedules a new estimate once the quality screen's settings
+// have been left alone for estimateDelay.
+func (m *Model) syncEstimate() tea.Cmd {
+	var key string
+	if sample := m.estimateSample(); m.state == stateQuality && sample != "" {
+		cols, rows := m.estimateSize()
+		key = fmt.Sprintf("%s|%s|%d|%s|%dx%d", sample, m.outputFormat, m.quality, m.presetName, cols, rows)
+	}
+	if key == m.estimateKey {
+		return nil
+	}
+	if m.estimateCancel != nil {
+		m.estimateCancel()
+		m.estimateCancel = nil
+	}
+	m.estimateKey = key
+	if key == "" {
+		m.estimating = false
+		return nil
+	}
+	m.estimating = true
+	return tea.Tick(estimateDelay, func(time.Time) tea.Msg { return estimateTickMsg{key: key} })
+}
+
+func (m Model) viewEstimate() string {
+	sample := m.estimateSample()
+	if sample == "" {
+		return ""
+	}
+
+	var s strings.Builder
+	if m.batchMode {
+		s.WriteString(m.styles.Subtitle.Render("Sample: "+filepath.Base(sample)) + "\n")
+	}
+
+	switch {
+	case m.estimateErr != nil && !m.estimating:
+		s.WriteString(m.styles.Error.Render("Can't estimate: " + m.estimateErr.Error()))
+		return s.String()
+	case m.est.sample != sample:
+		s.WriteString(m.spinner.View() + " Estimating…")
+		return s.String()
+	}
+
+	e := m.est
+	size := fmt.Sprintf("Estimated size: %s", formatBytes(e.outputSize))
+	if e.inputSize > 0 && e.outputSize > 0 {
+		if e.outputSize <= e.inputSize {
+			size += fmt.Sprintf(" (%.1f× smaller than %s)", float64(e.inputSize)/float64(e.outputSize), formatBytes(e.inputSize))
+		} else {
+			size += fmt.Sprintf(" (%.1f× larger than %s)", float64(e.outputSize)/float64(e.inputSize), formatBytes(e.inputSize))
+		}
+	}
+	if m.estimating {
+		size += " " + m.spinner.View()
+	}
+	s.WriteString(size + "\n")
+
+	if !e.compared {
+		s.WriteString(m.styles.Subtitle.Render("No quality metrics: the output format can't be decoded"))
+		return s.String()
+	}
+	psnr := "lossless"
+	if !math.IsInf(e.psnr, 1) {
+		psnr = fmt.Sprintf("%.1f dB", e.psnr)
+	}
+	s.WriteString(fmt.Sprintf("PSNR: %s • SSIM: %.3f\n\n", psnr, e.ssim))
 
-	return BoxStyle.Render(s.String())
+	cols, _ := m.estimateSize()
+	label := lipgloss.NewStyle().Width(cols)
+	before := lipgloss.JoinVertical(lipgloss.Left, label.Render(m.styles.Subtitle.Render("Before")), e.before)
+	after := lipgloss.JoinVertical(lipgloss.Left, label.Render(m.styles.Subtitle.Render("After")), e.after)
+	s.WriteString(lipgloss.JoinHorizontal(lipgloss.Top, before, "  ", after))
+	return s.String()
 }
 
 func (m Model) viewConfirm() string {
 	var s strings.Builder
-	s.WriteString(TitleStyle.Render("Confirm Conversion") + "\n\n")
+	s.WriteString(m.styles.Title.Render("Confirm Conversion") + "\n\n")
 
-	s.WriteString("🖼  Input:   " + ImageFileStyle.Render(filepath.Base(m.inputFile)) + "\n")
-	s.WriteString("📄 Output:  " + ImageFileStyle.Render(filepath.Base(m.outputFile)) + "\n")
-	s.WriteString("📁 Format:  " + FormatBadge.Render(strings.ToUpper(m.outputFormat)) + "\n")
+	s.WriteString("🖼  Input:   " + m.styles.ImageFile.Render(filepath.Base(m.inputFile)) + "\n")
+	s.WriteString("📄 Output:  " + m.styles.ImageFile.Render(filepath.Base(m.outputFile)) + "\n")
+	s.WriteString("📂 Folder:  " + m.styles.Subtitle.Render(filepath.Dir(m.outputFile)) + "\n")
+	s.WriteString("📁 Format:  " + m.styles.FormatBadge.Render(strings.ToUpper(m.outputFormat)) + "\n")
 	s.WriteString(fmt.Sprintf("⚙  Quality: %d%%\n\n", m.quality))
+	if outputExists(m.outputFile) {
+		s.WriteString(m.styles.Warning.Render("⚠ This overwrites an existing file") + "\n\n")
+	}
 
-	s.WriteString(WarningStyle.Render("Proceed with conversion? (y/n)"))
+	s.WriteString(m.styles.Warning.Render("Proceed with conversion? (y/n)"))
 
-	return BoxStyle.Render(s.String())
+	return m.styles.Box.Render(s.String())
 }
 
 func (m Model) viewConverting() string {
 	var s strings.Builder
-	s.WriteString(TitleStyle.Render("Converting...") + "\n\n")
+	s.WriteString(m.styles.Title.Render("Converting...") + "\n\n")
 	s.WriteString(m.spinner.View() + " Processing " + filepath.Base(m.inputFile))
-	return BoxStyle.Render(s.String())
+	return m.styles.Box.Render(s.String())
 }
 
 func (m Model) viewComplete() string {
 	var s strings.Builder
 
 	if m.convErr != nil {
-		s.WriteString(ErrorStyle.Render("✗ Conversion Failed") + "\n\n")
+		s.WriteString(m.styles.Error.Render("✗ Conversion Failed") + "\n\n")
 		s.WriteString(m.convErr.Error())
 	} else {
-		s.WriteString(SuccessStyle.Render("✓ Conversion Complete") + "\n\n")
-		s.WriteString("📄 Output: " + ImageFileStyle.Render(m.outputFile) + "\n")
+		s.WriteString(m.styles.Success.Render("✓ Conversion Complete") + "\n\n")
+		s.WriteString("📄 Output: " + m.styles.ImageFile.Render(m.outputFile) + "\n")
 
 		if info, err := os.Stat(m.outputFile); err == nil {
 			size := float64(info.Size()) / 1024
@@ -817,18 +1624,16 @@ func (m Model) viewComplete() string {
 
 	s.WriteString("\n\nPress any key to continue...")
 
-	return BoxStyle.Render(s.String())
+	return m.styles.Box.Render(s.String())
 }
 
 func (m Model) viewOutputDirBrowser() string {
 	var s strings.Builder
-	s.WriteString(TitleStyle.Render("Select Output Directory") + "\n")
-	s.WriteString(SubtitleStyle.Render(m.currentDir) + "\n\n")
+	s.WriteString(m.styles.Title.Render("Select Output Directory") + "\n")
+	s.WriteString(m.styles.Subtitle.Render(m.currentDir) + "\n")
+	s.WriteString(m.viewBrowserStatus() + "\n")
 
-	maxVisible := m.height - 15
-	if maxVisible < 5 {
-		maxVisible = 5
-	}
+	maxVisible := m.browserRows()
 
 	end := m.scrollOffset + maxVisible
 	if end > len(m.files) {
@@ -837,76 +1642,106 @@ func (m Model) viewOutputDirBrowser() string {
 
 	for i := m.scrollOffset; i < end; i++ {
 		entry := m.files[i]
-		if !entry.isDir {
-			continue
-		}
 		cursor := "  "
-		style := DirStyle
+		style := m.styles.Dir
 
 		if i == m.fileIndex {
-			cursor = SelectedItemStyle.Render("▸ ")
-			style = SelectedItemStyle
+			cursor = m.styles.SelectedItem.Render("▸ ")
+			style = m.styles.SelectedItem
 		}
 
-		s.WriteString(cursor + "📁 " + style.Render(entry.name) + "\n")
+		s.WriteString(mark(zoneItem, i, cursor+"📁 "+style.Render(entry.name)) + "\n")
 	}
 
-	s.WriteString("\n" + SubtitleStyle.Render("Press 's' to select this directory"))
+	s.WriteString("\n" + m.styles.Subtitle.Render(fmt.Sprintf("Press '%s' to select this directory", m.keys.UseFolder.Help().Key)))
 
-	return BoxStyle.Render(s.String())
+	return m.styles.Box.Render(s.String())
 }
 
 func (m Model) viewSettings() string {
 	var s strings.Builder
-	s.WriteString(TitleStyle.Render("Settings") + "\n\n")
+	s.WriteString(m.styles.Title.Render("Settings") + "\n\n")
 
 	settings := []struct {
 		name  string
 		value string
 	}{
 		{"Default Quality", fmt.Sprintf("◀ %d%% ▶", m.config.DefaultQuality)},
-		{"Default Format", m.config.DefaultFormat},
+		{"Default Format", "◀ " + strings.ToUpper(m.config.DefaultFormat) + " ▶"},
+		{"Favorite Formats", strings.ToUpper(strings.Join(m.config.FavoriteFormats, ", "))},
 		{"Output Directory", m.config.OutputDir},
-		{"Show Hidden Files", boolIcon(m.config.ShowHiddenFiles)},
-		{"Confirm Overwrite", boolIcon(m.config.ConfirmOverwrite)},
+		{"Show Hidden Files", m.boolIcon(m.config.ShowHiddenFiles)},
+		{"Confirm Overwrite", m.boolIcon(m.config.ConfirmOverwrite)},
+		{"Theme", m.viewTheme()},
 		{"Back", ""},
 	}
 
 	for i, setting := range settings {
 		cursor := "  "
-		style := ItemStyle
+		style := m.styles.Item
 		if i == m.settingIndex {
-			cursor = SelectedItemStyle.Render("▸ ")
-			style = SelectedItemStyle
+			cursor = m.styles.SelectedItem.Render("▸ ")
+			style = m.styles.SelectedItem
 		}
 
 		line := setting.name
 		if setting.value != "" {
 			line = fmt.Sprintf("%-20s %s", setting.name, setting.value)
 		}
-		s.WriteString(cursor + style.Render(line) + "\n")
+		s.WriteString(mark(zoneItem, i, cursor+style.Render(line)) + "\n")
+	}
+
+	return m.styles.Box.Render(s.String())
+}
+
+func (m Model) viewFavorites() string {
+	var s strings.Builder
+	s.WriteString(m.styles.Title.Render("Favorite Formats") + "\n")
+	s.WriteString(m.styles.Subtitle.Render("Favorites are listed first in the format picker") + "\n\n")
+
+	for i, f := range m.orderedFormats() {
+		cursor := "  "
+		style := m.styles.Item
+		if i == m.favoriteIndex {
+			cursor = m.styles.SelectedItem.Render("▸ ")
+			style = m.styles.SelectedItem
+		}
+		star := "☆ "
+		if slices.Contains(m.config.FavoriteFormats, f) {
+			star = "★ "
+		}
+		s.WriteString(mark(zoneItem, i, cursor+star+style.Render(strings.ToUpper(f))) + "\n")
 	}
 
-	return BoxStyle.Render(s.String())
+	return m.styles.Box.Render(s.String())
+}
+
+func (m Model) viewTheme() string {
+	value := "◀ " + m.config.Theme + " ▶"
+	if os.Getenv("NO_COLOR") != "" {
+		value += m.styles.Subtitle.Render("  (NO_COLOR is set)")
+	}
+	return value
 }
 
-func boolIcon(b bool) string {
+func (m Model) boolIcon(b bool) string {
 	if b {
-		return SuccessStyle.Render("●")
+		return m.styles.Success.Render("●")
 	}
-	return lipgloss.NewStyle().Foreground(muted).Render("○")
+	return m.styles.Off.Render("○")
 }
 
 func (m Model) viewBatchSelect() string {
 	var s strings.Builder
-	s.WriteString(TitleStyle.Render("Batch Select Images") + "\n")
-	s.WriteString(SubtitleStyle.Render(m.currentDir) + "\n")
-	s.WriteString(WarningStyle.Render(fmt.Sprintf("Selected: %d images", len(m.selectedFiles))) + "\n\n")
-
-	maxVisible := m.height - 17
-	if maxVisible < 5 {
-		maxVisible = 5
+	s.WriteString(m.styles.Title.Render("Batch Select Images") + "\n")
+	s.WriteString(m.styles.Subtitle.Render(m.currentDir) + "\n")
+	s.WriteString(m.styles.Warning.Render("Selected: "+m.selectionSummary()) + "\n")
+	if m.globbing {
+		s.WriteString(m.styles.Warning.Render("Select matching: "+m.globPattern+"▏") + "\n")
 	}
+	s.WriteString(m.viewBrowserStatus() + "\n")
+
+	maxVisible := m.browserRows()
 
 	end := m.scrollOffset + maxVisible
 	if end > len(m.files) {
@@ -919,121 +1754,101 @@ func (m Model) viewBatchSelect() string {
 		var style lipgloss.Style
 
 		if i == m.fileIndex {
-			cursor = SelectedItemStyle.Render("▸ ")
+			cursor = m.styles.SelectedItem.Render("▸ ")
 		}
 
 		checkbox := "[ ] "
 		if entry.selected {
-			checkbox = SuccessStyle.Render("[✓] ")
+			checkbox = m.styles.Success.Render("[✓] ")
 		}
 
 		icon := "   "
 		if entry.isDir {
 			icon = "📁 "
-			style = DirStyle
+			style = m.styles.Dir
 			checkbox = "    "
 		} else if entry.isImg {
 			icon = "🖼  "
-			style = ImageFileStyle
+			style = m.styles.ImageFile
 		} else {
 			icon = "📄 "
-			style = FileStyle
+			style = m.styles.File
 			checkbox = "    "
 		}
 
 		if i == m.fileIndex {
-			style = SelectedItemStyle
+			style = m.styles.SelectedItem
 		}
 
-		s.WriteString(cursor + checkbox + icon + style.Render(entry.name) + "\n")
+		s.WriteString(mark(zoneItem, i, cursor+checkbox+icon+style.Render(entry.name)+m.viewEntryDetail(entry)) + "\n")
+	}
+	if len(m.files) == 0 {
+		s.WriteString(m.styles.Subtitle.Render("  No matching files") + "\n")
+	}
+	if m.menuNotice != "" {
+		s.WriteString("\n" + m.styles.Warning.Render(m.menuNotice))
 	}
 
 	if len(m.files) > maxVisible {
-		s.WriteString(fmt.Sprintf("\n%s", SubtitleStyle.Render(fmt.Sprintf("(%d/%d)", m.fileIndex+1, len(m.files)))))
+		s.WriteString(fmt.Sprintf("\n%s", m.styles.Subtitle.Render(fmt.Sprintf("(%d/%d)", m.fileIndex+1, len(m.files)))))
 	}
 
-	return BoxStyle.Render(s.String())
+	return m.styles.Box.Render(s.String())
 }
 
 func (m Model) viewBatchConfirm() string {
 	var s strings.Builder
-	s.WriteString(TitleStyle.Render("Confirm Batch Conversion") + "\n\n")
+	s.WriteString(m.styles.Title.Render("Confirm Batch Conversion") + "\n\n")
 
-	s.WriteString(fmt.Sprintf("🖼  Files:   %s\n", WarningStyle.Render(fmt.Sprintf("%d images", len(m.selectedFiles)))))
-	s.WriteString(fmt.Sprintf("📄 Format:  %s\n", FormatBadge.Render(strings.ToUpper(m.outputFormat))))
+	s.WriteString(fmt.Sprintf("🖼  Files:   %s\n", m.styles.Warning.Render(fmt.Sprintf("%d images", len(m.selectedFiles)))))
+	s.WriteString(fmt.Sprintf("📄 Format:  %s\n", m.styles.FormatBadge.Render(strings.ToUpper(m.outputFormat))))
 	s.WriteString(fmt.Sprintf("⚙  Quality: %d%%\n", m.quality))
-	s.WriteString(fmt.Sprintf("📁 Output:  %s\n\n", SubtitleStyle.Render(m.config.OutputDir)))
-
-	s.WriteString(WarningStyle.Render("Proceed with batch conversion? (y/n)"))
+	s.WriteString(fmt.Sprintf("📁 Output:  %s\n\n", m.styles.Subtitle.Render(m.batchOutputDir)))
 
-	return BoxStyle.Render(s.String())
-}
+	s.WriteString(m.styles.Warning.Render("Proceed with batch conversion? (y/n)"))
 
-func (m Model) viewBatchConverting() string {
-	var s strings.Builder
-	s.WriteString(TitleStyle.Render("Converting...") + "\n\n")
-	s.WriteString(m.spinner.View() + fmt.Sprintf(" Processing %d images...", len(m.selectedFiles)))
-	return BoxStyle.Render(s.String())
+	return m.styles.Box.Render(s.String())
 }
 
-func (m Model) viewBatchComplete() string {
+func (m Model) viewBatchPlan() string {
 	var s strings.Builder
+	s.WriteString(m.styles.Title.Render("Preview Plan") + "\n")
+	s.WriteString(m.styles.Subtitle.Render(m.batchOutputDir) + "\n\n")
 
-	successCount := 0
-	for _, r := range m.batchResults {
-		if r.success {
-			successCount++
-		}
-	}
+	maxVisible := m.planRows()
 
-	if successCount == len(m.batchResults) {
-		s.WriteString(SuccessStyle.Render("✓ Batch Complete") + "\n\n")
-	} else {
-		s.WriteString(WarningStyle.Render("⚠ Batch Complete (with errors)") + "\n\n")
+	end := m.planOffset + maxVisible
+	if end > len(m.batchPlan) {
+		end = len(m.batchPlan)
 	}
 
-	s.WriteString(fmt.Sprintf("🖼  Converted: %d/%d images\n", successCount, len(m.batchResults)))
-	s.WriteString(fmt.Sprintf("📁 Output:    %s\n\n", SubtitleStyle.Render(m.batchOutputDir)))
-
-	// Show errors if any
-	for _, r := range m.batchResults {
-		if !r.success {
-			s.WriteString(ErrorStyle.Render("✗ ") + filepath.Base(r.input) + ": " + r.err.Error() + "\n")
+	for _, e := range m.batchPlan[m.planOffset:end] {
+		line := m.styles.ImageFile.Render(filepath.Base(e.Input)) + " → " + filepath.Base(e.Output)
+		switch {
+		case e.Err != nil:
+			line = m.styles.Error.Render("✗ ") + filepath.Base(e.Input) + ": " + e.Err.Error()
+		case e.Overwrite:
+			line += " " + m.styles.Warning.Render("(overwrite)")
 		}
+		s.WriteString("  " + line + "\n")
 	}
 
-	s.WriteString("\nPress any key to continue...")
-
-	return BoxStyle.Render(s.String())
-}
+	if len(m.batchPlan) > maxVisible {
+		s.WriteString(m.styles.Subtitle.Render(fmt.Sprintf("(%d-%d of %d)", m.planOffset+1, end, len(m.batchPlan))) + "\n")
+	}
 
-func (m Model) viewHelp() string {
-	var help string
-	switch m.state {
-	case stateMenu:
-		help = "↑/↓: navigate • enter: select • q: quit"
-	case stateSelectInput, stateSelectOutput:
-		help = "↑/↓: navigate • enter: select • tab: toggle hidden • esc: back"
-	case stateSelectFormat:
-		help = "←/→: select format • enter: confirm • esc: back"
-	case stateQuality:
-		help = "←/→: adjust quality • enter: confirm • esc: back"
-	case stateSettings:
-		help = "↑/↓: navigate • ←/→: adjust • enter: toggle • esc: back"
-	case stateSelectOutputDir:
-		help = "↑/↓: navigate • enter: open dir • s: select current • esc: back"
-	case stateBatchSelect:
-		help = "↑/↓: navigate • space: select • a: all • n: none • c: continue • esc: back"
-	case stateBatchConfirm:
-		help = "y: confirm • n: cancel"
-	default:
-		help = "esc: back to menu • q: quit"
+	s.WriteString("\n")
+	if n := m.batchPlan.Failed(); n > 0 {
+		s.WriteString(m.styles.Error.Render(fmt.Sprintf("%d files cannot be converted", n)))
+	} else {
+		s.WriteString(m.styles.Warning.Render("Proceed with batch conversion? (y/n)"))
 	}
-	return HelpStyle.Render(help)
+
+	return m.styles.Box.Render(s.String())
 }
 
 func Run() error {
-	p := tea.NewProgram(NewModel(), tea.WithAltScreen())
+	p := tea.NewProgram(NewModel(), tea.WithAltScreen(), tea.WithMouseCellMotion())
 	_, err := p.Run()
 	return err
 }
@@ -0,0 +1,243 @@
+package tui
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/x/ansi"
+)
+
+// Views mark what can be clicked with zone markers: escape sequences that
+// take up no cells, which View strips again. A click is matched to an item
+// by looking for the markers around its cell in the rendered screen.
+
+type zoneKind int
+
+const (
+	// zoneItem is a row of the screen's list, or a format badge; the
+	// index is the item's index in the list.
+	zoneItem zoneKind = iota + 1
+	// zoneSlider is the track of the quality slider.
+	zoneSlider
+)
+
+const doubleClickTime = 400 * time.Millisecond
+
+var zoneMarker = regexp.MustCompile(`\x1b\[[0-9;]*z`)
+
+func mark(kind zoneKind, index int, s string) string {
+	return fmt.Sprintf("\x1b[%d;%dz%s\x1b[z", kind, index, s)
+}
+
+// zone is a marked item on the screen, covering columns x0 to x1 (not
+// included) of line y.
+type zone struct {
+	kind   zoneKind
+	index  int
+	x0, x1 int
+	y      int
+}
+
+// zones finds the marked items in a rendered screen. Like bubbletea, it
+// keeps the last height lines of a screen that is too tall.
+func zones(view string, height int) []zone {
+	lines := strings.Split(view, "\n")
+	if height > 0 && len(lines) > height {
+		lines = lines[len(lines)-height:]
+	}
+
+	var found []zone
+	for y, line := range lines {
+		var open *zone
+		var state byte
+		x := 0
+		for line != "" {
+			seq, width, n, next := ansi.DecodeSequence(line, state, nil)
+			state, line = next, line[n:]
+			if width > 0 || !zoneMarker.MatchString(seq) {
+				x += width
+				continue
+			}
+			var kind, index int
+			if _, err := fmt.Sscanf(seq, "\x1b[%d;%dz", &kind, &index); err == nil {
+				open = &zone{kind: zoneKind(kind), index: index, x0: x, y: y}
+			} else if open != nil {
+				open.x1 = x
+				found = append(found, *open)
+				open = nil
+			}
+		}
+	}
+	return found
+}
+
+func (m Model) zones() []zone {
+	return zones(m.render(), m.height)
+}
+
+func (m Model) zoneAt(x, y int) (zone, bool) {
+	for _, z := range m.zones() {
+		if z.y == y && x >= z.x0 && x < z.x1 {
+			return z, true
+		}
+	}
+	return zone{}, false
+}
+
+// click is the last left click, to tell double-clicks.
+type click struct {
+	state state
+	index int
+	at    time.Time
+}
+
+func (m Model) updateMouse(msg tea.MouseMsg) (tea.Model, tea.Cmd) {
+	if m.globbing || m.state == stateBatchConverting {
+		return m, nil
+	}
+
+	switch {
+	case msg.Button == tea.MouseButtonWheelUp:
+		m.scroll(-1)
+	case msg.Button == tea.MouseButtonWheelDown:
+		m.scroll(1)
+	case msg.Action == tea.MouseActionRelease:
+		m.dragging = false
+	case msg.Action == tea.MouseActionMotion && msg.Button == tea.MouseButtonLeft:
+		if m.dragging {
+			m.setQualityAt(msg.X, m.dragZone)
+		}
+	case msg.Action == tea.MouseActionPress && msg.Button == tea.MouseButtonLeft:
+		return m.click(msg.X, msg.Y)
+	}
+	return m, nil
+}
+
+// cursor returns the highlighted index of the screen's list and the list's
+// length, or nil when the screen has no list.
+func (m *Model) cursor() (*int, int) {
+	switch m.state {
+	case stateMenu:
+		return &m.menuIndex, len(m.menuItems)
+	case stateSelectInput, stateBatchSelect, stateSelectOutputDir:
+		return &m.fileIndex, len(m.files)
+	case stateSelectPreset:
+		return &m.presetIndex, len(m.presetNames)
+	case stateSelectFormat:
+		return &m.formatIndex, len(m.formats)
+	case stateRecentFiles:
+		return &m.recentIndex, len(m.config.RecentFiles)
+	case stateHistory:
+		return &m.historyIndex, len(m.historyEntries)
+	case stateSettings:
+		return &m.settingIndex, len(settingNames)
+	case stateFavorites:
+		return &m.favoriteIndex, len(m.orderedFormats())
+	case stateBasket:
+		return &m.basketIndex, len(m.selectedFiles)
+	}
+	return nil, 0
+}
+
+// highlight moves the screen's cursor to i, scrolling the browsers to
+// keep it in view.
+func (m *Model) highlight(i int) bool {
+	cur, n := m.cursor()
+	if cur == nil || n == 0 {
+		return false
+	}
+	*cur = max(min(i, n-1), 0)
+	if cur == &m.fileIndex {
+		m.ensureVisible()
+	}
+	return true
+}
+
+// scroll moves the cursor with the mouse wheel; the batch plan, which has
+// no cursor, scrolls instead.
+func (m *Model) scroll(step int) {
+	if m.state == stateBatchPlan {
+		m.planOffset = max(min(m.planOffset+step, len(m.batchPlan)-m.planRows()), 0)
+		return
+	}
+	if cur, _ := m.cursor(); cur != nil {
+		m.highlight(*cur + step)
+	}
+}
+
+// click highlights the clicked item, and opens it on a double-click as
+// enter would. Pressing on the quality slider sets the quality and starts
+// a drag.
+func (m Model) click(x, y int) (tea.Model, tea.Cmd) {
+	z, ok := m.zoneAt(x, y)
+	if !ok {
+		m.lastClick = click{}
+		return m, nil
+	}
+	if z.kind == zoneSlider {
+		m.dragging, m.dragZone = true, z
+		m.setQualityAt(x, z)
+		return m, nil
+	}
+
+	now := m.now()
+	last := m.lastClick
+	double := last.state == m.state && last.index == z.index && now.Sub(last.at) < doubleClickTime
+	m.lastClick = click{state: m.state, index: z.index, at: now}
+	if double {
+		// A third click starts over.
+		m.lastClick = click{}
+	}
+	if !m.highlight(z.index) || !double {
+		return m, nil
+	}
+	return m.activate()
+}
+
+// activate acts on the highlighted item as its key would: enter in most
+// lists, space for images in the batch browser and for favorites.
+func (m Model) activate() (tea.Model, tea.Cmd) {
+	b := m.keys.Select
+	switch m.state {
+	case stateBasket:
+		return m, nil
+	case stateFavorites:
+		b = m.keys.Toggle
+	case stateBatchSelect:
+		if !m.files[m.fileIndex].isDir {
+			b = m.keys.Toggle
+		}
+	}
+	msg, ok := keyMsg(b)
+	if !ok {
+		return m, nil
+	}
+	m.searching = false
+	return m.update(msg)
+}
+
+// keyMsg returns a key press that b matches.
+func keyMsg(b key.Binding) (tea.KeyMsg, bool) {
+	if !b.Enabled() {
+		return tea.KeyMsg{}, false
+	}
+	name := b.Keys()[0]
+	alt := strings.HasPrefix(name, "alt+") && name != "alt+"
+	name = strings.TrimPrefix(name, "alt+")
+	for t := tea.KeyType(-128); t < 128; t++ {
+		if t != tea.KeyRunes && t.String() == name {
+			return tea.KeyMsg{Type: t, Alt: alt}, true
+		}
+	}
+	return tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(name), Alt: alt}, true
+}
+
+// setQualityAt sets the quality from a column of the slider z, or its
+// nearest end.
+func (m *Model) setQualityAt(x int, z zone) {
+	m.quality = max(min((x-z.x0+1)*100/(z.x1-z.x0), 100), 1)
+}
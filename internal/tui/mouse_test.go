@@ -0,0 +1,172 @@
+package tui
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/mahamedmuse/photon/internal/testutil"
+)
+
+func mouseModel(t *testing.T) Model {
+	t.Helper()
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	m := NewModel()
+	m.width, m.height = 80, 40
+	return m
+}
+
+func mouse(m Model, msgs ...tea.MouseMsg) Model {
+	for _, msg := range msgs {
+		next, _ := m.Update(msg)
+		m = next.(Model)
+	}
+	return m
+}
+
+func findZone(t *testing.T, m Model, kind zoneKind, index int) zone {
+	t.Helper()
+	for _, z := range m.zones() {
+		if z.kind == kind && z.index == index {
+			return z
+		}
+	}
+	t.Fatalf("no zone %d/%d on screen %v", kind, index, m.state)
+	return zone{}
+}
+
+func press(x, y int) tea.MouseMsg {
+	return tea.MouseMsg{X: x, Y: y, Button: tea.MouseButtonLeft, Action: tea.MouseActionPress}
+}
+
+func clickOn(z zone) tea.MouseMsg {
+	return press(z.x0, z.y)
+}
+
+func TestZones(t *testing.T) {
+	m := testModel(stateSelectInput)
+	list := m.styles.SelectedItem.Render("▸ ") + "🖼  a.png\n" + mark(zoneItem, 1, "  📁 "+m.styles.Dir.Render("dir"))
+	view := "header\n" + lipgloss.JoinHorizontal(lipgloss.Top, m.styles.Box.Render(list), " ", mark(zoneSlider, 0, "[===]"))
+
+	got := zones(view, 0)
+	want := []zone{
+		{kind: zoneSlider, index: 0, x0: 18, x1: 23, y: 1},
+		{kind: zoneItem, index: 1, x0: 3, x1: 11, y: 4},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("zones = %+v", got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("zone %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+	if strings.Contains(zoneMarker.ReplaceAllString(view, ""), "z") {
+		t.Error("markers left after stripping")
+	}
+
+	// A screen taller than the terminal loses its top lines.
+	if z := zones(view, 3); len(z) != 1 || z[0].y != 0 {
+		t.Errorf("zones in 3 lines = %+v", z)
+	}
+}
+
+func TestMouseMenu(t *testing.T) {
+	m := mouseModel(t)
+	if strings.Contains(m.View(), "\x1b[1;") {
+		t.Fatal("View shows zone markers")
+	}
+
+	now := time.Now()
+	m.now = func() time.Time { return now }
+
+	settings := findZone(t, m, zoneItem, 5)
+	m = mouse(m, clickOn(settings))
+	if m.state != stateMenu || m.menuIndex != 5 {
+		t.Fatalf("single click: state %v, index %d", m.state, m.menuIndex)
+	}
+	// Clicks further apart than doubleClickTime are single clicks.
+	now = now.Add(doubleClickTime)
+	m = mouse(m, clickOn(settings))
+	if m.state != stateMenu {
+		t.Fatalf("slow clicks opened %v", m.state)
+	}
+	now = now.Add(doubleClickTime / 2)
+	m = mouse(m, clickOn(settings))
+	if m.state != stateSettings {
+		t.Fatalf("double click should open settings, state %v", m.state)
+	}
+
+	m = mouse(m, tea.MouseMsg{Button: tea.MouseButtonWheelDown}, tea.MouseMsg{Button: tea.MouseButtonWheelDown})
+	if m.settingIndex != 2 {
+		t.Errorf("wheel moved to %d, want 2", m.settingIndex)
+	}
+}
+
+func TestMouseBrowser(t *testing.T) {
+	m := mouseModel(t)
+	dir := t.TempDir()
+	os.Mkdir(filepath.Join(dir, "sub"), 0755)
+	testutil.WritePNG(t, filepath.Join(dir, "sub", "a.png"), 4, 4)
+	testutil.WritePNG(t, filepath.Join(dir, "b.png"), 4, 4)
+
+	m.batchMode = true
+	m.state = stateBatchSelect
+	m.loadFiles(dir)
+	// .., sub, b.png
+	m = mouse(m, tea.MouseMsg{Button: tea.MouseButtonWheelDown})
+	if m.fileIndex != 1 {
+		t.Fatalf("wheel moved to %d", m.fileIndex)
+	}
+
+	img := findZone(t, m, zoneItem, 2)
+	m = mouse(m, clickOn(img))
+	if m.fileIndex != 2 || len(m.selectedFiles) != 0 {
+		t.Fatalf("single click: index %d, selected %v", m.fileIndex, m.selectedFiles)
+	}
+	m = mouse(m, clickOn(img))
+	if len(m.selectedFiles) != 1 {
+		t.Fatalf("double click on an image should select it, got %v", m.selectedFiles)
+	}
+
+	sub := findZone(t, m, zoneItem, 1)
+	m = mouse(m, clickOn(sub), clickOn(sub))
+	if m.currentDir != filepath.Join(dir, "sub") {
+		t.Errorf("double click on a folder should open it, in %s", m.currentDir)
+	}
+}
+
+func TestMouseFormatAndSlider(t *testing.T) {
+	m := mouseModel(t)
+	m.inputFile = filepath.Join(t.TempDir(), "a.png")
+	m.openPresetPicker()
+	m.state = stateSelectFormat
+
+	webp := findZone(t, m, zoneItem, 3)
+	m = mouse(m, clickOn(webp), clickOn(webp))
+	if m.state != stateQuality || m.outputFormat != m.formats[3] {
+		t.Fatalf("state %v, format %q", m.state, m.outputFormat)
+	}
+
+	slider := findZone(t, m, zoneSlider, 0)
+	m = mouse(m, press(slider.x0+9, slider.y))
+	if m.quality != 25 || !m.dragging {
+		t.Fatalf("click on the slider: quality %d, dragging %v", m.quality, m.dragging)
+	}
+	// Dragging past the end pins the quality at 100.
+	m = mouse(m,
+		tea.MouseMsg{X: slider.x1 + 5, Y: slider.y + 3, Button: tea.MouseButtonLeft, Action: tea.MouseActionMotion},
+		tea.MouseMsg{X: slider.x1 + 5, Y: slider.y + 3, Action: tea.MouseActionRelease})
+	if m.quality != 100 || m.dragging {
+		t.Errorf("after drag: quality %d, dragging %v", m.quality, m.dragging)
+	}
+	m = mouse(m, tea.MouseMsg{X: slider.x0, Y: slider.y, Button: tea.MouseButtonLeft, Action: tea.MouseActionMotion})
+	if m.quality != 100 {
+		t.Errorf("motion without a drag changed quality to %d", m.quality)
+	}
+}
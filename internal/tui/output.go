@@ -0,0 +1,143 @@
+package tui
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/cursor"
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/mahamedmuse/photon/internal/image"
+)
+
+// openOutputStep starts the single-file output step from the configured
+// directory and name template.
+func (m *Model) openOutputStep() {
+	m.resolveOutputFile()
+	m.editOutput()
+}
+
+// editOutput starts the single-file output step at m.outputFile.
+func (m *Model) editOutput() {
+	m.outputDir = filepath.Dir(m.outputFile)
+	m.nextToOriginal = m.outputDir == filepath.Dir(m.inputFile)
+
+	m.outputName = textinput.New()
+	m.outputName.Prompt = ""
+	m.outputName.CharLimit = 255
+	m.outputName.Cursor.SetMode(cursor.CursorStatic)
+	m.outputName.SetValue(filepath.Base(m.outputFile))
+	m.outputName.Focus()
+
+	m.overwritePath, m.outputNotice = "", ""
+	m.state = stateSelectOutput
+}
+
+func (m Model) outputTargetDir() string {
+	if m.nextToOriginal {
+		return filepath.Dir(m.inputFile)
+	}
+	return m.outputDir
+}
+
+// outputTarget is the path the output step currently points at, with the
+// format's extension added when the name has none.
+func (m Model) outputTarget() string {
+	name := strings.TrimSpace(m.outputName.Value())
+	if name != "" && filepath.Ext(name) == "" {
+		name += "." + m.outputFormat
+	}
+	return filepath.Join(m.outputTargetDir(), name)
+}
+
+// checkOutputName reports why the typed name can't be used, if it can't.
+func (m Model) checkOutputName() string {
+	name := strings.TrimSpace(m.outputName.Value())
+	switch {
+	case name == "":
+		return "Enter a file name"
+	case strings.ContainsRune(name, '/') || strings.ContainsRune(name, filepath.Separator):
+		return "The file name can't contain a path separator; use " + m.keys.ChooseFolder.Help().Key + " to pick a folder"
+	}
+	want, _ := image.FormatFromExtension("." + m.outputFormat)
+	if got, err := image.FormatFromExtension(name); filepath.Ext(name) != "" && (err != nil || got != want) {
+		return "The extension must match the output format (." + m.outputFormat + ")"
+	}
+	if m.outputTarget() == m.inputFile {
+		return "The output can't replace the input file"
+	}
+	return ""
+}
+
+func outputExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+func (m Model) updateOutputStep(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	m.outputNotice = ""
+
+	switch {
+	case key.Matches(msg, m.keys.NextToOriginal):
+		m.nextToOriginal = !m.nextToOriginal
+		return m, nil
+	case key.Matches(msg, m.keys.ChooseFolder):
+		m.dirPickerFor = stateSelectOutput
+		m.state = stateSelectOutputDir
+		m.loadFiles(m.outputDir)
+		return m, nil
+	case key.Matches(msg, m.keys.FreeName):
+		if target := m.outputTarget(); outputExists(target) {
+			m.outputName.SetValue(filepath.Base(image.UniqueName(target)))
+			m.outputName.CursorEnd()
+		}
+		return m, nil
+	case key.Matches(msg, m.keys.Select):
+		if problem := m.checkOutputName(); problem != "" {
+			m.outputNotice = problem
+			return m, nil
+		}
+		target := m.outputTarget()
+		// Overwriting needs a second enter when the setting asks for it.
+		if outputExists(target) && m.config.ConfirmOverwrite && m.overwritePath != target {
+			m.overwritePath = target
+			m.outputNotice = fmt.Sprintf("Press %s again to overwrite it, or %s for a free name", m.keys.Select.Help().Key, m.keys.FreeName.Help().Key)
+			return m, nil
+		}
+		if !m.nextToOriginal {
+			if err := os.MkdirAll(m.outputDir, 0755); err != nil {
+				m.outputNotice = err.Error()
+				return m, nil
+			}
+		}
+		m.outputFile = target
+		m.state = stateConfirm
+		return m, nil
+	}
+
+	var cmd tea.Cmd
+	m.outputName, cmd = m.outputName.Update(msg)
+	m.overwritePath = ""
+	return m, cmd
+}
+
+func (m Model) viewOutputStep() string {
+	var s strings.Builder
+	s.WriteString(m.styles.Title.Render("Output") + "\n\n")
+
+	s.WriteString("📁 Folder:  " + m.styles.Subtitle.Render(m.outputTargetDir()) + "\n")
+	s.WriteString("📄 Name:    " + m.outputName.View() + "\n\n")
+	s.WriteString(m.boolIcon(m.nextToOriginal) + " Save next to the original\n")
+
+	if target := m.outputTarget(); m.checkOutputName() == "" && outputExists(target) {
+		s.WriteString("\n" + m.styles.Warning.Render("⚠ "+filepath.Base(target)+" already exists and will be overwritten") + "\n")
+	}
+	if m.outputNotice != "" {
+		s.WriteString("\n" + m.styles.Warning.Render(m.outputNotice) + "\n")
+	}
+
+	return m.styles.Box.Render(s.String())
+}
@@ -0,0 +1,137 @@
+package tui
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/mahamedmuse/photon/internal/config"
+	"github.com/mahamedmuse/photon/internal/testutil"
+)
+
+func outputModel(t *testing.T) Model {
+	t.Helper()
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	src := t.TempDir()
+	input := filepath.Join(src, "photo.png")
+	testutil.WritePNG(t, input, 4, 4)
+
+	m := NewModel()
+	m.config.OutputDir = filepath.Join(t.TempDir(), "out")
+	m.config.ConfirmOverwrite = true
+	m.inputFile, m.outputFormat = input, "jpg"
+	m.openOutputStep()
+	return m
+}
+
+func send(m Model, msgs ...tea.KeyMsg) Model {
+	for _, msg := range msgs {
+		next, _ := m.Update(msg)
+		m = next.(Model)
+	}
+	return m
+}
+
+func typeText(s string) []tea.KeyMsg {
+	var msgs []tea.KeyMsg
+	for _, r := range s {
+		msgs = append(msgs, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{r}})
+	}
+	return msgs
+}
+
+var (
+	enter     = tea.KeyMsg{Type: tea.KeyEnter}
+	tab       = tea.KeyMsg{Type: tea.KeyTab}
+	clearLine = tea.KeyMsg{Type: tea.KeyCtrlU}
+)
+
+func TestOutputStep(t *testing.T) {
+	m := outputModel(t)
+	if m.state != stateSelectOutput || m.outputName.Value() != "photo.jpg" {
+		t.Fatalf("state %v, name %q", m.state, m.outputName.Value())
+	}
+
+	m = send(m, clearLine)
+	m = send(m, typeText("quick")...)
+	m = send(m, enter)
+	if want := filepath.Join(m.config.OutputDir, "quick.jpg"); m.state != stateConfirm || m.outputFile != want {
+		t.Errorf("got state %v, output %s; want confirm, %s", m.state, m.outputFile, want)
+	}
+
+	m = outputModel(t)
+	m = send(m, tab, enter)
+	if want := filepath.Join(filepath.Dir(m.inputFile), "photo.jpg"); m.outputFile != want {
+		t.Errorf("next to original wrote to %s, want %s", m.outputFile, want)
+	}
+}
+
+func TestOutputStepChecks(t *testing.T) {
+	m := outputModel(t)
+	m = send(m, clearLine)
+	m = send(m, typeText("photo.png")...)
+	m = send(m, enter)
+	if m.state != stateSelectOutput || m.outputNotice == "" {
+		t.Errorf("a .png name for jpg output was accepted")
+	}
+
+	m = outputModel(t)
+	os.MkdirAll(m.config.OutputDir, 0755)
+	existing := filepath.Join(m.config.OutputDir, "photo.jpg")
+	os.WriteFile(existing, []byte("x"), 0644)
+
+	m = send(m, enter)
+	if m.state != stateSelectOutput {
+		t.Fatal("overwriting should need a second enter")
+	}
+	m = send(m, tea.KeyMsg{Type: tea.KeyCtrlR})
+	if m.outputName.Value() != "photo-1.jpg" {
+		t.Errorf("ctrl+r suggested %q", m.outputName.Value())
+	}
+	m = send(m, enter)
+	if m.state != stateConfirm || m.outputFile != filepath.Join(m.config.OutputDir, "photo-1.jpg") {
+		t.Errorf("state %v, output %s", m.state, m.outputFile)
+	}
+
+	m = outputModel(t)
+	existing = m.outputTarget()
+	os.WriteFile(existing, []byte("x"), 0644)
+	m = send(m, enter, enter)
+	if m.state != stateConfirm || m.outputFile != existing {
+		t.Errorf("second enter should accept the overwrite, got state %v", m.state)
+	}
+}
+
+func TestRerunRecentUsesRecordedOutput(t *testing.T) {
+	m := outputModel(t)
+	output := filepath.Join(t.TempDir(), "renamed.jpg")
+	r := config.RecentFile{Path: m.inputFile, Output: output, Format: "jpg", Quality: 70}
+
+	m.rerunRecent(r)
+	if m.state != stateConfirm || m.outputFile != output {
+		t.Fatalf("state %v, output %s; want confirm, %s", m.state, m.outputFile, output)
+	}
+
+	// An output that exists now asks before it's overwritten.
+	os.WriteFile(output, []byte("x"), 0644)
+	m.rerunRecent(r)
+	if m.state != stateSelectOutput || m.outputTarget() != output {
+		t.Fatalf("state %v, target %s; want the output step at %s", m.state, m.outputTarget(), output)
+	}
+	m = send(m, enter)
+	if m.state != stateSelectOutput || m.outputNotice == "" {
+		t.Fatalf("state %v; the first enter should ask to overwrite", m.state)
+	}
+	m = send(m, enter)
+	if m.state != stateConfirm || m.outputFile != output {
+		t.Errorf("state %v, output %s; want confirm, %s", m.state, m.outputFile, output)
+	}
+
+	// Without the setting it goes straight to the confirm step.
+	m.config.ConfirmOverwrite = false
+	m.rerunRecent(r)
+	if m.state != stateConfirm || m.outputFile != output {
+		t.Errorf("state %v, output %s; want confirm, %s", m.state, m.outputFile, output)
+	}
+}
@@ -0,0 +1,310 @@
+package tui
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	goimage "image"
+	"image/color"
+	"image/color/palette"
+	"image/draw"
+	"image/png"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/mahamedmuse/photon/internal/image"
+)
+
+type previewProtocol string
+
+const (
+	previewOff    previewProtocol = "off"
+	previewBlocks previewProtocol = "blocks"
+	previewKitty  previewProtocol = "kitty"
+	previewITerm  previewProtocol = "iterm"
+	previewSixel  previewProtocol = "sixel"
+)
+
+// Terminal cells are assumed to be twice as tall as wide, about 10x20
+// pixels, when sizing images for graphics protocols.
+const (
+	cellWidth  = 10
+	cellHeight = 20
+
+	thumbSize     = 480
+	thumbCacheLen = 32
+)
+
+// previewDelay is how long the cursor has to rest on an image before it is
+// decoded, so scrolling through a folder doesn't decode every file passed.
+const previewDelay = 150 * time.Millisecond
+
+// detectPreviewProtocol picks a graphics protocol from PHOTON_PREVIEW
+// (off, blocks, kitty, iterm, sixel) or from the terminal's environment,
+// falling back to Unicode half blocks.
+func detectPreviewProtocol() previewProtocol {
+	switch p := previewProtocol(strings.ToLower(os.Getenv("PHOTON_PREVIEW"))); p {
+	case previewOff, previewBlocks, previewKitty, previewITerm, previewSixel:
+		return p
+	}
+
+	term := os.Getenv("TERM")
+	switch {
+	case os.Getenv("KITTY_WINDOW_ID") != "" || term == "xterm-kitty" || term == "xterm-ghostty":
+		return previewKitty
+	case os.Getenv("TERM_PROGRAM") == "iTerm.app" || os.Getenv("TERM_PROGRAM") == "WezTerm":
+		return previewITerm
+	case strings.Contains(term, "sixel") || term == "foot" || term == "mlterm":
+		return previewSixel
+	}
+	return previewBlocks
+}
+
+type thumbEntry struct {
+	key   string
+	thumb goimage.Image
+}
+
+// previewer decodes images into small thumbnails, keeping the most recent
+// ones, and renders them for a terminal protocol. It is shared between the
+// model and background commands.
+type previewer struct {
+	protocol previewProtocol
+
+	mu     sync.Mutex
+	thumbs []thumbEntry
+}
+
+func newPreviewer(protocol previewProtocol) *previewer {
+	return &previewer{protocol: protocol}
+}
+
+func (p *previewer) thumbnail(path string) (goimage.Image, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	key := fmt.Sprintf("%s|%d|%d", path, info.Size(), info.ModTime().UnixNano())
+
+	p.mu.Lock()
+	for i, e := range p.thumbs {
+		if e.key == key {
+			// Move to the front so the least recently used entry goes first.
+			copy(p.thumbs[1:i+1], p.thumbs[:i])
+			p.thumbs[0] = e
+			p.mu.Unlock()
+			return e.thumb, nil
+		}
+	}
+	p.mu.Unlock()
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return nil, err
+	}
+	thumb := img
+	if b := img.Bounds(); b.Dx() >= b.Dy() {
+		thumb = image.Resize(img, thumbSize, 0)
+	} else {
+		thumb = image.Resize(img, 0, thumbSize)
+	}
+
+	p.mu.Lock()
+	p.thumbs = append([]thumbEntry{{key, thumb}}, p.thumbs...)
+	if len(p.thumbs) > thumbCacheLen {
+		p.thumbs = p.thumbs[:thumbCacheLen]
+	}
+	p.mu.Unlock()
+	return thumb, nil
+}
+
+// render draws the image at path in a box of cols x rows cells. The result
+// is exactly rows lines of cols cells. Graphics protocol output goes at the
+// start of the first line, with the cursor moved back and forth around it
+// so the text renderer's idea of where the cursor is stays right.
+func (p *previewer) render(path string, cols, rows int) (string, error) {
+	thumb, err := p.thumbnail(path)
+	if err != nil {
+		return "", err
+	}
+
+	b := thumb.Bounds()
+	w, h := fitCells(b.Dx(), b.Dy(), cols, rows)
+
+	var seq string
+	switch p.protocol {
+	case previewKitty:
+		seq = kittyImage(image.Resize(thumb, w*cellWidth, h*cellHeight), w, h)
+	case previewITerm:
+		seq = itermImage(thumb, w, h)
+	case previewSixel:
+		seq = sixelImage(image.Resize(thumb, w*cellWidth, h*cellHeight))
+	default:
+		return halfBlocks(thumb, w, h, cols, rows), nil
+	}
+
+	blank := strings.Repeat(" ", cols)
+	lines := make([]string, rows)
+	for i := range lines {
+		lines[i] = blank
+	}
+	lines[0] = fmt.Sprintf("%s\x1b[%dD\x1b7%s\x1b8\x1b[%dC", blank, cols, seq, cols)
+	return strings.Join(lines, "\n"), nil
+}
+
+// fitCells fits a w x h pixel image in cols x rows cells, keeping its
+// aspect ratio.
+func fitCells(w, h, cols, rows int) (int, int) {
+	if w == 0 || h == 0 {
+		return 0, 0
+	}
+	cw, ch := cols, cols*h*cellWidth/(w*cellHeight)
+	if ch > rows {
+		cw, ch = rows*w*cellHeight/(h*cellWidth), rows
+	}
+	return max(cw, 1), max(ch, 1)
+}
+
+// halfBlocks draws two pixels per cell with "▀", the top one as foreground
+// and the bottom one as background colour, padded to cols x rows cells.
+func halfBlocks(img goimage.Image, w, h, cols, rows int) string {
+	small := image.Resize(img, w, h*2)
+	b := small.Bounds()
+
+	var s strings.Builder
+	for y := 0; y < rows; y++ {
+		if y > 0 {
+			s.WriteString("\n")
+		}
+		drawn := 0
+		if 2*y < b.Dy() {
+			for x := 0; x < b.Dx(); x++ {
+				top := color.NRGBAModel.Convert(small.At(b.Min.X+x, b.Min.Y+2*y)).(color.NRGBA)
+				bottom := top
+				if 2*y+1 < b.Dy() {
+					bottom = color.NRGBAModel.Convert(small.At(b.Min.X+x, b.Min.Y+2*y+1)).(color.NRGBA)
+				}
+				fmt.Fprintf(&s, "\x1b[38;2;%d;%d;%dm\x1b[48;2;%d;%d;%dm▀", top.R, top.G, top.B, bottom.R, bottom.G, bottom.B)
+			}
+			s.WriteString("\x1b[0m")
+			drawn = b.Dx()
+		}
+		s.WriteString(strings.Repeat(" ", max(cols-drawn, 0)))
+	}
+	return s.String()
+}
+
+func encodePNG(img goimage.Image) string {
+	var buf bytes.Buffer
+	png.Encode(&buf, img)
+	return base64.StdEncoding.EncodeToString(buf.Bytes())
+}
+
+// kittyClear deletes every image placed with the kitty graphics protocol.
+const kittyClear = "\x1b_Ga=d,d=A,q=2\x1b\\"
+
+// kittyImage transmits and places an image over cols x rows cells without
+// moving the cursor, replacing any previous preview.
+func kittyImage(img goimage.Image, cols, rows int) string {
+	data := encodePNG(img)
+
+	var s strings.Builder
+	s.WriteString(kittyClear)
+	for first := true; len(data) > 0; first = false {
+		chunk := data[:min(len(data), 4096)]
+		data = data[len(chunk):]
+		more := 0
+		if len(data) > 0 {
+			more = 1
+		}
+		if first {
+			fmt.Fprintf(&s, "\x1b_Ga=T,f=100,C=1,q=2,c=%d,r=%d,m=%d;%s\x1b\\", cols, rows, more, chunk)
+		} else {
+			fmt.Fprintf(&s, "\x1b_Gm=%d;%s\x1b\\", more, chunk)
+		}
+	}
+	return s.String()
+}
+
+func itermImage(img goimage.Image, cols, rows int) string {
+	return fmt.Sprintf("\x1b]1337;File=inline=1;width=%d;height=%d;preserveAspectRatio=1:%s\a", cols, rows, encodePNG(img))
+}
+
+// sixelImage encodes img as DEC sixel graphics with a 216 colour palette.
+func sixelImage(img goimage.Image) string {
+	b := img.Bounds()
+	pal := goimage.NewPaletted(goimage.Rect(0, 0, b.Dx(), b.Dy()), palette.WebSafe)
+	draw.FloydSteinberg.Draw(pal, pal.Bounds(), img, b.Min)
+
+	var s strings.Builder
+	fmt.Fprintf(&s, "\x1bPq\"1;1;%d;%d", b.Dx(), b.Dy())
+	for i, c := range palette.WebSafe {
+		r, g, bl, _ := c.RGBA()
+		fmt.Fprintf(&s, "#%d;2;%d;%d;%d", i, r*100/0xffff, g*100/0xffff, bl*100/0xffff)
+	}
+
+	w, h := b.Dx(), b.Dy()
+	for band := 0; band < h; band += 6 {
+		used := make(map[uint8]bool)
+		for y := band; y < min(band+6, h); y++ {
+			for x := 0; x < w; x++ {
+				used[pal.ColorIndexAt(x, y)] = true
+			}
+		}
+		for c := range used {
+			fmt.Fprintf(&s, "#%d", c)
+			run, last := 0, byte(0)
+			flush := func() {
+				switch {
+				case run > 3:
+					fmt.Fprintf(&s, "!%d%c", run, last)
+				case run > 0:
+					s.WriteString(strings.Repeat(string(last), run))
+				}
+			}
+			for x := 0; x < w; x++ {
+				bits := byte(0)
+				for dy := 0; dy < 6 && band+dy < h; dy++ {
+					if pal.ColorIndexAt(x, band+dy) == c {
+						bits |= 1 << dy
+					}
+				}
+				ch := bits + 63
+				if ch != last && run > 0 {
+					flush()
+					run = 0
+				}
+				last = ch
+				run++
+			}
+			flush()
+			s.WriteString("$")
+		}
+		s.WriteString("-")
+	}
+	s.WriteString("\x1b\\")
+	return s.String()
+}
+
+type previewTickMsg struct{ key, path string }
+
+type previewMsg struct {
+	key  string
+	view string
+	err  error
+}
+
+func renderPreview(p *previewer, key, path string, cols, rows int) tea.Cmd {
+	return func() tea.Msg {
+		view, err := p.render(path, cols, rows)
+		return previewMsg{key: key, view: view, err: err}
+	}
+}
@@ -0,0 +1,144 @@
+package tui
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/mahamedmuse/photon/internal/testutil"
+)
+
+func TestDetectPreviewProtocol(t *testing.T) {
+	for _, tt := range []struct {
+		env  map[string]string
+		want previewProtocol
+	}{
+		{map[string]string{}, previewBlocks},
+		{map[string]string{"KITTY_WINDOW_ID": "1"}, previewKitty},
+		{map[string]string{"TERM_PROGRAM": "iTerm.app"}, previewITerm},
+		{map[string]string{"TERM": "foot"}, previewSixel},
+		{map[string]string{"TERM_PROGRAM": "iTerm.app", "PHOTON_PREVIEW": "off"}, previewOff},
+		{map[string]string{"PHOTON_PREVIEW": "Sixel"}, previewSixel},
+	} {
+		for _, k := range []string{"PHOTON_PREVIEW", "TERM", "TERM_PROGRAM", "KITTY_WINDOW_ID"} {
+			t.Setenv(k, tt.env[k])
+		}
+		if got := detectPreviewProtocol(); got != tt.want {
+			t.Errorf("%v: got %s, want %s", tt.env, got, tt.want)
+		}
+	}
+}
+
+func TestFitCells(t *testing.T) {
+	for _, tt := range []struct{ w, h, cols, rows, wantW, wantH int }{
+		{200, 100, 40, 20, 40, 10},
+		{100, 200, 40, 20, 20, 20},
+		{100, 100, 40, 10, 20, 10},
+		{1000, 1, 40, 20, 40, 1},
+	} {
+		w, h := fitCells(tt.w, tt.h, tt.cols, tt.rows)
+		if w != tt.wantW || h != tt.wantH {
+			t.Errorf("fitCells(%d, %d, %d, %d) = %d, %d, want %d, %d", tt.w, tt.h, tt.cols, tt.rows, w, h, tt.wantW, tt.wantH)
+		}
+	}
+}
+
+func TestRenderPreview(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "a.png")
+	testutil.WritePNG(t, path, 64, 32)
+
+	for _, protocol := range []previewProtocol{previewBlocks, previewKitty, previewITerm, previewSixel} {
+		view, err := newPreviewer(protocol).render(path, 20, 8)
+		if err != nil {
+			t.Fatalf("%s: %v", protocol, err)
+		}
+		lines := strings.Split(view, "\n")
+		if len(lines) != 8 {
+			t.Fatalf("%s: got %d lines, want 8", protocol, len(lines))
+		}
+		for i, line := range lines {
+			if w := lipgloss.Width(line); w != 20 {
+				t.Errorf("%s: line %d is %d cells wide, want 20", protocol, i, w)
+			}
+		}
+	}
+
+	if _, err := newPreviewer(previewBlocks).render(filepath.Join(t.TempDir(), "missing.png"), 20, 8); err == nil {
+		t.Error("expected an error for a missing file")
+	}
+}
+
+func TestThumbnailCache(t *testing.T) {
+	dir := t.TempDir()
+	p := newPreviewer(previewBlocks)
+
+	var paths []string
+	for i := range thumbCacheLen + 2 {
+		path := filepath.Join(dir, string(rune('a'+i))+".png")
+		testutil.WritePNG(t, path, 4, 4)
+		paths = append(paths, path)
+		if _, err := p.thumbnail(path); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(p.thumbs) != thumbCacheLen {
+		t.Fatalf("cache holds %d thumbnails, want %d", len(p.thumbs), thumbCacheLen)
+	}
+	if !strings.HasPrefix(p.thumbs[len(p.thumbs)-1].key, paths[2]+"|") {
+		t.Errorf("oldest entry is %s, want %s", p.thumbs[len(p.thumbs)-1].key, paths[2])
+	}
+
+	// A changed file is decoded again rather than served from the cache.
+	testutil.WritePNG(t, paths[2], 6, 6)
+	os.Chtimes(paths[2], time.Now(), time.Now().Add(time.Hour))
+	thumb, err := p.thumbnail(paths[2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if thumb.Bounds().Dx() != 6 {
+		t.Errorf("got a stale %dpx thumbnail", thumb.Bounds().Dx())
+	}
+}
+
+func TestPreviewDebounce(t *testing.T) {
+	m := mouseModel(t)
+	m.width = 120
+	m.preview = newPreviewer(previewKitty)
+	dir := t.TempDir()
+	testutil.WritePNG(t, filepath.Join(dir, "a.png"), 4, 4)
+	testutil.WritePNG(t, filepath.Join(dir, "b.png"), 4, 4)
+	m.state = stateSelectInput
+	m.loadFiles(dir)
+
+	// .., a.png, b.png: pass over a.png on the way to b.png.
+	m.fileIndex = 1
+	cmd := m.syncPreview()
+	passed := cmd().(previewTickMsg)
+	m.fileIndex = 2
+	cmd = m.syncPreview()
+	rested := cmd().(previewTickMsg)
+
+	if next, cmd := m.update(passed); cmd != nil {
+		t.Fatalf("a.png was rendered after the cursor left it: %v", next.(Model).previewKey)
+	}
+	next, cmd := m.update(rested)
+	m = next.(Model)
+	next, _ = m.update(cmd())
+	m = next.(Model)
+	if m.previewView == "" || m.previewErr != nil {
+		t.Fatalf("b.png not rendered: %v", m.previewErr)
+	}
+	if strings.HasSuffix(m.View(), kittyClear) {
+		t.Error("the preview is cleared while it is shown")
+	}
+
+	m.state = stateMenu
+	m.syncPreview()
+	if !strings.HasSuffix(m.View(), kittyClear) {
+		t.Error("leaving the browser doesn't delete the kitty image")
+	}
+}
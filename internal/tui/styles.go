@@ -1,144 +1,265 @@
 package tui
 
-import "github.com/charmbracelet/lipgloss"
-
-var (
-	// Colors
-	primary   = lipgloss.Color("#7C3AED")
-	secondary = lipgloss.Color("#A78BFA")
-	accent    = lipgloss.Color("#F59E0B")
-	success   = lipgloss.Color("#10B981")
-	danger    = lipgloss.Color("#EF4444")
-	muted     = lipgloss.Color("#6B7280")
-	bg        = lipgloss.Color("#1F2937")
-	bgLight   = lipgloss.Color("#374151")
-	fg        = lipgloss.Color("#F9FAFB")
-	fgMuted   = lipgloss.Color("#9CA3AF")
+import (
+	"os"
 
+	"github.com/charmbracelet/bubbles/help"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// theme is a palette the styles are built from.
+type theme struct {
+	primary, secondary, accent, success, danger lipgloss.TerminalColor
+	muted, bg, bgLight, fg, fgMuted             lipgloss.TerminalColor
+
+	// mono themes have no colors, so selection is shown in reverse video.
+	mono bool
+}
+
+// themes are the built-in themes, named as in config.Themes.
+var themes = map[string]theme{
+	"default": {
+		primary:   lipgloss.Color("#7C3AED"),
+		secondary: lipgloss.Color("#A78BFA"),
+		accent:    lipgloss.Color("#F59E0B"),
+		success:   lipgloss.Color("#10B981"),
+		danger:    lipgloss.Color("#EF4444"),
+		muted:     lipgloss.Color("#6B7280"),
+		bg:        lipgloss.Color("#1F2937"),
+		bgLight:   lipgloss.Color("#374151"),
+		fg:        lipgloss.Color("#F9FAFB"),
+		fgMuted:   lipgloss.Color("#9CA3AF"),
+	},
+	// light is for terminals with a light background.
+	"light": {
+		primary:   lipgloss.Color("#6D28D9"),
+		secondary: lipgloss.Color("#7C3AED"),
+		accent:    lipgloss.Color("#B45309"),
+		success:   lipgloss.Color("#047857"),
+		danger:    lipgloss.Color("#B91C1C"),
+		muted:     lipgloss.Color("#6B7280"),
+		bg:        lipgloss.Color("#F9FAFB"),
+		bgLight:   lipgloss.Color("#D1D5DB"),
+		fg:        lipgloss.Color("#111827"),
+		fgMuted:   lipgloss.Color("#4B5563"),
+	},
+	"dracula": {
+		primary:   lipgloss.Color("#BD93F9"),
+		secondary: lipgloss.Color("#FF79C6"),
+		accent:    lipgloss.Color("#F1FA8C"),
+		success:   lipgloss.Color("#50FA7B"),
+		danger:    lipgloss.Color("#FF5555"),
+		muted:     lipgloss.Color("#6272A4"),
+		bg:        lipgloss.Color("#282A36"),
+		bgLight:   lipgloss.Color("#44475A"),
+		fg:        lipgloss.Color("#F8F8F2"),
+		fgMuted:   lipgloss.Color("#BFBFBF"),
+	},
+	"nord": {
+		primary:   lipgloss.Color("#88C0D0"),
+		secondary: lipgloss.Color("#81A1C1"),
+		accent:    lipgloss.Color("#EBCB8B"),
+		success:   lipgloss.Color("#A3BE8C"),
+		danger:    lipgloss.Color("#BF616A"),
+		muted:     lipgloss.Color("#4C566A"),
+		bg:        lipgloss.Color("#2E3440"),
+		bgLight:   lipgloss.Color("#434C5E"),
+		fg:        lipgloss.Color("#ECEFF4"),
+		fgMuted:   lipgloss.Color("#D8DEE9"),
+	},
+	"mono": {
+		primary:   lipgloss.NoColor{},
+		secondary: lipgloss.NoColor{},
+		accent:    lipgloss.NoColor{},
+		success:   lipgloss.NoColor{},
+		danger:    lipgloss.NoColor{},
+		muted:     lipgloss.NoColor{},
+		bg:        lipgloss.NoColor{},
+		bgLight:   lipgloss.NoColor{},
+		fg:        lipgloss.NoColor{},
+		fgMuted:   lipgloss.NoColor{},
+		mono:      true,
+	},
+}
+
+// themeFor returns the named theme, falling back to the default one. NO_COLOR
+// picks the mono theme whatever the setting.
+func themeFor(name string) theme {
+	if os.Getenv("NO_COLOR") != "" {
+		return themes["mono"]
+	}
+	if t, ok := themes[name]; ok {
+		return t
+	}
+	return themes["default"]
+}
+
+// styles are the TUI's styles, built from a theme. Each Model has its
+// own, so a model switching themes doesn't restyle another.
+type styles struct {
+	Logo, Title, Subtitle                  lipgloss.Style
+	Box, ActiveBox, Item, SelectedItem     lipgloss.Style
+	FormatBadge, FormatBadgeSelected       lipgloss.Style
+	Success, Error, Warning                lipgloss.Style
+	Progress, ProgressComplete, Help       lipgloss.Style
+	SliderTrack, SliderFilled, SliderThumb lipgloss.Style
+	Dir, File, ImageFile, Tab, ActiveTab   lipgloss.Style
+	Button, ButtonActive                   lipgloss.Style
+	// Spinner colors the spinner, and Off marks settings that are off.
+	Spinner, Off lipgloss.Style
+
+	// help styles the key help in the footer.
+	help help.Styles
+}
+
+// newStyles builds every style from t.
+func newStyles(t theme) styles {
+	var s styles
 	// Logo style
-	LogoStyle = lipgloss.NewStyle().
-			Foreground(primary).
-			Bold(true).
-			MarginBottom(1)
+	s.Logo = lipgloss.NewStyle().
+		Foreground(t.primary).
+		Bold(true).
+		MarginBottom(1)
 
 	// Title styles
-	TitleStyle = lipgloss.NewStyle().
-			Foreground(fg).
-			Bold(true).
-			Padding(0, 1).
-			MarginBottom(1)
+	s.Title = lipgloss.NewStyle().
+		Foreground(t.fg).
+		Bold(true).
+		Padding(0, 1).
+		MarginBottom(1)
 
-	SubtitleStyle = lipgloss.NewStyle().
-			Foreground(fgMuted).
-			Italic(true)
+	s.Subtitle = lipgloss.NewStyle().
+		Foreground(t.fgMuted).
+		Italic(true)
 
 	// Box styles
-	BoxStyle = lipgloss.NewStyle().
-			Border(lipgloss.RoundedBorder()).
-			BorderForeground(primary).
-			Padding(1, 2)
+	s.Box = lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(t.primary).
+		Padding(1, 2)
 
-	ActiveBoxStyle = lipgloss.NewStyle().
-			Border(lipgloss.RoundedBorder()).
-			BorderForeground(accent).
-			Padding(1, 2)
+	s.ActiveBox = lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(t.accent).
+		Padding(1, 2)
 
 	// List item styles
-	ItemStyle = lipgloss.NewStyle().
-			Foreground(fg).
-			PaddingLeft(2)
+	s.Item = lipgloss.NewStyle().
+		Foreground(t.fg).
+		PaddingLeft(2)
 
-	SelectedItemStyle = lipgloss.NewStyle().
-				Foreground(accent).
-				Bold(true).
-				PaddingLeft(1)
+	s.SelectedItem = lipgloss.NewStyle().
+		Foreground(t.accent).
+		Bold(true).
+		PaddingLeft(1)
 
 	// Format badge styles
-	FormatBadge = lipgloss.NewStyle().
-			Foreground(fg).
-			Background(primary).
-			Padding(0, 1).
-			MarginRight(1)
-
-	FormatBadgeSelected = lipgloss.NewStyle().
-				Foreground(bg).
-				Background(accent).
-				Bold(true).
-				Padding(0, 1).
-				MarginRight(1)
+	s.FormatBadge = lipgloss.NewStyle().
+		Foreground(t.fg).
+		Background(t.primary).
+		Padding(0, 1).
+		MarginRight(1)
+
+	s.FormatBadgeSelected = lipgloss.NewStyle().
+		Foreground(t.bg).
+		Background(t.accent).
+		Bold(true).
+		Padding(0, 1).
+		MarginRight(1)
 
 	// Status styles
-	SuccessStyle = lipgloss.NewStyle().
-			Foreground(success).
-			Bold(true)
+	s.Success = lipgloss.NewStyle().
+		Foreground(t.success).
+		Bold(true)
 
-	ErrorStyle = lipgloss.NewStyle().
-			Foreground(danger).
-			Bold(true)
+	s.Error = lipgloss.NewStyle().
+		Foreground(t.danger).
+		Bold(true)
 
-	WarningStyle = lipgloss.NewStyle().
-			Foreground(accent)
+	s.Warning = lipgloss.NewStyle().
+		Foreground(t.accent)
 
 	// Progress bar
-	ProgressStyle = lipgloss.NewStyle().
-			Foreground(primary)
+	s.Progress = lipgloss.NewStyle().
+		Foreground(t.primary)
 
-	ProgressCompleteStyle = lipgloss.NewStyle().
-				Foreground(success)
+	s.ProgressComplete = lipgloss.NewStyle().
+		Foreground(t.success)
 
 	// Help text
-	HelpStyle = lipgloss.NewStyle().
-			Foreground(muted).
-			MarginTop(1)
+	s.Help = lipgloss.NewStyle().
+		Foreground(t.muted).
+		MarginTop(1)
 
 	// Quality slider
-	SliderTrack = lipgloss.NewStyle().
-			Foreground(bgLight)
+	s.SliderTrack = lipgloss.NewStyle().
+		Foreground(t.bgLight)
 
-	SliderFilled = lipgloss.NewStyle().
-			Foreground(primary)
+	s.SliderFilled = lipgloss.NewStyle().
+		Foreground(t.primary)
 
-	SliderThumb = lipgloss.NewStyle().
-			Foreground(accent).
-			Bold(true)
+	s.SliderThumb = lipgloss.NewStyle().
+		Foreground(t.accent).
+		Bold(true)
 
 	// File item styles
-	DirStyle = lipgloss.NewStyle().
-			Foreground(secondary).
-			Bold(true)
+	s.Dir = lipgloss.NewStyle().
+		Foreground(t.secondary).
+		Bold(true)
 
-	FileStyle = lipgloss.NewStyle().
-			Foreground(fg)
+	s.File = lipgloss.NewStyle().
+		Foreground(t.fg)
 
-	ImageFileStyle = lipgloss.NewStyle().
-			Foreground(success)
+	s.ImageFile = lipgloss.NewStyle().
+		Foreground(t.success)
 
 	// Tab styles
-	TabStyle = lipgloss.NewStyle().
-			Foreground(fgMuted).
-			Padding(0, 2)
+	s.Tab = lipgloss.NewStyle().
+		Foreground(t.fgMuted).
+		Padding(0, 2)
 
-	ActiveTabStyle = lipgloss.NewStyle().
-			Foreground(accent).
-			Bold(true).
-			Padding(0, 2).
-			Border(lipgloss.NormalBorder(), false, false, true, false).
-			BorderForeground(accent)
+	s.ActiveTab = lipgloss.NewStyle().
+		Foreground(t.accent).
+		Bold(true).
+		Padding(0, 2).
+		Border(lipgloss.NormalBorder(), false, false, true, false).
+		BorderForeground(t.accent)
 
 	// Button styles
-	ButtonStyle = lipgloss.NewStyle().
-			Foreground(fg).
-			Background(bgLight).
-			Padding(0, 3).
-			MarginRight(1)
-
-	ButtonActiveStyle = lipgloss.NewStyle().
-				Foreground(bg).
-				Background(accent).
-				Bold(true).
-				Padding(0, 3).
-				MarginRight(1)
-)
+	s.Button = lipgloss.NewStyle().
+		Foreground(t.fg).
+		Background(t.bgLight).
+		Padding(0, 3).
+		MarginRight(1)
+
+	s.ButtonActive = lipgloss.NewStyle().
+		Foreground(t.bg).
+		Background(t.accent).
+		Bold(true).
+		Padding(0, 3).
+		MarginRight(1)
+
+	if t.mono {
+		s.SelectedItem = s.SelectedItem.Reverse(true)
+		s.FormatBadgeSelected = s.FormatBadgeSelected.Reverse(true)
+		s.ButtonActive = s.ButtonActive.Reverse(true)
+		s.SliderThumb = s.SliderThumb.Reverse(true)
+	}
+
+	s.Spinner = lipgloss.NewStyle().Foreground(t.primary)
+	s.Off = lipgloss.NewStyle().Foreground(t.muted)
+
+	s.help = help.Styles{
+		ShortKey:       lipgloss.NewStyle().Foreground(t.fgMuted),
+		ShortDesc:      lipgloss.NewStyle().Foreground(t.muted),
+		ShortSeparator: lipgloss.NewStyle().Foreground(t.bgLight),
+		Ellipsis:       lipgloss.NewStyle().Foreground(t.bgLight),
+		FullKey:        lipgloss.NewStyle().Foreground(t.fgMuted),
+		FullDesc:       lipgloss.NewStyle().Foreground(t.muted),
+		FullSeparator:  lipgloss.NewStyle().Foreground(t.bgLight),
+	}
+	return s
+}
 
 const Logo = `
  ██████╗ ██╗  ██╗ ██████╗ ████████╗ ██████╗ ███╗   ██╗
@@ -0,0 +1,203 @@
+package watch
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/mahamedmuse/photon/internal/image"
+)
+
+const DefaultDebounce = 500 * time.Millisecond
+
+// Watcher converts images as they are created or modified in Dir, writing
+// outputs next to them (or to Options.OutputDir) the same way ConvertBatch
+// does.
+type Watcher struct {
+	Dir      string
+	FromExt  string
+	ToExt    string
+	Options  image.Options
+	Debounce time.Duration
+
+	// Log receives a line for every file that failed to convert. Successful
+	// conversions go through Options.Reporter.
+	Log io.Writer
+}
+
+type pending struct {
+	path  string
+	timer *time.Timer
+	size  int64
+}
+
+// writes remembers the outputs the watcher wrote for one window, long
+// enough for the events they cause not to be taken for new files.
+type writes struct {
+	window time.Duration
+	at     map[string]time.Time
+}
+
+func (w *writes) add(path string, now time.Time) {
+	w.expire(now)
+	w.at[path] = now
+}
+
+func (w *writes) has(path string, now time.Time) bool {
+	w.expire(now)
+	_, ok := w.at[path]
+	return ok
+}
+
+func (w *writes) expire(now time.Time) {
+	for path, at := range w.at {
+		if now.Sub(at) >= w.window {
+			delete(w.at, path)
+		}
+	}
+}
+
+// Run watches until ctx is cancelled. A file is converted once it has seen
+// no events for Debounce and its size stopped changing, so files that are
+// still being written aren't picked up half-way.
+func (w *Watcher) Run(ctx context.Context) error {
+	w.FromExt = strings.TrimPrefix(strings.ToLower(w.FromExt), ".")
+	w.ToExt = strings.TrimPrefix(strings.ToLower(w.ToExt), ".")
+	if w.Debounce <= 0 {
+		w.Debounce = DefaultDebounce
+	}
+	if w.Log == nil {
+		w.Log = io.Discard
+	}
+
+	dstFormat, err := image.FormatFromExtension("." + w.ToExt)
+	if err != nil {
+		return err
+	}
+	if !image.CanEncode(dstFormat) {
+		return fmt.Errorf("no encoder available for %s in this build", dstFormat)
+	}
+
+	fsw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("create watcher: %w", err)
+	}
+	defer fsw.Close()
+
+	if w.Options.OutputDir != "" {
+		if err := os.MkdirAll(w.Options.OutputDir, 0755); err != nil {
+			return fmt.Errorf("create output directory: %w", err)
+		}
+	}
+
+	if err := fsw.Add(w.Dir); err != nil {
+		return fmt.Errorf("watch %s: %w", w.Dir, err)
+	}
+
+	timers := make(map[string]*pending)
+	ready := make(chan *pending)
+	written := &writes{window: w.Debounce, at: make(map[string]time.Time)}
+	index := 0
+
+	defer func() {
+		for _, p := range timers {
+			p.timer.Stop()
+		}
+	}()
+
+	schedule := func(path string) {
+		info, err := os.Stat(path)
+		if err != nil || info.IsDir() {
+			return
+		}
+		if p, ok := timers[path]; ok {
+			p.timer.Stop()
+		}
+		p := &pending{path: path, size: info.Size()}
+		p.timer = time.AfterFunc(w.Debounce, func() {
+			select {
+			case ready <- p:
+			case <-ctx.Done():
+			}
+		})
+		timers[path] = p
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+
+		case event, ok := <-fsw.Events:
+			if !ok {
+				return nil
+			}
+			if !event.Has(fsnotify.Create) && !event.Has(fsnotify.Write) {
+				continue
+			}
+			if written.has(event.Name, time.Now()) || !w.accepts(event.Name) {
+				continue
+			}
+			schedule(event.Name)
+
+		case err, ok := <-fsw.Errors:
+			if !ok {
+				return nil
+			}
+			fmt.Fprintf(w.Log, "watch error: %v\n", err)
+
+		case p := <-ready:
+			// A timer that fired while being rescheduled is stale.
+			path := p.path
+			if timers[path] != p {
+				continue
+			}
+			info, err := os.Stat(path)
+			if err != nil {
+				delete(timers, path)
+				continue
+			}
+			if info.Size() != p.size {
+				schedule(path)
+				continue
+			}
+			delete(timers, path)
+
+			index++
+			outDir := w.Options.OutputDir
+			if outDir == "" {
+				outDir = filepath.Dir(path)
+			}
+			job, err := image.NameJob(path, outDir, w.ToExt, index, w.Options, make(map[string]bool))
+			if err == nil {
+				err = image.Convert(job.Input, job.Output, w.Options)
+				written.add(job.Output, time.Now())
+			}
+			if err != nil {
+				fmt.Fprintf(w.Log, "Failed %s: %v\n", path, err)
+			}
+		}
+	}
+}
+
+func (w *Watcher) accepts(path string) bool {
+	name := filepath.Base(path)
+	if strings.HasPrefix(name, ".") {
+		return false
+	}
+	ext := strings.TrimPrefix(strings.ToLower(filepath.Ext(name)), ".")
+	if w.FromExt != "" {
+		return ext == w.FromExt
+	}
+	source, err := image.FormatFromExtension(name)
+	if err != nil {
+		return false
+	}
+	target, _ := image.FormatFromExtension("." + w.ToExt)
+	return source != target
+}
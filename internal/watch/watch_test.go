@@ -0,0 +1,101 @@
+package watch
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mahamedmuse/photon/internal/image"
+	"github.com/mahamedmuse/photon/internal/testutil"
+)
+
+func waitFor(path string, timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if _, err := os.Stat(path); err == nil {
+			return true
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	return false
+}
+
+func TestWatcherConvertsNewFiles(t *testing.T) {
+	dir := t.TempDir()
+	ctx, cancel := context.WithCancel(context.Background())
+
+	w := &Watcher{
+		Dir:      dir,
+		ToExt:    "jpg",
+		Options:  image.DefaultOptions(),
+		Debounce: 50 * time.Millisecond,
+	}
+	done := make(chan error, 1)
+	go func() { done <- w.Run(ctx) }()
+
+	// Give the watcher time to register before creating files.
+	time.Sleep(100 * time.Millisecond)
+	testutil.WritePNG(t, filepath.Join(dir, "shot.png"), 8, 8)
+	os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("hi"), 0644)
+
+	if !waitFor(filepath.Join(dir, "shot.jpg"), 5*time.Second) {
+		t.Error("expected shot.jpg to be created")
+	}
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Run: %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("watcher did not stop after cancel")
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "shot-1.jpg")); err == nil {
+		t.Error("output should not be converted again")
+	}
+}
+
+func TestWatcherAccepts(t *testing.T) {
+	w := &Watcher{ToExt: "webp"}
+	cases := map[string]bool{
+		"a.png":       true,
+		"a.HEIC":      true,
+		"a.webp":      false,
+		".hidden.png": false,
+		"notes.txt":   false,
+	}
+	for name, want := range cases {
+		if got := w.accepts(name); got != want {
+			t.Errorf("accepts(%q) = %v, want %v", name, got, want)
+		}
+	}
+
+	w.FromExt = "png"
+	if w.accepts("a.jpg") {
+		t.Error("expected --from to restrict accepted files")
+	}
+}
+
+func TestWritesExpire(t *testing.T) {
+	now := time.Now()
+	w := &writes{window: time.Second, at: make(map[string]time.Time)}
+	w.add("a.webp", now)
+	w.add("b.webp", now.Add(time.Second/2))
+
+	if !w.has("a.webp", now.Add(time.Second/2)) {
+		t.Error("a.webp expired inside the window")
+	}
+	if w.has("a.webp", now.Add(time.Second)) {
+		t.Error("a.webp kept after the window")
+	}
+	if len(w.at) != 1 {
+		t.Errorf("%d writes remembered, want 1", len(w.at))
+	}
+	if w.has("b.webp", now.Add(2*time.Second)) || len(w.at) != 0 {
+		t.Errorf("writes = %v, want none", w.at)
+	}
+}